@@ -1,6 +1,117 @@
 package alerts
 
+import (
+	"context"
+	"time"
+)
+
+// LabelChat label overriding destination chat, e.g. key of telegram.Config.Chats or chat id
+const LabelChat = "chat"
+
 // Alerter is an interface for alerts
 type Alerter interface {
 	PostMessage(message string, infoLevel string) error
 }
+
+// AlerterV2 is context-aware interface for structured alerts
+type AlerterV2 interface {
+	Send(ctx context.Context, alert *Alert) error
+}
+
+// Alert is structured alert
+type Alert struct {
+	Severity Severity          `json:"severity"`
+	Title    string            `json:"title,omitempty"`
+	Service  string            `json:"service,omitempty"`
+	Host     string            `json:"host,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Fields   []Field           `json:"fields,omitempty"`
+	Message  string            `json:"message"`
+	Stack    string            `json:"stack,omitempty"`
+	Time     time.Time         `json:"time"`
+}
+
+// Field is named value shown in alert
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Label returns label value or empty string
+func (a *Alert) Label(name string) string {
+	return a.Labels[name]
+}
+
+// VisibleLabels returns labels without the ones used for delivery and deduplication
+func (a *Alert) VisibleLabels() map[string]string {
+	var result map[string]string
+
+	for name, value := range a.Labels {
+		if name == LabelChat || name == LabelFingerprint || name == LabelStatus {
+			continue
+		}
+
+		if result == nil {
+			result = make(map[string]string, len(a.Labels))
+		}
+
+		result[name] = value
+	}
+
+	return result
+}
+
+// Adapt wraps Alerter into AlerterV2, alerts are posted with infoLevel,
+// empty infoLevel means chat label or severity name
+func Adapt(a Alerter, infoLevel string) AlerterV2 {
+	return &adapter{alerter: a, infoLevel: infoLevel}
+}
+
+type adapter struct {
+	alerter   Alerter
+	infoLevel string
+}
+
+func (a *adapter) Send(ctx context.Context, alert *Alert) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	infoLevel := a.infoLevel
+	if infoLevel == "" {
+		if infoLevel = alert.Label(LabelChat); infoLevel == "" {
+			infoLevel = alert.Severity.String()
+		}
+	}
+
+	return a.alerter.PostMessage(alert.Message, infoLevel)
+}
+
+// Legacy wraps AlerterV2 into Alerter. infoLevel is parsed as severity,
+// unknown ones are sent with SeverityInfo and chat label set to infoLevel
+func Legacy(a AlerterV2) Alerter {
+	return legacy{a}
+}
+
+type legacy struct {
+	alerter AlerterV2
+}
+
+func (l legacy) PostMessage(message string, infoLevel string) error {
+	return l.alerter.Send(context.Background(), NewAlert(message, infoLevel))
+}
+
+// NewAlert creates alert from the old-style message and infoLevel
+func NewAlert(message string, infoLevel string) *Alert {
+	alert := &Alert{Message: message, Time: time.Now()}
+
+	severity, err := ParseSeverity(infoLevel)
+	if err != nil {
+		alert.Severity = SeverityInfo
+		alert.Labels = map[string]string{LabelChat: infoLevel}
+	} else {
+		alert.Severity = severity
+	}
+
+	return alert
+}
@@ -0,0 +1,307 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Overflow policy of the full dispatcher queue
+type Overflow int
+
+// Overflow policies
+const (
+	// OverflowDrop drops the message and returns ErrQueueFull
+	OverflowDrop Overflow = iota
+	// OverflowBlock blocks the caller until there is free space in the queue
+	OverflowBlock
+)
+
+// Dispatcher defaults
+const (
+	DefaultQueueSize  = 1000
+	DefaultWorkers    = 2
+	DefaultRetries    = 3
+	DefaultBackoff    = time.Second
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+var (
+	// ErrQueueFull returned when message is dropped because of full queue
+	ErrQueueFull = errors.New("alerts: queue is full")
+	// ErrClosed returned when message is posted to closed dispatcher
+	ErrClosed = errors.New("alerts: dispatcher is closed")
+)
+
+// DispatcherConfig describes dispatcher
+type DispatcherConfig struct {
+	QueueSize int
+	Workers   int
+	// Retries number of retries of a failed message, negative disables them
+	Retries int
+	// Backoff delay before the first retry, doubled for every next one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// CoalesceWindow identical messages posted within the window after the first one
+	// are sent once in the end of the window as a summary, 0 disables coalescing
+	CoalesceWindow time.Duration
+	Overflow       Overflow
+}
+
+type queuedMessage struct {
+	message, infoLevel string
+}
+
+type coalesced struct {
+	count int
+	timer *time.Timer
+}
+
+// Dispatcher delivers messages of the wrapped Alerter asynchronously
+type Dispatcher struct {
+	alerter Alerter
+	config  DispatcherConfig
+	queue   chan queuedMessage
+	stop    chan struct{}
+	workers sync.WaitGroup
+
+	sync.Mutex
+	coalescing map[queuedMessage]*coalesced
+	// closing rejects new messages while Close flushes, closed rejects summaries too
+	closing, closed bool
+
+	pending, dropped, failed int64
+}
+
+// NewDispatcher creates dispatcher and starts its workers
+func NewDispatcher(alerter Alerter, config DispatcherConfig) *Dispatcher {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+
+	if config.Workers <= 0 {
+		config.Workers = DefaultWorkers
+	}
+
+	if config.Retries == 0 {
+		config.Retries = DefaultRetries
+	}
+
+	if config.Backoff <= 0 {
+		config.Backoff = DefaultBackoff
+	}
+
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+
+	d := &Dispatcher{
+		alerter:    alerter,
+		config:     config,
+		queue:      make(chan queuedMessage, config.QueueSize),
+		stop:       make(chan struct{}),
+		coalescing: make(map[queuedMessage]*coalesced),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		d.workers.Add(1)
+		go d.work()
+	}
+
+	return d
+}
+
+// PostMessage queues message, it is Alerter implementation
+func (d *Dispatcher) PostMessage(message string, infoLevel string) error {
+	m := queuedMessage{message: message, infoLevel: infoLevel}
+
+	if d.config.CoalesceWindow > 0 {
+		d.Lock()
+
+		if d.closing {
+			d.Unlock()
+			return ErrClosed
+		}
+
+		if c, ok := d.coalescing[m]; ok {
+			c.count++
+			d.Unlock()
+
+			return nil
+		}
+
+		d.coalescing[m] = &coalesced{count: 1, timer: time.AfterFunc(d.config.CoalesceWindow, func() {
+			d.flushCoalesced(m)
+		})}
+
+		d.Unlock()
+	}
+
+	return d.enqueue(m, false)
+}
+
+// enqueue queues the message, summaries are still accepted while Close flushes
+func (d *Dispatcher) enqueue(m queuedMessage, summary bool) error {
+	d.Lock()
+	if d.closed || (d.closing && !summary) {
+		d.Unlock()
+		return ErrClosed
+	}
+
+	atomic.AddInt64(&d.pending, 1)
+	d.Unlock()
+
+	if d.config.Overflow == OverflowBlock {
+		select {
+		case d.queue <- m:
+			return nil
+		case <-d.stop:
+			atomic.AddInt64(&d.pending, -1)
+			return ErrClosed
+		}
+	}
+
+	select {
+	case d.queue <- m:
+		return nil
+	default:
+		atomic.AddInt64(&d.pending, -1)
+		atomic.AddInt64(&d.dropped, 1)
+
+		return ErrQueueFull
+	}
+}
+
+// flushCoalesced ends coalescing window of the message and queues summary of repeats
+func (d *Dispatcher) flushCoalesced(m queuedMessage) {
+	d.Lock()
+
+	c, ok := d.coalescing[m]
+	if ok {
+		delete(d.coalescing, m)
+		c.timer.Stop()
+	}
+
+	d.Unlock()
+
+	if ok && c.count > 1 {
+		summary := fmt.Sprintf("%s\nx%d in last %s", m.message, c.count, ShortDuration(d.config.CoalesceWindow))
+		if err := d.enqueue(queuedMessage{message: summary, infoLevel: m.infoLevel}, true); err != nil {
+			log.Printf("[Alerts] Error queueing summary %v", err)
+		}
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.workers.Done()
+
+	for {
+		select {
+		case m := <-d.queue:
+			d.send(m)
+			atomic.AddInt64(&d.pending, -1)
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) send(m queuedMessage) {
+	backoff := d.config.Backoff
+
+	for attempt := 0; ; attempt++ {
+		err := d.alerter.PostMessage(m.message, m.infoLevel)
+		if err == nil {
+			return
+		}
+
+		if attempt >= d.config.Retries {
+			atomic.AddInt64(&d.failed, 1)
+			log.Printf("[Alerts] Error posting message after %d attempts %v", attempt+1, err)
+
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-d.stop:
+			return
+		}
+
+		if backoff *= 2; backoff > d.config.MaxBackoff {
+			backoff = d.config.MaxBackoff
+		}
+	}
+}
+
+// Flush sends coalesced summaries immediately and waits until all queued messages are delivered
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	d.Lock()
+	keys := make([]queuedMessage, 0, len(d.coalescing))
+	for m := range d.coalescing {
+		keys = append(keys, m)
+	}
+	d.Unlock()
+
+	for _, m := range keys {
+		d.flushCoalesced(m)
+	}
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for atomic.LoadInt64(&d.pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// Close flushes the dispatcher and stops its workers, messages posted after Close are rejected
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.Lock()
+	d.closing = true
+	d.Unlock()
+
+	err := d.Flush(ctx)
+
+	d.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.stop)
+	}
+	d.Unlock()
+
+	d.workers.Wait()
+
+	return err
+}
+
+// Stats returns number of queued, dropped and failed messages
+func (d *Dispatcher) Stats() (pending, dropped, failed int64) {
+	return atomic.LoadInt64(&d.pending), atomic.LoadInt64(&d.dropped), atomic.LoadInt64(&d.failed)
+}
+
+// ShortDuration formats duration without zero units: 5m instead of 5m0s
+func ShortDuration(d time.Duration) string {
+	s := d.String()
+
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+
+	return s
+}
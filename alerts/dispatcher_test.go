@@ -0,0 +1,136 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeAlerter struct {
+	sync.Mutex
+	messages []string
+	fails    int
+}
+
+func (f *fakeAlerter) PostMessage(message string, infoLevel string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.fails > 0 {
+		f.fails--
+		return errors.New("fail")
+	}
+
+	f.messages = append(f.messages, message)
+
+	return nil
+}
+
+func (f *fakeAlerter) sent() []string {
+	f.Lock()
+	defer f.Unlock()
+
+	return append([]string(nil), f.messages...)
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	a := &fakeAlerter{fails: 2}
+	d := NewDispatcher(a, DispatcherConfig{Backoff: time.Millisecond})
+
+	if err := d.PostMessage("hello", "info"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent := a.sent(); len(sent) != 1 || sent[0] != "hello" {
+		t.Fatalf("unexpected messages %v", sent)
+	}
+
+	if err := d.PostMessage("late", "info"); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestDispatcherCoalesce(t *testing.T) {
+	a := &fakeAlerter{}
+	d := NewDispatcher(a, DispatcherConfig{CoalesceWindow: 5 * time.Minute})
+
+	for i := 0; i < 37; i++ {
+		d.PostMessage("disk full", "error")
+	}
+
+	d.PostMessage("other", "error")
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := a.sent()
+	if len(sent) != 3 {
+		t.Fatalf("expected 3 messages, got %v", sent)
+	}
+
+	found := false
+	for _, m := range sent {
+		if strings.HasSuffix(m, "x37 in last 5m") {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Fatalf("summary not found in %v", sent)
+	}
+	for i := 0; i < 2; i++ {
+		if err := d.PostMessage("disk full", "error"); err != ErrClosed {
+			t.Fatalf("expected ErrClosed for coalesced message after Close, got %v", err)
+		}
+	}
+}
+
+func TestDispatcherDrop(t *testing.T) {
+	block := make(chan struct{})
+	a := &blockingAlerter{block: block}
+	d := NewDispatcher(a, DispatcherConfig{QueueSize: 1, Workers: 1})
+
+	var dropped bool
+	for i := 0; i < 5; i++ {
+		if d.PostMessage("m", "info") == ErrQueueFull {
+			dropped = true
+		}
+	}
+
+	close(block)
+	d.Close(context.Background())
+
+	if _, n, _ := d.Stats(); !dropped || n == 0 {
+		t.Fatal("expected dropped messages")
+	}
+}
+
+type blockingAlerter struct {
+	block chan struct{}
+}
+
+func (b *blockingAlerter) PostMessage(message string, infoLevel string) error {
+	<-b.block
+	return nil
+}
+
+func TestShortDuration(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		5 * time.Minute:  "5m",
+		time.Hour:        "1h",
+		90 * time.Second: "1m30s",
+		time.Second:      "1s",
+	} {
+		if s := ShortDuration(d); s != expected {
+			t.Errorf("%v: expected %s, got %s", d, expected, s)
+		}
+	}
+}
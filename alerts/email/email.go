@@ -0,0 +1,318 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finnan444/utils/alerts"
+)
+
+// TLS modes
+const (
+	// TLSStart upgrades connection with STARTTLS, it's the default
+	TLSStart = "starttls"
+	// TLSImplicit connects with TLS, usually to port 465
+	TLSImplicit = "tls"
+	// TLSNone sends mail without encryption
+	TLSNone = "none"
+)
+
+const maxSubjectRunes = 100
+
+// Config describes email config
+type Config struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+	// Recipients addresses by info level, see telegram.Config.Chats
+	Recipients    map[string][]string `json:"recipients"`
+	TLS           string              `json:"tls"`
+	HTML          bool                `json:"html"`
+	SubjectPrefix string              `json:"subjectPrefix"`
+	// Template and HTMLTemplate of plain-text and HTML bodies, see alerts.DefaultTemplateText
+	Template     string `json:"template"`
+	HTMLTemplate string `json:"htmlTemplate"`
+}
+
+// Alert is email structure
+type Alert struct {
+	config       *Config
+	timeout      time.Duration
+	template     *alerts.Template
+	htmlTemplate *alerts.Template
+}
+
+// InitEmail initialize email
+func InitEmail(config *Config, connectionTimeoutSeconds time.Duration) *Alert {
+	return &Alert{
+		timeout:      connectionTimeoutSeconds * time.Second,
+		config:       config,
+		template:     parseTemplate(alerts.FormatPlain, config.Template),
+		htmlTemplate: parseTemplate(alerts.FormatHTML, config.HTMLTemplate),
+	}
+}
+
+func parseTemplate(format alerts.Format, text string) *alerts.Template {
+	template, err := alerts.TemplateFor(format, text)
+	if err != nil {
+		log.Printf("[Email] Error parsing %s template, default is used %v", format, err)
+		return alerts.DefaultTemplate(format)
+	}
+
+	return template
+}
+
+// PostMessage mails message to the recipients of infoLevel
+func (mngr *Alert) PostMessage(message string, infoLevel string) error {
+	return mngr.Send(context.Background(), &alerts.Alert{
+		Message: message,
+		Labels:  map[string]string{alerts.LabelChat: infoLevel},
+	})
+}
+
+// Send mails alert to the recipients of its chat label or severity name
+func (mngr *Alert) Send(ctx context.Context, alert *alerts.Alert) error {
+	infoLevel := alert.Label(alerts.LabelChat)
+	if infoLevel == "" {
+		infoLevel = alert.Severity.String()
+	}
+
+	to := mngr.config.Recipients[infoLevel]
+	if len(to) == 0 {
+		log.Printf("[Email] Unknown info level %s", infoLevel)
+		return fmt.Errorf("[Email] Unknown info level %s", infoLevel)
+	}
+
+	text, err := mngr.template.Render(alert)
+	if err != nil {
+		log.Printf("[Email] Error rendering alert %v", err)
+		return err
+	}
+
+	var htmlBody string
+	if mngr.config.HTML {
+		if htmlBody, err = mngr.htmlTemplate.Render(alert); err != nil {
+			log.Printf("[Email] Error rendering alert %v", err)
+			return err
+		}
+
+		htmlBody = `<div style="white-space:pre-wrap">` + htmlBody + "</div>"
+	}
+
+	msg, err := buildMessage(mngr.config.From, to, mngr.subject(alert), text, htmlBody, time.Now())
+	if err != nil {
+		log.Printf("[Email] Error building message %v", err)
+		return err
+	}
+
+	if err = mngr.send(ctx, to, msg); err != nil {
+		log.Printf("[Email] error sending message %v", err)
+	}
+
+	return err
+}
+
+// subject is prefix, severity, service and title or the first line of message
+func (mngr *Alert) subject(alert *alerts.Alert) string {
+	var b strings.Builder
+
+	if mngr.config.SubjectPrefix != "" {
+		b.WriteString(mngr.config.SubjectPrefix)
+		b.WriteByte(' ')
+	}
+
+	b.WriteString("[" + strings.ToUpper(alert.Severity.String()) + "] ")
+
+	if alert.Service != "" {
+		b.WriteString(alert.Service + ": ")
+	}
+
+	line := alert.Title
+	if line == "" {
+		line = alert.Message
+	}
+
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+
+	if runes := []rune(line); len(runes) > maxSubjectRunes {
+		line = string(runes[:maxSubjectRunes]) + "…"
+	}
+
+	b.WriteString(line)
+
+	return b.String()
+}
+
+// buildMessage builds RFC 5322 message, it is multipart/alternative when htmlBody is not empty
+func buildMessage(from string, to []string, subject, text, htmlBody string, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if htmlBody == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuoted(&buf, text); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", htmlBody},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err = writeQuoted(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeQuoted(w io.Writer, s string) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write([]byte(s)); err != nil {
+		return err
+	}
+
+	return qw.Close()
+}
+
+// envelope returns bare addresses of sender and recipients for MAIL FROM and RCPT TO,
+// config values may be in the header form, e.g. "Alerts <alerts@example.com>"
+func envelope(from string, to []string) (string, []string, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return "", nil, fmt.Errorf("[Email] invalid sender %q: %v", from, err)
+	}
+
+	recipients := make([]string, len(to))
+	for i, addr := range to {
+		rcpt, err := mail.ParseAddress(addr)
+		if err != nil {
+			return "", nil, fmt.Errorf("[Email] invalid recipient %q: %v", addr, err)
+		}
+
+		recipients[i] = rcpt.Address
+	}
+
+	return sender.Address, recipients, nil
+}
+
+func (mngr *Alert) send(ctx context.Context, to []string, msg []byte) error {
+	config := mngr.config
+
+	from, to, err := envelope(config.From, to)
+	if err != nil {
+		return err
+	}
+
+	port := config.Port
+	if port == 0 {
+		if config.TLS == TLSImplicit {
+			port = 465
+		} else {
+			port = 587
+		}
+	}
+
+	timeout := alerts.Timeout(ctx, mngr.timeout)
+	dialer := &net.Dialer{Timeout: timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(config.Host, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if config.TLS == TLSImplicit {
+		conn = tls.Client(conn, &tls.Config{ServerName: config.Host})
+	}
+
+	c, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if config.TLS != TLSImplicit && config.TLS != TLSNone {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("[Email] %s doesn't support STARTTLS", config.Host)
+		}
+
+		if err = c.StartTLS(&tls.Config{ServerName: config.Host}); err != nil {
+			return err
+		}
+	}
+
+	if config.Username != "" {
+		if err = c.Auth(smtp.PlainAuth("", config.Username, config.Password, config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err = c.Mail(from); err != nil {
+		return err
+	}
+
+	for _, addr := range to {
+		if err = c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
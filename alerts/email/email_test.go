@@ -0,0 +1,135 @@
+package email
+
+import (
+	"context"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/alerts"
+)
+
+func TestBuildMessage(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	msg, err := buildMessage("bot@example.com", []string{"a@example.com", "b@example.com"}, "Диск заполнен", "line 1\nline 2", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(string(msg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject")); subject != "Диск заполнен" {
+		t.Fatalf("unexpected subject %q", subject)
+	}
+
+	if to := m.Header.Get("To"); to != "a@example.com, b@example.com" {
+		t.Fatalf("unexpected recipients %q", to)
+	}
+
+	body, _ := ioutil.ReadAll(m.Body)
+	if string(body) != "line 1\r\nline 2" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestBuildMessageHTML(t *testing.T) {
+	msg, err := buildMessage("bot@example.com", []string{"a@example.com"}, "s", "a < b", "<pre>a &lt; b</pre>", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(msg)
+	if !strings.Contains(s, "multipart/alternative") || !strings.Contains(s, "text/html") || !strings.Contains(s, "a &lt; b") {
+		t.Fatalf("unexpected message %s", s)
+	}
+}
+
+func TestSubject(t *testing.T) {
+	a := InitEmail(&Config{SubjectPrefix: "[prod]"}, 1)
+	subject := a.subject(&alerts.Alert{Severity: alerts.SeverityError, Service: "api", Message: "db down\nstack"})
+
+	if subject != "[prod] [ERROR] api: db down" {
+		t.Fatalf("unexpected subject %q", subject)
+	}
+}
+
+func TestSendEnvelope(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	commands := make(chan []string, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 test")
+
+		var received []string
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			switch cmd := strings.ToUpper(strings.Fields(line + " ")[0]); cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 test")
+			case "MAIL", "RCPT":
+				received = append(received, line)
+				tp.PrintfLine("250 ok")
+			case "DATA":
+				tp.PrintfLine("354 go on")
+				tp.ReadDotBytes()
+				tp.PrintfLine("250 ok")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				commands <- received
+				return
+			default:
+				tp.PrintfLine("502 unknown")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	a := InitEmail(&Config{
+		Host:       host,
+		Port:       portNumber,
+		TLS:        TLSNone,
+		From:       "Alerts <alerts@example.com>",
+		Recipients: map[string][]string{"critical": {"Ops Team <ops@example.com>", "dev@example.com"}},
+	}, 1)
+
+	if err := a.Send(context.Background(), &alerts.Alert{Severity: alerts.SeverityCritical, Message: "down"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "MAIL FROM:<alerts@example.com>,RCPT TO:<ops@example.com>,RCPT TO:<dev@example.com>"
+	if got := strings.Join(<-commands, ","); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+
+	if _, _, err := envelope("not an address", nil); err == nil {
+		t.Fatal("expected error of invalid sender")
+	}
+}
@@ -0,0 +1,94 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MultiError aggregates errors of several destinations
+type MultiError []error
+
+func (m MultiError) Error() string {
+	parts := make([]string, len(m))
+	for i, err := range m {
+		parts[i] = err.Error()
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// errorOrNil returns nil for empty MultiError
+func (m MultiError) errorOrNil() error {
+	if len(m) == 0 {
+		return nil
+	}
+
+	return m
+}
+
+// FanOut sends alerts to all destinations concurrently, it is Alerter and AlerterV2 implementation
+type FanOut struct {
+	names        []string
+	destinations []AlerterV2
+}
+
+// NewFanOut creates fan-out alerter, destinations are named by their type in errors, e.g. *slack.Alert
+func NewFanOut(destinations ...AlerterV2) *FanOut {
+	names := make([]string, len(destinations))
+	for i, d := range destinations {
+		names[i] = fmt.Sprintf("%T", d)
+	}
+
+	return &FanOut{names: names, destinations: destinations}
+}
+
+// NewNamedFanOut creates fan-out alerter of named destinations like NewRouter does
+func NewNamedFanOut(destinations map[string]AlerterV2) *FanOut {
+	f := &FanOut{}
+	for name := range destinations {
+		f.names = append(f.names, name)
+	}
+
+	sort.Strings(f.names)
+
+	for _, name := range f.names {
+		f.destinations = append(f.destinations, destinations[name])
+	}
+
+	return f
+}
+
+// PostMessage sends alert made by NewAlert
+func (f *FanOut) PostMessage(message string, infoLevel string) error {
+	return f.Send(context.Background(), NewAlert(message, infoLevel))
+}
+
+// Send sends alert to all destinations and returns MultiError of failed ones
+func (f *FanOut) Send(ctx context.Context, alert *Alert) error {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		result MultiError
+	)
+
+	for i, d := range f.destinations {
+		wg.Add(1)
+
+		go func(name string, d AlerterV2) {
+			defer wg.Done()
+
+			if err := d.Send(ctx, alert); err != nil {
+				mu.Lock()
+				result = append(result, fmt.Errorf("%s: %v", name, err))
+				mu.Unlock()
+			}
+		}(f.names[i], d)
+	}
+
+	wg.Wait()
+
+	return result.errorOrNil()
+}
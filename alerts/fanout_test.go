@@ -0,0 +1,38 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type failingAlerter struct{}
+
+func (failingAlerter) Send(ctx context.Context, alert *Alert) error {
+	return errors.New("boom")
+}
+
+func TestFanOut(t *testing.T) {
+	a, b := &recordingAlerter{}, &recordingAlerter{}
+	f := NewFanOut(a, failingAlerter{}, b, failingAlerter{})
+
+	err := f.Send(context.Background(), &Alert{Message: "m"})
+
+	multi, ok := err.(MultiError)
+	if !ok || len(multi) != 2 || multi.Error() != "alerts.failingAlerter: boom; alerts.failingAlerter: boom" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(a.alerts) != 1 || len(b.alerts) != 1 {
+		t.Fatal("alert is not sent to all destinations")
+	}
+
+	if err := NewFanOut(a).PostMessage("m", "info"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	err = NewNamedFanOut(map[string]AlerterV2{"ok": a, "pager": failingAlerter{}}).Send(context.Background(), &Alert{Message: "m"})
+	if err == nil || err.Error() != "pager: boom" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
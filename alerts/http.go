@@ -0,0 +1,56 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+)
+
+// Timeout returns timeout limited by deadline of ctx
+func Timeout(ctx context.Context, timeout time.Duration) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline); left < timeout {
+			return left
+		}
+	}
+
+	return timeout
+}
+
+// PostJSON posts body to url, non 2xx responses are errors
+func PostJSON(ctx context.Context, url string, body []byte, headers map[string]string, timeout time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(url)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType(transport.ApplicationJSON)
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	req.SetBody(body)
+
+	client := transport.GetHTTPClient()
+	err := client.DoTimeout(req, resp, Timeout(ctx, timeout))
+	transport.PutHTTPClient(client)
+
+	if err != nil {
+		return err
+	}
+
+	if sc := resp.StatusCode(); sc < 200 || sc >= 300 {
+		return fmt.Errorf("alerts: %s responded %d %s", req.URI().Host(), sc, resp.Body())
+	}
+
+	return nil
+}
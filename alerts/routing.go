@@ -0,0 +1,239 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule routes matching alerts to destinations
+type Rule struct {
+	// MinSeverity lowest matching severity
+	MinSeverity Severity `json:"minSeverity"`
+	// Services matching alerts, empty matches any service
+	Services []string `json:"services,omitempty"`
+	// Labels must be equal to the alert labels, "*" matches any present value
+	Labels       map[string]string `json:"labels,omitempty"`
+	Destinations []string          `json:"destinations"`
+	// Continue evaluates following rules after the match
+	Continue bool `json:"continue,omitempty"`
+}
+
+// QuietHours suppresses alerts of destination during the period
+type QuietHours struct {
+	// Start and End are "15:04" times, the period may wrap midnight
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Timezone IANA name, UTC by default
+	Timezone string `json:"timezone,omitempty"`
+	// Bypass alerts of this severity or higher are not suppressed, critical by default
+	Bypass *Severity `json:"bypass,omitempty"`
+
+	start, end int
+	location   *time.Location
+	bypass     Severity
+}
+
+// RoutingConfig describes routing of alerts
+type RoutingConfig struct {
+	Rules []Rule `json:"rules"`
+	// Default destinations of alerts not matched by any rule
+	Default    []string              `json:"default,omitempty"`
+	QuietHours map[string]QuietHours `json:"quietHours,omitempty"`
+}
+
+// Router sends alerts to destinations using rules, it is AlerterV2 implementation
+type Router struct {
+	rules        []Rule
+	defaults     []string
+	quiet        map[string]*QuietHours
+	destinations map[string]AlerterV2
+}
+
+// NewRouter creates router, all destinations used by config must be present
+func NewRouter(config *RoutingConfig, destinations map[string]AlerterV2) (*Router, error) {
+	r := &Router{
+		rules:        config.Rules,
+		defaults:     config.Default,
+		quiet:        make(map[string]*QuietHours, len(config.QuietHours)),
+		destinations: destinations,
+	}
+
+	names := append([]string(nil), config.Default...)
+	for _, rule := range config.Rules {
+		names = append(names, rule.Destinations...)
+	}
+
+	for _, name := range names {
+		if _, ok := destinations[name]; !ok {
+			return nil, fmt.Errorf("alerts: unknown destination %s", name)
+		}
+	}
+
+	for name, qh := range config.QuietHours {
+		if _, ok := destinations[name]; !ok {
+			return nil, fmt.Errorf("alerts: quiet hours of unknown destination %s", name)
+		}
+
+		if err := qh.init(); err != nil {
+			return nil, fmt.Errorf("alerts: quiet hours of %s: %v", name, err)
+		}
+
+		q := qh
+		r.quiet[name] = &q
+	}
+
+	return r, nil
+}
+
+func parseClock(s string) (int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+
+	h, err := strconv.Atoi(parts[0])
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+
+	m, err := strconv.Atoi(parts[1])
+	if err != nil || m < 0 || m > 59 {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+
+	return h*60 + m, nil
+}
+
+func (qh *QuietHours) init() (err error) {
+	if qh.start, err = parseClock(qh.Start); err != nil {
+		return err
+	}
+
+	if qh.end, err = parseClock(qh.End); err != nil {
+		return err
+	}
+
+	if qh.location, err = time.LoadLocation(qh.Timezone); err != nil {
+		return err
+	}
+
+	qh.bypass = SeverityCritical
+	if qh.Bypass != nil {
+		qh.bypass = *qh.Bypass
+	}
+
+	return nil
+}
+
+// Suppresses reports whether alert of severity at t falls into quiet hours
+func (qh *QuietHours) Suppresses(severity Severity, t time.Time) bool {
+	if severity >= qh.bypass {
+		return false
+	}
+
+	t = t.In(qh.location)
+	m := t.Hour()*60 + t.Minute()
+
+	if qh.start <= qh.end {
+		return m >= qh.start && m < qh.end
+	}
+
+	return m >= qh.start || m < qh.end
+}
+
+// Match reports whether alert matches the rule
+func (rule *Rule) Match(alert *Alert) bool {
+	if alert.Severity < rule.MinSeverity {
+		return false
+	}
+
+	if len(rule.Services) > 0 {
+		found := false
+		for _, service := range rule.Services {
+			if service == alert.Service {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	for name, value := range rule.Labels {
+		actual, ok := alert.Labels[name]
+		if !ok || value != "*" && value != actual {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Route returns destinations of the alert excluding ones in quiet hours
+func (r *Router) Route(alert *Alert) []string {
+	var (
+		result  []string
+		seen    = make(map[string]bool)
+		matched bool
+		t       = alert.Time
+	)
+
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	add := func(names []string) {
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+
+			seen[name] = true
+
+			if qh, ok := r.quiet[name]; ok && qh.Suppresses(alert.Severity, t) {
+				continue
+			}
+
+			result = append(result, name)
+		}
+	}
+
+	for i := range r.rules {
+		if !r.rules[i].Match(alert) {
+			continue
+		}
+
+		matched = true
+		add(r.rules[i].Destinations)
+
+		if !r.rules[i].Continue {
+			break
+		}
+	}
+
+	if !matched {
+		add(r.defaults)
+	}
+
+	return result
+}
+
+// Send sends alert to all its destinations and returns MultiError of failed ones
+func (r *Router) Send(ctx context.Context, alert *Alert) error {
+	var result MultiError
+
+	for _, name := range r.Route(alert) {
+		if err := r.destinations[name].Send(ctx, alert); err != nil {
+			log.Printf("[Alerts] Error sending alert to %s %v", name, err)
+			result = append(result, fmt.Errorf("%s: %v", name, err))
+		}
+	}
+
+	return result.errorOrNil()
+}
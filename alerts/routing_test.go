@@ -0,0 +1,101 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type recordingAlerter struct {
+	alerts []*Alert
+}
+
+func (r *recordingAlerter) Send(ctx context.Context, alert *Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+const routingConfig = `{
+	"rules": [
+		{"minSeverity": "critical", "destinations": ["pager"], "continue": true},
+		{"services": ["billing"], "labels": {"team": "*"}, "destinations": ["billing"]},
+		{"minSeverity": "warning", "destinations": ["ops"]}
+	],
+	"default": ["log"],
+	"quietHours": {
+		"ops": {"start": "22:00", "end": "08:00", "timezone": "Europe/Moscow"}
+	}
+}`
+
+func newTestRouter(t *testing.T) *Router {
+	config := &RoutingConfig{}
+	if err := json.Unmarshal([]byte(routingConfig), config); err != nil {
+		t.Fatal(err)
+	}
+
+	destinations := map[string]AlerterV2{}
+	for _, name := range []string{"pager", "billing", "ops", "log"} {
+		destinations[name] = &recordingAlerter{}
+	}
+
+	r, err := NewRouter(config, destinations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestRoute(t *testing.T) {
+	r := newTestRouter(t)
+	noon := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)   // 12:00 in Moscow
+	night := time.Date(2020, 1, 1, 21, 0, 0, 0, time.UTC) // 00:00 in Moscow
+
+	for i, test := range []struct {
+		alert    Alert
+		expected []string
+	}{
+		{Alert{Severity: SeverityCritical, Time: noon}, []string{"pager", "ops"}},
+		{Alert{Severity: SeverityCritical, Time: night}, []string{"pager", "ops"}},
+		{Alert{Severity: SeverityError, Time: night}, nil},
+		{Alert{Severity: SeverityError, Time: noon}, []string{"ops"}},
+		{Alert{Severity: SeverityDebug, Service: "billing", Labels: map[string]string{"team": "a"}}, []string{"billing"}},
+		{Alert{Severity: SeverityDebug, Service: "billing"}, []string{"log"}},
+		{Alert{Severity: SeverityInfo}, []string{"log"}},
+	} {
+		routes := r.Route(&test.alert)
+		if len(routes) != len(test.expected) {
+			t.Fatalf("%d: expected %v, got %v", i, test.expected, routes)
+		}
+
+		for j := range routes {
+			if routes[j] != test.expected[j] {
+				t.Fatalf("%d: expected %v, got %v", i, test.expected, routes)
+			}
+		}
+	}
+}
+
+func TestUnknownDestination(t *testing.T) {
+	_, err := NewRouter(&RoutingConfig{Default: []string{"missing"}}, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLegacy(t *testing.T) {
+	rec := &recordingAlerter{}
+	a := Legacy(rec)
+
+	a.PostMessage("one", "warn")
+	a.PostMessage("two", "-100500")
+
+	if rec.alerts[0].Severity != SeverityWarning || rec.alerts[0].Label(LabelChat) != "" {
+		t.Fatalf("unexpected alert %+v", rec.alerts[0])
+	}
+
+	if rec.alerts[1].Severity != SeverityInfo || rec.alerts[1].Label(LabelChat) != "-100500" {
+		t.Fatalf("unexpected alert %+v", rec.alerts[1])
+	}
+}
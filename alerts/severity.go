@@ -0,0 +1,58 @@
+package alerts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity of alert
+type Severity int
+
+// Severities
+const (
+	SeverityDebug Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+	SeverityCritical
+)
+
+var severityNames = []string{"debug", "info", "warning", "error", "critical"}
+
+// String returns lowercase name of severity
+func (s Severity) String() string {
+	if s < SeverityDebug || s > SeverityCritical {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+
+	return severityNames[s]
+}
+
+// ParseSeverity parses severity name, warn and crit are accepted as well
+func ParseSeverity(name string) (Severity, error) {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case "warn":
+		return SeverityWarning, nil
+	case "crit", "fatal":
+		return SeverityCritical, nil
+	}
+
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+
+	return SeverityDebug, fmt.Errorf("alerts: unknown severity %q", name)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *Severity) UnmarshalText(text []byte) (err error) {
+	*s, err = ParseSeverity(string(text))
+	return err
+}
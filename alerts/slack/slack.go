@@ -0,0 +1,93 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/finnan444/utils/alerts"
+)
+
+// Config describes slack config
+type Config struct {
+	// Webhooks incoming webhook urls by info level, see telegram.Config.Chats
+	Webhooks  map[string]string `json:"webhooks"`
+	Username  string            `json:"username"`
+	IconEmoji string            `json:"iconEmoji"`
+	// Template of alerts in mrkdwn, see alerts.DefaultTemplateText
+	Template string `json:"template"`
+}
+
+// Alert is Slack structure
+type Alert struct {
+	config   *Config
+	timeout  time.Duration
+	template *alerts.Template
+}
+
+type message struct {
+	Text      string `json:"text"`
+	Username  string `json:"username,omitempty"`
+	IconEmoji string `json:"icon_emoji,omitempty"`
+}
+
+// InitSlack initialize Slack
+func InitSlack(config *Config, connectionTimeoutSeconds time.Duration) *Alert {
+	template, err := alerts.TemplateFor(alerts.FormatSlack, config.Template)
+	if err != nil {
+		log.Printf("[Slack] Error parsing template, default is used %v", err)
+		template = alerts.DefaultTemplate(alerts.FormatSlack)
+	}
+
+	return &Alert{
+		timeout:  connectionTimeoutSeconds * time.Second,
+		config:   config,
+		template: template,
+	}
+}
+
+// PostMessage posts message to the webhook of infoLevel
+func (mngr *Alert) PostMessage(message string, infoLevel string) error {
+	return mngr.Send(context.Background(), &alerts.Alert{
+		Message: message,
+		Labels:  map[string]string{alerts.LabelChat: infoLevel},
+	})
+}
+
+// Send posts alert to the webhook of its chat label or severity name
+func (mngr *Alert) Send(ctx context.Context, alert *alerts.Alert) error {
+	infoLevel := alert.Label(alerts.LabelChat)
+	if infoLevel == "" {
+		infoLevel = alert.Severity.String()
+	}
+
+	url, ok := mngr.config.Webhooks[infoLevel]
+	if !ok || url == "" {
+		log.Printf("[Slack] Unknown info level %s", infoLevel)
+		return fmt.Errorf("[Slack] Unknown info level %s", infoLevel)
+	}
+
+	text, err := mngr.template.Render(alert)
+	if err != nil {
+		log.Printf("[Slack] Error rendering alert %v", err)
+		return err
+	}
+
+	body, err := json.Marshal(&message{
+		Text:      text,
+		Username:  mngr.config.Username,
+		IconEmoji: mngr.config.IconEmoji,
+	})
+	if err != nil {
+		log.Printf("[Slack] Error marshaling request %v", err)
+		return err
+	}
+
+	if err = alerts.PostJSON(ctx, url, body, nil, mngr.timeout); err != nil {
+		log.Printf("[Slack] error posting message %v", err)
+	}
+
+	return err
+}
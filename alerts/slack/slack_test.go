@@ -0,0 +1,55 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/finnan444/utils/alerts"
+	"github.com/valyala/fasthttp"
+)
+
+func TestSend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan *message, 1)
+
+	go fasthttp.Serve(ln, func(ctx *fasthttp.RequestCtx) {
+		if string(ctx.Path()) != "/hooks/critical" {
+			ctx.SetStatusCode(fasthttp.StatusNotFound)
+			return
+		}
+
+		msg := &message{}
+		json.Unmarshal(ctx.PostBody(), msg)
+		received <- msg
+	})
+
+	url := "http://" + ln.Addr().String() + "/hooks/"
+	a := InitSlack(&Config{
+		Webhooks:  map[string]string{"critical": url + "critical", "info": url + "missing"},
+		Username:  "alerts",
+		IconEmoji: ":fire:",
+	}, 1)
+
+	if err := a.Send(context.Background(), &alerts.Alert{Severity: alerts.SeverityCritical, Message: "down"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := <-received; msg.Text != "down" || msg.Username != "alerts" || msg.IconEmoji != ":fire:" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	if err := a.PostMessage("down", "info"); err == nil {
+		t.Fatal("expected error of failed webhook")
+	}
+
+	if err := a.PostMessage("down", "unknown"); err == nil {
+		t.Fatal("expected error of unknown info level")
+	}
+}
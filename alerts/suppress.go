@@ -0,0 +1,475 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Labels set by Suppressor
+const (
+	// LabelFingerprint overrides computed fingerprint of alert
+	LabelFingerprint = "fingerprint"
+	// LabelStatus is firing, resolved or flapping
+	LabelStatus = "status"
+)
+
+// Statuses of alert
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+	StatusFlapping = "flapping"
+)
+
+// Suppressor defaults
+const (
+	DefaultSuppressWindow = 5 * time.Minute
+	DefaultFlapWindow     = time.Hour
+	DefaultFlapThreshold  = 4
+)
+
+// Limit allows Count messages Per period
+type Limit struct {
+	Count int
+	Per   time.Duration
+}
+
+var (
+	// TelegramChatLimits are limits of a single chat: a message per second and 20 per minute in groups
+	TelegramChatLimits = []Limit{{1, time.Second}, {20, time.Minute}}
+	// TelegramGlobalLimit is the limit of all messages of a bot
+	TelegramGlobalLimit = Limit{30, time.Second}
+)
+
+// SuppressorConfig describes suppressor
+type SuppressorConfig struct {
+	// Window repeats of the firing alert are suppressed and summarized once per window
+	Window time.Duration
+	// ResolveAfter alert without repeats for this period is resolved, Window by default
+	ResolveAfter time.Duration
+	// FlapThreshold state changes within FlapWindow marking alert as flapping,
+	// notifications of flapping alert are suppressed until it's stable for FlapWindow
+	FlapThreshold int
+	FlapWindow    time.Duration
+	// ChatLimits per chat, chat is the chat label or severity name, TelegramChatLimits by default
+	ChatLimits []Limit
+	// GlobalLimit of all messages, TelegramGlobalLimit by default
+	GlobalLimit Limit
+}
+
+type alertState struct {
+	alert       *Alert
+	firing      bool
+	flapping    bool
+	last        time.Time
+	lastSent    time.Time
+	suppressed  int
+	transitions []time.Time
+}
+
+// Suppressor deduplicates alerts, sends "still firing" summaries and "resolved" messages
+// and limits send rate, it is Alerter and AlerterV2 implementation
+type Suppressor struct {
+	next      AlerterV2
+	config    SuppressorConfig
+	limiter   *rateLimiter
+	stop      chan struct{}
+	closeOnce sync.Once
+	now       func() time.Time
+
+	sync.Mutex
+	states   map[string]*alertState
+	silences map[string]time.Time
+}
+
+// NewSuppressor creates suppressor wrapping next
+func NewSuppressor(next AlerterV2, config SuppressorConfig) *Suppressor {
+	if config.Window <= 0 {
+		config.Window = DefaultSuppressWindow
+	}
+
+	if config.ResolveAfter <= 0 {
+		config.ResolveAfter = config.Window
+	}
+
+	if config.FlapThreshold <= 0 {
+		config.FlapThreshold = DefaultFlapThreshold
+	}
+
+	if config.FlapWindow <= 0 {
+		config.FlapWindow = DefaultFlapWindow
+	}
+
+	if config.ChatLimits == nil {
+		config.ChatLimits = TelegramChatLimits
+	}
+
+	if config.GlobalLimit.Count <= 0 {
+		config.GlobalLimit = TelegramGlobalLimit
+	}
+
+	s := &Suppressor{
+		next:     next,
+		config:   config,
+		limiter:  newRateLimiter(config.ChatLimits, config.GlobalLimit),
+		stop:     make(chan struct{}),
+		now:      time.Now,
+		states:   make(map[string]*alertState),
+		silences: make(map[string]time.Time),
+	}
+
+	go s.run()
+
+	return s
+}
+
+// Fingerprint identifies alert by its fingerprint label or severity, service, message and labels
+func Fingerprint(alert *Alert) string {
+	if fp := alert.Label(LabelFingerprint); fp != "" {
+		return fp
+	}
+
+	names := make([]string, 0, len(alert.Labels))
+	for name := range alert.Labels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d\x00%s\x00%s", alert.Severity, alert.Service, alert.Message)
+
+	for _, name := range names {
+		fmt.Fprintf(h, "\x00%s=%s", name, alert.Labels[name])
+	}
+
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// PostMessage sends alert made by NewAlert
+func (s *Suppressor) PostMessage(message string, infoLevel string) error {
+	return s.Send(context.Background(), NewAlert(message, infoLevel))
+}
+
+// Send sends the first alert of the fingerprint, repeats are suppressed.
+// Send waits on the caller's goroutine until the rate limit allows the message
+// or ctx is done, which may take up to a minute with TelegramChatLimits.
+// Callers which must not block should pass ctx with deadline or send through Dispatcher
+func (s *Suppressor) Send(ctx context.Context, alert *Alert) error {
+	fp, now := Fingerprint(alert), s.now()
+
+	s.Lock()
+
+	st, ok := s.states[fp]
+	if !ok {
+		st = &alertState{alert: alert}
+		s.states[fp] = st
+	}
+
+	st.last = now
+
+	if s.silenced(fp, now) {
+		// the first silenced alert is counted by the summary as the sent one
+		if !st.firing {
+			st.firing, st.lastSent = true, now
+		} else {
+			st.suppressed++
+		}
+
+		s.Unlock()
+
+		return nil
+	}
+
+	if ok && st.firing {
+		st.suppressed++
+		s.Unlock()
+
+		return nil
+	}
+
+	st.firing = true
+	status := StatusFiring
+
+	if ok {
+		st.transition(now, s.config.FlapWindow)
+
+		if st.flapping {
+			st.suppressed++
+			s.Unlock()
+
+			return nil
+		}
+
+		if len(st.transitions) >= s.config.FlapThreshold {
+			st.flapping = true
+			status = StatusFlapping
+		}
+	}
+
+	st.lastSent, st.suppressed = now, 0
+	s.Unlock()
+
+	return s.deliver(ctx, withStatus(alert, fp, status, ""))
+}
+
+// transition records firing or resolving of alert and forgets ones outside of window
+func (st *alertState) transition(now time.Time, window time.Duration) {
+	st.transitions = append(st.transitions, now)
+
+	i := 0
+	for i < len(st.transitions) && now.Sub(st.transitions[i]) > window {
+		i++
+	}
+
+	st.transitions = st.transitions[i:]
+}
+
+// tick sends summaries and resolves alerts without repeats
+func (s *Suppressor) tick(now time.Time) {
+	var pending []*Alert
+
+	s.Lock()
+
+	for fp, st := range s.states {
+		silenced := s.silenced(fp, now)
+
+		if st.flapping && st.firing && now.Sub(st.transitions[len(st.transitions)-1]) >= s.config.FlapWindow {
+			st.flapping = false
+		}
+
+		switch {
+		case st.firing && now.Sub(st.last) >= s.config.ResolveAfter:
+			st.firing = false
+			st.transition(now, s.config.FlapWindow)
+
+			if !st.flapping && !silenced {
+				pending = append(pending, withStatus(st.alert, fp, StatusResolved, ""))
+			}
+		case st.firing && !st.flapping && !silenced && st.suppressed > 0 && now.Sub(st.lastSent) >= s.config.Window:
+			pending = append(pending, withStatus(st.alert, fp, StatusFiring,
+				fmt.Sprintf("x%d in last %s", st.suppressed+1, ShortDuration(now.Sub(st.lastSent).Round(time.Second)))))
+			st.lastSent, st.suppressed = now, 0
+		case !st.firing && now.Sub(st.last) >= s.config.FlapWindow:
+			if st.flapping {
+				pending = append(pending, withStatus(st.alert, fp, StatusResolved, ""))
+			}
+
+			delete(s.states, fp)
+		}
+	}
+
+	s.Unlock()
+
+	for _, alert := range pending {
+		if err := s.deliver(context.Background(), alert); err != nil {
+			log.Printf("[Alerts] Error sending %s alert %v", alert.Label(LabelStatus), err)
+		}
+	}
+}
+
+// Silence suppresses all notifications of the fingerprint until the time,
+// repeats are summarized after the silence
+func (s *Suppressor) Silence(fingerprint string, until time.Time) {
+	s.Lock()
+	s.silences[fingerprint] = until
+	s.Unlock()
+}
+
+// Unsilence removes silence of the fingerprint
+func (s *Suppressor) Unsilence(fingerprint string) {
+	s.Lock()
+	delete(s.silences, fingerprint)
+	s.Unlock()
+}
+
+// Silences returns active silences by fingerprint
+func (s *Suppressor) Silences() map[string]time.Time {
+	now := s.now()
+
+	s.Lock()
+	defer s.Unlock()
+
+	result := make(map[string]time.Time, len(s.silences))
+	for fp, until := range s.silences {
+		if s.silenced(fp, now) {
+			result[fp] = until
+		}
+	}
+
+	return result
+}
+
+// Resolve forgets state of the fingerprint resolved manually, the next alert is sent immediately
+func (s *Suppressor) Resolve(fingerprint string) {
+	s.Lock()
+	delete(s.states, fingerprint)
+	s.Unlock()
+}
+
+// silenced reports whether fingerprint is silenced and removes expired silence, lock must be held
+func (s *Suppressor) silenced(fingerprint string, now time.Time) bool {
+	until, ok := s.silences[fingerprint]
+	if ok && !now.Before(until) {
+		delete(s.silences, fingerprint)
+		return false
+	}
+
+	return ok
+}
+
+func (s *Suppressor) run() {
+	interval := s.config.Window / 10
+	if interval > time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.tick(s.now())
+		}
+	}
+}
+
+func (s *Suppressor) deliver(ctx context.Context, alert *Alert) error {
+	chat := alert.Label(LabelChat)
+	if chat == "" {
+		chat = alert.Severity.String()
+	}
+
+	if err := s.limiter.wait(ctx, chat); err != nil {
+		return err
+	}
+
+	return s.next.Send(ctx, alert)
+}
+
+// Close stops background summaries, it's safe to call it more than once
+func (s *Suppressor) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// withStatus returns copy of alert with status and fingerprint labels and message prefixed by the status
+func withStatus(alert *Alert, fingerprint, status, suffix string) *Alert {
+	result := *alert
+	result.Labels = make(map[string]string, len(alert.Labels)+2)
+
+	for name, value := range alert.Labels {
+		result.Labels[name] = value
+	}
+
+	result.Labels[LabelStatus] = status
+	result.Labels[LabelFingerprint] = fingerprint
+
+	switch {
+	case status == StatusResolved:
+		result.Message = "Resolved: " + alert.Message
+	case status == StatusFlapping:
+		result.Message = "Flapping: " + alert.Message
+	case suffix != "":
+		result.Message = "Still firing: " + alert.Message
+	}
+
+	if suffix != "" {
+		result.Message += "\n" + suffix
+	}
+
+	return &result
+}
+
+// rateLimiter is sliding window limiter of chats
+type rateLimiter struct {
+	sync.Mutex
+	chatLimits []Limit
+	global     Limit
+	chats      map[string][]time.Time
+	all        []time.Time
+}
+
+func newRateLimiter(chatLimits []Limit, global Limit) *rateLimiter {
+	return &rateLimiter{chatLimits: chatLimits, global: global, chats: make(map[string][]time.Time)}
+}
+
+// delay returns how long to wait before sending by limit
+func (l Limit) delay(sent []time.Time, now time.Time) time.Duration {
+	if l.Count <= 0 || len(sent) < l.Count {
+		return 0
+	}
+
+	return sent[len(sent)-l.Count].Add(l.Per).Sub(now)
+}
+
+// reserve records message if it's allowed now, otherwise returns the delay
+func (r *rateLimiter) reserve(chat string, now time.Time) time.Duration {
+	r.Lock()
+	defer r.Unlock()
+
+	sent := r.chats[chat]
+	delay := r.global.delay(r.all, now)
+
+	for _, l := range r.chatLimits {
+		if d := l.delay(sent, now); d > delay {
+			delay = d
+		}
+	}
+
+	if delay > 0 {
+		return delay
+	}
+
+	r.chats[chat] = prune(append(sent, now), now, r.chatLimits)
+	r.all = prune(append(r.all, now), now, []Limit{r.global})
+
+	for name, times := range r.chats {
+		if len(prune(times, now, r.chatLimits)) == 0 {
+			delete(r.chats, name)
+		}
+	}
+
+	return 0
+}
+
+// prune drops times older than the longest limit period
+func prune(times []time.Time, now time.Time, limits []Limit) []time.Time {
+	var longest time.Duration
+	for _, l := range limits {
+		if l.Per > longest {
+			longest = l.Per
+		}
+	}
+
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= longest {
+		i++
+	}
+
+	return times[i:]
+}
+
+func (r *rateLimiter) wait(ctx context.Context, chat string) error {
+	for {
+		delay := r.reserve(chat, time.Now())
+		if delay <= 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
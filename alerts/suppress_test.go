@@ -0,0 +1,153 @@
+package alerts
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSuppressor(now *time.Time) (*Suppressor, *recordingAlerter) {
+	rec := &recordingAlerter{}
+	s := NewSuppressor(rec, SuppressorConfig{Window: time.Minute, FlapWindow: time.Hour, ChatLimits: []Limit{}})
+	s.Close()
+	s.now = func() time.Time { return *now }
+
+	return s, rec
+}
+
+func TestSuppressorRepeats(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, rec := newTestSuppressor(&now)
+	alert := &Alert{Severity: SeverityError, Service: "api", Message: "db down"}
+
+	for i := 0; i < 10; i++ {
+		s.Send(context.Background(), alert)
+		now = now.Add(5 * time.Second)
+		s.tick(now)
+	}
+
+	now = now.Add(10 * time.Second)
+	s.tick(now)
+
+	if len(rec.alerts) != 2 {
+		t.Fatalf("expected alert and summary, got %d", len(rec.alerts))
+	}
+
+	if summary := rec.alerts[1].Message; !strings.HasPrefix(summary, "Still firing: db down") || !strings.HasSuffix(summary, "x10 in last 1m") {
+		t.Fatalf("unexpected summary %q", summary)
+	}
+
+	now = now.Add(2 * time.Minute)
+	s.tick(now)
+
+	if last := rec.alerts[len(rec.alerts)-1]; last.Label(LabelStatus) != StatusResolved || last.Message != "Resolved: db down" {
+		t.Fatalf("expected resolved alert, got %+v", last)
+	}
+}
+
+func TestSuppressorFlapping(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, rec := newTestSuppressor(&now)
+	alert := &Alert{Severity: SeverityError, Message: "flaky"}
+
+	for i := 0; i < 6; i++ {
+		s.Send(context.Background(), alert)
+		now = now.Add(2 * time.Minute)
+		s.tick(now)
+	}
+
+	// firing, resolved, firing, resolved, flapping and nothing after that
+	if len(rec.alerts) != 5 || rec.alerts[4].Label(LabelStatus) != StatusFlapping {
+		t.Fatalf("unexpected alerts %d", len(rec.alerts))
+	}
+
+	now = now.Add(2 * time.Hour)
+	s.tick(now)
+
+	if len(rec.alerts) != 6 || rec.alerts[5].Label(LabelStatus) != StatusResolved {
+		t.Fatalf("expected final resolve, got %d alerts", len(rec.alerts))
+	}
+
+	if len(s.states) != 0 {
+		t.Fatal("state is not removed")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := &Alert{Message: "m", Labels: map[string]string{"a": "1", "b": "2"}}
+	b := &Alert{Message: "m", Labels: map[string]string{"b": "2", "a": "1"}}
+	c := &Alert{Message: "m", Labels: map[string]string{"a": "1"}}
+
+	if Fingerprint(a) != Fingerprint(b) || Fingerprint(a) == Fingerprint(c) {
+		t.Fatal("unexpected fingerprints")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter([]Limit{{1, time.Second}, {3, time.Minute}}, Limit{30, time.Second})
+	now := time.Now()
+
+	if d := l.reserve("a", now); d != 0 {
+		t.Fatalf("unexpected delay %v", d)
+	}
+
+	if d := l.reserve("a", now); d != time.Second {
+		t.Fatalf("expected 1s delay, got %v", d)
+	}
+
+	if d := l.reserve("b", now); d != 0 {
+		t.Fatalf("other chat is limited by %v", d)
+	}
+
+	l.reserve("a", now.Add(time.Second))
+	l.reserve("a", now.Add(2*time.Second))
+
+	if d := l.reserve("a", now.Add(3*time.Second)); d != 57*time.Second {
+		t.Fatalf("expected 57s delay, got %v", d)
+	}
+}
+
+func TestSuppressorSilence(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, rec := newTestSuppressor(&now)
+	alert := &Alert{Severity: SeverityError, Message: "disk full"}
+	fp := Fingerprint(alert)
+
+	s.Silence(fp, now.Add(time.Hour))
+
+	for i := 0; i < 119; i++ {
+		s.Send(context.Background(), alert)
+		now = now.Add(30 * time.Second)
+		s.tick(now)
+	}
+
+	if len(rec.alerts) != 0 {
+		t.Fatalf("silenced alert is sent %d times", len(rec.alerts))
+	}
+
+	if _, ok := s.Silences()[fp]; !ok {
+		t.Fatal("silence is not listed")
+	}
+
+	now = now.Add(30 * time.Second)
+	s.Send(context.Background(), alert)
+	s.tick(now)
+
+	if len(rec.alerts) != 1 || !strings.HasSuffix(rec.alerts[0].Message, "x120 in last 1h") {
+		t.Fatalf("expected summary after silence, got %d alerts %q", len(rec.alerts), rec.alerts[0].Message)
+	}
+
+	s.Resolve(fp)
+	s.Send(context.Background(), alert)
+
+	if len(rec.alerts) != 2 || rec.alerts[1].Message != "disk full" {
+		t.Fatal("alert is not sent after manual resolve")
+	}
+}
+
+func TestSuppressorCloseTwice(t *testing.T) {
+	s := NewSuppressor(&recordingAlerter{}, SuppressorConfig{})
+	s.Close()
+	s.Close()
+}
@@ -0,0 +1,344 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/finnan444/utils/alerts"
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+)
+
+// Callback data prefixes of alert buttons, the alert key follows the prefix
+const (
+	CallbackAck     = "ack:"
+	CallbackSilence = "silence:"
+	CallbackResolve = "resolve:"
+)
+
+// Ack statuses
+const (
+	AckFiring       = "firing"
+	AckAcknowledged = "acknowledged"
+	AckSilenced     = "silenced"
+	AckResolved     = "resolved"
+)
+
+// Acks defaults
+const (
+	// AcksPath is path of ack states endpoint
+	AcksPath       = "/internal/alerts/acks"
+	DefaultSilence = time.Hour
+	// ackRetention resolved alerts are forgotten after it
+	ackRetention = 24 * time.Hour
+	// maxKeyLength keeps callback data within 64 bytes
+	maxKeyLength = 48
+)
+
+// Silencer receives silences and manual resolves of alerts, alerts.Suppressor implements it
+type Silencer interface {
+	Silence(fingerprint string, until time.Time)
+	Resolve(fingerprint string)
+}
+
+// AckState is state of alert sent with buttons
+type AckState struct {
+	Fingerprint   string     `json:"fingerprint"`
+	ChatID        string     `json:"chatId"`
+	MessageID     int        `json:"messageId"`
+	Title         string     `json:"title"`
+	Status        string     `json:"status"`
+	By            string     `json:"by,omitempty"`
+	Sent          time.Time  `json:"sent"`
+	Updated       time.Time  `json:"updated"`
+	SilencedUntil *time.Time `json:"silencedUntil,omitempty"`
+
+	text, parseMode string
+}
+
+// Acks handles Ack, Silence and Resolve buttons of alerts, see Alert.SetAcks
+type Acks struct {
+	client   *Client
+	silencer Silencer
+	silence  time.Duration
+
+	sync.Mutex
+	states map[string]*AckState
+}
+
+// NewAcks creates acks editing messages with client, silencer may be nil
+func NewAcks(client *Client, silencer Silencer) *Acks {
+	return &Acks{
+		client:   client,
+		silencer: silencer,
+		silence:  DefaultSilence,
+		states:   make(map[string]*AckState),
+	}
+}
+
+// Register adds callback handlers of buttons to mux
+func (a *Acks) Register(mux *Mux) {
+	for _, prefix := range []string{CallbackAck, CallbackSilence, CallbackResolve} {
+		mux.HandleCallback(prefix, a.handle)
+	}
+}
+
+// States returns states of tracked alerts, the latest first
+func (a *Acks) States() []AckState {
+	a.Lock()
+	defer a.Unlock()
+
+	result := make([]AckState, 0, len(a.states))
+	for _, st := range a.states {
+		result = append(result, *st)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Sent.After(result[j].Sent)
+	})
+
+	return result
+}
+
+// ackKey is fingerprint or its hash if it doesn't fit callback data
+func ackKey(fingerprint string) string {
+	if len(fingerprint) <= maxKeyLength {
+		return fingerprint
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(fingerprint))
+
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+func (a *Acks) keyboard(key, status string) *InlineKeyboardMarkup {
+	var row []InlineKeyboardButton
+
+	if status == AckFiring {
+		row = append(row, NewCallbackButton("Ack", CallbackAck+key))
+	}
+
+	if status == AckFiring || status == AckAcknowledged {
+		row = append(row, NewCallbackButton("Silence "+alerts.ShortDuration(a.silence), CallbackSilence+key))
+	}
+
+	if status != AckResolved {
+		row = append(row, NewCallbackButton("Resolve", CallbackResolve+key))
+	}
+
+	if len(row) == 0 {
+		return nil
+	}
+
+	return NewInlineKeyboard(row)
+}
+
+// status returns ack status of the firing alert, resolved or unknown alerts are firing again
+func (a *Acks) status(fingerprint string) string {
+	a.Lock()
+	defer a.Unlock()
+
+	if st, ok := a.states[ackKey(fingerprint)]; ok && st.Status != AckResolved {
+		return st.Status
+	}
+
+	return AckFiring
+}
+
+// track remembers alert message to edit it later. Repeated messages of the alert
+// which is not resolved, e.g. "still firing" summaries, keep its ack status
+func (a *Acks) track(fingerprint, chatID string, msg *Message, title, text, parseMode string) {
+	now := time.Now()
+
+	a.Lock()
+	defer a.Unlock()
+
+	for key, st := range a.states {
+		if now.Sub(st.Updated) > ackRetention && (st.Status == AckResolved || now.Sub(st.Sent) > ackRetention) {
+			delete(a.states, key)
+		}
+	}
+
+	key := ackKey(fingerprint)
+	if st, ok := a.states[key]; ok && st.Status != AckResolved {
+		st.ChatID, st.MessageID, st.Updated = chatID, msg.MessageID, now
+		st.text, st.parseMode = text, parseMode
+		return
+	}
+
+	a.states[key] = &AckState{
+		Fingerprint: fingerprint,
+		ChatID:      chatID,
+		MessageID:   msg.MessageID,
+		Title:       title,
+		Status:      AckFiring,
+		Sent:        now,
+		Updated:     now,
+		text:        text,
+		parseMode:   parseMode,
+	}
+}
+
+// resolved marks alert resolved by its "resolved" notification
+func (a *Acks) resolved(ctx context.Context, fingerprint string) {
+	a.Lock()
+	st, ok := a.states[ackKey(fingerprint)]
+	if ok && st.Status != AckResolved {
+		st.Status, st.By, st.Updated = AckResolved, "", time.Now()
+	} else {
+		ok = false
+	}
+	a.Unlock()
+
+	if ok {
+		a.edit(ctx, ackKey(fingerprint))
+	}
+}
+
+func userName(u *User) string {
+	if u == nil {
+		return "unknown"
+	}
+
+	if u.UserName != "" {
+		return "@" + u.UserName
+	}
+
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+func (a *Acks) handle(ctx context.Context, query *CallbackQuery) {
+	var (
+		prefix = query.Data[:strings.IndexByte(query.Data, ':')+1]
+		key    = strings.TrimPrefix(query.Data, prefix)
+		now    = time.Now()
+		by     = userName(query.From)
+		answer string
+	)
+
+	a.Lock()
+
+	st, ok := a.states[key]
+	if !ok {
+		a.Unlock()
+		a.answer(ctx, query, "Alert is not tracked anymore")
+
+		return
+	}
+
+	switch prefix {
+	case CallbackAck:
+		st.Status, answer = AckAcknowledged, "Acknowledged"
+	case CallbackSilence:
+		until := now.Add(a.silence)
+		st.Status, st.SilencedUntil, answer = AckSilenced, &until, "Silenced for "+alerts.ShortDuration(a.silence)
+	case CallbackResolve:
+		st.Status, answer = AckResolved, "Resolved"
+	}
+
+	st.By, st.Updated = by, now
+	fingerprint, until := st.Fingerprint, st.SilencedUntil
+	a.Unlock()
+
+	if a.silencer != nil {
+		switch prefix {
+		case CallbackSilence:
+			a.silencer.Silence(fingerprint, *until)
+		case CallbackResolve:
+			a.silencer.Resolve(fingerprint)
+		}
+	}
+
+	a.answer(ctx, query, answer)
+	a.edit(ctx, key)
+}
+
+func (a *Acks) answer(ctx context.Context, query *CallbackQuery, text string) {
+	if err := a.client.AnswerCallbackQuery(ctx, &AnswerCallbackQueryRequest{CallbackQueryID: query.ID, Text: text}); err != nil {
+		log.Printf("[Telegram] Error answering callback query %v", err)
+	}
+}
+
+// edit appends status line to the alert message and updates its buttons
+func (a *Acks) edit(ctx context.Context, key string) {
+	a.Lock()
+
+	st, ok := a.states[key]
+	if !ok {
+		a.Unlock()
+		return
+	}
+
+	var line string
+
+	switch st.Status {
+	case AckAcknowledged:
+		line = "👀 Acknowledged by " + st.By
+	case AckSilenced:
+		line = fmt.Sprintf("🔕 Silenced until %s by %s", st.SilencedUntil.Format("15:04 MST"), st.By)
+	case AckResolved:
+		line = "✅ Resolved"
+		if st.By != "" {
+			line += " by " + st.By
+		}
+	}
+
+	status := "\n\n" + alerts.Escape(formatOf(st.parseMode), line)
+
+	text := st.text
+	if limit := MaxMessageLength - utf8.RuneCountInString(status); utf8.RuneCountInString(text) > limit {
+		// Split closes entities open at the cut, so the kept part stays valid markup
+		text = Split(text, st.parseMode, limit)[0]
+	}
+
+	req := &EditMessageTextRequest{
+		ChatID:      st.ChatID,
+		MessageID:   st.MessageID,
+		Text:        text + status,
+		ParseMode:   st.parseMode,
+		ReplyMarkup: a.keyboard(key, st.Status),
+	}
+
+	if req.ParseMode == ParseModeNone {
+		req.ParseMode = ""
+	}
+
+	a.Unlock()
+
+	if _, err := a.client.EditMessageText(ctx, req); err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		log.Printf("[Telegram] Error editing alert message %v", err)
+	}
+}
+
+// AcksHandler returns handler responding with ack states.
+// Requests are authenticated with adminSecret, see transport.AuthenticateAdmin
+func AcksHandler(acks *Acks, adminSecret string, server transport.PathesLogger) transport.RouterFunc {
+	return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		if !transport.AuthenticateAdmin(ctx, adminSecret) {
+			return
+		}
+
+		resp := transport.GetResponse()
+		resp.Payload = acks.States()
+		transport.SendResponse(ctx, resp, now, server)
+	}
+}
+
+// RegisterAcksRoute adds GET route of ack states, e.g. AcksPath, to transport.DefaultRouter
+func RegisterAcksRoute(path string, acks *Acks, adminSecret string, server transport.PathesLogger, middlewares ...transport.Middleware) {
+	RegisterAcksRouteOn(transport.DefaultRouter, path, acks, adminSecret, server, middlewares...)
+}
+
+// RegisterAcksRouteOn adds GET route of ack states to router
+func RegisterAcksRouteOn(router *transport.Router, path string, acks *Acks, adminSecret string, server transport.PathesLogger, middlewares ...transport.Middleware) {
+	router.AddGetRoute(path, transport.Chain(AcksHandler(acks, adminSecret, server), middlewares...))
+}
@@ -0,0 +1,224 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/alerts"
+	"github.com/finnan444/utils/transport"
+	"github.com/finnan444/utils/transport/transporttest"
+	"github.com/valyala/fasthttp"
+)
+
+type fakeSilencer struct {
+	silenced map[string]time.Time
+	resolved []string
+}
+
+func (s *fakeSilencer) Silence(fingerprint string, until time.Time) {
+	s.silenced[fingerprint] = until
+}
+
+func (s *fakeSilencer) Resolve(fingerprint string) {
+	s.resolved = append(s.resolved, fingerprint)
+}
+
+func TestAcks(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		sent    *SendMessageRequest
+		edits   []*EditMessageTextRequest
+		answers []string
+	)
+
+	c, stop := startFakeAPI(t, func(ctx *fasthttp.RequestCtx) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch path := string(ctx.Path()); {
+		case strings.HasSuffix(path, methodSendMessage):
+			sent = &SendMessageRequest{}
+			json.Unmarshal(ctx.PostBody(), sent)
+			ctx.SetBodyString(`{"ok":true,"result":{"message_id":7}}`)
+		case strings.HasSuffix(path, methodEditMessageText):
+			req := &EditMessageTextRequest{}
+			json.Unmarshal(ctx.PostBody(), req)
+			edits = append(edits, req)
+			ctx.SetBodyString(`{"ok":true,"result":{"message_id":7}}`)
+		case strings.HasSuffix(path, methodAnswerCallbackQuery):
+			req := &AnswerCallbackQueryRequest{}
+			json.Unmarshal(ctx.PostBody(), req)
+			answers = append(answers, req.Text)
+			ctx.SetBodyString(`{"ok":true,"result":true}`)
+		}
+	})
+	defer stop()
+
+	silencer := &fakeSilencer{silenced: map[string]time.Time{}}
+	acks := NewAcks(c, silencer)
+	mux := NewMux()
+	acks.Register(mux)
+
+	a := InitTelegram(&Config{ParseMode: ParseModeNone}, 1)
+	a.Client = c
+	a.SetAcks(acks)
+
+	alert := &alerts.Alert{Message: "disk full", Labels: map[string]string{alerts.LabelChat: "42", alerts.LabelFingerprint: "disk"}}
+	if err := a.Send(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent == nil || sent.ReplyMarkup == nil || len(sent.ReplyMarkup.InlineKeyboard[0]) != 3 ||
+		sent.ReplyMarkup.InlineKeyboard[0][0].CallbackData != CallbackAck+"disk" {
+		t.Fatalf("unexpected message %+v", sent)
+	}
+
+	query := func(data string) {
+		mux.Dispatch(context.Background(), &Update{CallbackQuery: &CallbackQuery{
+			ID: "q", From: &User{UserName: "alice"}, Data: data,
+		}})
+	}
+
+	query(CallbackAck + "disk")
+
+	if len(edits) != 1 || edits[0].MessageID != 7 || edits[0].Text != "disk full\n\n👀 Acknowledged by @alice" ||
+		len(edits[0].ReplyMarkup.InlineKeyboard[0]) != 2 {
+		t.Fatalf("unexpected edits %+v", edits)
+	}
+
+	query(CallbackSilence + "disk")
+
+	if until := silencer.silenced["disk"]; time.Until(until) < 59*time.Minute {
+		t.Fatalf("unexpected silence %v", silencer.silenced)
+	}
+
+	query(CallbackAck + "unknown")
+
+	if len(answers) != 3 || answers[0] != "Acknowledged" || answers[2] != "Alert is not tracked anymore" {
+		t.Fatalf("unexpected answers %v", answers)
+	}
+
+	resolved := &alerts.Alert{Message: "Resolved: disk full", Labels: map[string]string{
+		alerts.LabelChat: "42", alerts.LabelFingerprint: "disk", alerts.LabelStatus: alerts.StatusResolved,
+	}}
+	if err := a.Send(context.Background(), resolved); err != nil {
+		t.Fatal(err)
+	}
+
+	if last := edits[len(edits)-1]; last.Text != "disk full\n\n✅ Resolved" || last.ReplyMarkup != nil {
+		t.Fatalf("unexpected edit %+v", last)
+	}
+
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+
+	RegisterAcksRouteOn(srv.Router, AcksPath, acks, "admin", &transport.LogPathes{})
+
+	srv.Get(AcksPath).Do().AssertStatus(fasthttp.StatusUnauthorized)
+
+	var states []AckState
+	srv.Get(AcksPath).Header(transport.AdminTokenHeader, "admin").Do().AssertStatus(fasthttp.StatusOK).DecodePayload(&states)
+
+	if len(states) != 1 || states[0].Fingerprint != "disk" || states[0].Status != AckResolved || states[0].By != "" {
+		t.Fatalf("unexpected states %+v", states)
+	}
+}
+
+func TestAcksEditFitsLimit(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		edit *EditMessageTextRequest
+	)
+
+	c, stop := startFakeAPI(t, func(ctx *fasthttp.RequestCtx) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if strings.HasSuffix(string(ctx.Path()), methodEditMessageText) {
+			edit = &EditMessageTextRequest{}
+			json.Unmarshal(ctx.PostBody(), edit)
+		}
+
+		ctx.SetBodyString(`{"ok":true,"result":{"message_id":7}}`)
+	})
+	defer stop()
+
+	acks := NewAcks(c, &fakeSilencer{silenced: map[string]time.Time{}})
+	mux := NewMux()
+	acks.Register(mux)
+
+	a := InitTelegram(&Config{ParseMode: ParseModeNone}, 1)
+	a.Client = c
+	a.SetAcks(acks)
+
+	message := strings.Repeat("word ", MaxMessageLength/5) + "x"
+	alert := &alerts.Alert{Message: message, Labels: map[string]string{alerts.LabelChat: "42", alerts.LabelFingerprint: "long"}}
+	if err := a.Send(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+
+	mux.Dispatch(context.Background(), &Update{CallbackQuery: &CallbackQuery{
+		ID: "q", From: &User{UserName: "alice"}, Data: CallbackAck + "long",
+	}})
+
+	if edit == nil || len([]rune(edit.Text)) > MaxMessageLength || !strings.HasSuffix(edit.Text, "👀 Acknowledged by @alice") {
+		t.Fatalf("unexpected edit %+v", edit)
+	}
+}
+
+func TestAcksKeepStatusOnSummary(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent *SendMessageRequest
+		id   int
+	)
+
+	c, stop := startFakeAPI(t, func(ctx *fasthttp.RequestCtx) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if strings.HasSuffix(string(ctx.Path()), methodSendMessage) {
+			sent = &SendMessageRequest{}
+			json.Unmarshal(ctx.PostBody(), sent)
+			id++
+		}
+
+		ctx.SetBodyString(`{"ok":true,"result":{"message_id":` + strconv.Itoa(id) + `}}`)
+	})
+	defer stop()
+
+	acks := NewAcks(c, &fakeSilencer{silenced: map[string]time.Time{}})
+	mux := NewMux()
+	acks.Register(mux)
+
+	a := InitTelegram(&Config{ParseMode: ParseModeNone}, 1)
+	a.Client = c
+	a.SetAcks(acks)
+
+	labels := map[string]string{alerts.LabelChat: "42", alerts.LabelFingerprint: "disk"}
+	if err := a.Send(context.Background(), &alerts.Alert{Message: "disk full", Labels: labels}); err != nil {
+		t.Fatal(err)
+	}
+
+	mux.Dispatch(context.Background(), &Update{CallbackQuery: &CallbackQuery{
+		ID: "q", From: &User{UserName: "alice"}, Data: CallbackAck + "disk",
+	}})
+
+	if err := a.Send(context.Background(), &alerts.Alert{Message: "Still firing: disk full", Labels: labels}); err != nil {
+		t.Fatal(err)
+	}
+
+	if buttons := sent.ReplyMarkup.InlineKeyboard[0]; len(buttons) != 2 || buttons[0].CallbackData != CallbackSilence+"disk" {
+		t.Fatalf("expected summary without ack button, got %+v", buttons)
+	}
+
+	states := acks.States()
+	if len(states) != 1 || states[0].Status != AckAcknowledged || states[0].By != "@alice" || states[0].MessageID != 2 {
+		t.Fatalf("unexpected states %+v", states)
+	}
+}
@@ -0,0 +1,350 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finnan444/utils/alerts"
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+)
+
+// Methods
+const (
+	methodGetMe               = "getMe"
+	methodGetChat             = "getChat"
+	methodSendMessage         = "sendMessage"
+	methodSendPhoto           = "sendPhoto"
+	methodSendDocument        = "sendDocument"
+	methodEditMessageText     = "editMessageText"
+	methodDeleteMessage       = "deleteMessage"
+	methodAnswerCallbackQuery = "answerCallbackQuery"
+)
+
+// Error is error response of Bot API
+type Error struct {
+	Method      string
+	Code        int
+	Description string
+	// RetryAfter seconds to wait when flood control is exceeded
+	RetryAfter int
+	// MigrateToChatID new id of the group upgraded to supergroup
+	MigrateToChatID int64
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("[Telegram] %s failed %d %s", e.Method, e.Code, e.Description)
+}
+
+// IsParseError reports whether text markup is rejected
+func (e *Error) IsParseError() bool {
+	return e.Code == fasthttp.StatusBadRequest && strings.Contains(e.Description, "can't parse entities")
+}
+
+// IsParseError reports whether err is *Error rejecting text markup
+func IsParseError(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.IsParseError()
+}
+
+// RetryAfter returns flood control delay of err or 0
+func RetryAfter(err error) time.Duration {
+	if e, ok := err.(*Error); ok {
+		return time.Duration(e.RetryAfter) * time.Second
+	}
+
+	return 0
+}
+
+type responseParameters struct {
+	MigrateToChatID int64 `json:"migrate_to_chat_id"`
+	RetryAfter      int   `json:"retry_after"`
+}
+
+type apiResponse struct {
+	OK          bool                `json:"ok"`
+	Result      json.RawMessage     `json:"result"`
+	ErrorCode   int                 `json:"error_code"`
+	Description string              `json:"description"`
+	Parameters  *responseParameters `json:"parameters"`
+}
+
+// InlineKeyboardButton is button of inline keyboard, one of URL and CallbackData must be set
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	URL          string `json:"url,omitempty"`
+	CallbackData string `json:"callback_data,omitempty"`
+}
+
+// InlineKeyboardMarkup is keyboard attached to message
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+// NewInlineKeyboard creates keyboard of button rows
+func NewInlineKeyboard(rows ...[]InlineKeyboardButton) *InlineKeyboardMarkup {
+	return &InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// NewCallbackButton creates button sending callback query with data, data is up to 64 bytes
+func NewCallbackButton(text, data string) InlineKeyboardButton {
+	return InlineKeyboardButton{Text: text, CallbackData: data}
+}
+
+// NewURLButton creates button opening url
+func NewURLButton(text, url string) InlineKeyboardButton {
+	return InlineKeyboardButton{Text: text, URL: url}
+}
+
+// SendMessageRequest is sendMessage parameters
+type SendMessageRequest struct {
+	ChatID                string                `json:"chat_id"`
+	Text                  string                `json:"text"`
+	ParseMode             string                `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool                  `json:"disable_web_page_preview,omitempty"`
+	DisableNotification   bool                  `json:"disable_notification,omitempty"`
+	ReplyToMessageID      int                   `json:"reply_to_message_id,omitempty"`
+	ReplyMarkup           *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+// MediaRequest is sendPhoto and sendDocument parameters
+type MediaRequest struct {
+	ChatID              string
+	Caption             string
+	ParseMode           string
+	DisableNotification bool
+	ReplyToMessageID    int
+	ReplyMarkup         *InlineKeyboardMarkup
+}
+
+// InputFile is uploaded file or FileID of the file already stored by Telegram or URL of file
+type InputFile struct {
+	FileName string
+	Content  io.Reader
+	FileID   string
+}
+
+// EditMessageTextRequest is editMessageText parameters, either ChatID and MessageID or InlineMessageID must be set
+type EditMessageTextRequest struct {
+	ChatID                string                `json:"chat_id,omitempty"`
+	MessageID             int                   `json:"message_id,omitempty"`
+	InlineMessageID       string                `json:"inline_message_id,omitempty"`
+	Text                  string                `json:"text"`
+	ParseMode             string                `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool                  `json:"disable_web_page_preview,omitempty"`
+	ReplyMarkup           *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+// AnswerCallbackQueryRequest is answerCallbackQuery parameters
+type AnswerCallbackQueryRequest struct {
+	CallbackQueryID string `json:"callback_query_id"`
+	Text            string `json:"text,omitempty"`
+	ShowAlert       bool   `json:"show_alert,omitempty"`
+	URL             string `json:"url,omitempty"`
+	CacheTime       int    `json:"cache_time,omitempty"`
+}
+
+// Client is Bot API client
+type Client struct {
+	botURL  string
+	timeout time.Duration
+}
+
+// NewClient creates client of the bot from config
+func NewClient(config *Config, connectionTimeoutSeconds time.Duration) *Client {
+	return &Client{
+		botURL:  fmt.Sprintf(config.URL, config.BotID, config.BotToken),
+		timeout: connectionTimeoutSeconds * time.Second,
+	}
+}
+
+// GetMe returns the bot user
+func (c *Client) GetMe(ctx context.Context) (*User, error) {
+	user := &User{}
+	return user, c.call(ctx, methodGetMe, nil, user)
+}
+
+// GetChat returns chat by id or @username
+func (c *Client) GetChat(ctx context.Context, chatID string) (*Chat, error) {
+	chat := &Chat{}
+	return chat, c.call(ctx, methodGetChat, map[string]string{"chat_id": chatID}, chat)
+}
+
+// SendMessage sends text message
+func (c *Client) SendMessage(ctx context.Context, req *SendMessageRequest) (*Message, error) {
+	msg := &Message{}
+	return msg, c.call(ctx, methodSendMessage, req, msg)
+}
+
+// SendPhoto sends photo
+func (c *Client) SendPhoto(ctx context.Context, req *MediaRequest, photo InputFile) (*Message, error) {
+	return c.sendMedia(ctx, methodSendPhoto, "photo", req, photo)
+}
+
+// SendDocument sends document
+func (c *Client) SendDocument(ctx context.Context, req *MediaRequest, document InputFile) (*Message, error) {
+	return c.sendMedia(ctx, methodSendDocument, "document", req, document)
+}
+
+// EditMessageText replaces text and keyboard of message, nil is returned for inline messages
+func (c *Client) EditMessageText(ctx context.Context, req *EditMessageTextRequest) (*Message, error) {
+	var result json.RawMessage
+	if err := c.call(ctx, methodEditMessageText, req, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 || result[0] != '{' {
+		return nil, nil
+	}
+
+	msg := &Message{}
+
+	return msg, json.Unmarshal(result, msg)
+}
+
+// DeleteMessage deletes message
+func (c *Client) DeleteMessage(ctx context.Context, chatID string, messageID int) error {
+	return c.call(ctx, methodDeleteMessage, map[string]interface{}{"chat_id": chatID, "message_id": messageID}, nil)
+}
+
+// AnswerCallbackQuery answers callback query of inline keyboard button
+func (c *Client) AnswerCallbackQuery(ctx context.Context, req *AnswerCallbackQueryRequest) error {
+	return c.call(ctx, methodAnswerCallbackQuery, req, nil)
+}
+
+func (c *Client) sendMedia(ctx context.Context, method, field string, req *MediaRequest, file InputFile) (*Message, error) {
+	fields := map[string]string{"chat_id": req.ChatID}
+
+	if req.Caption != "" {
+		fields["caption"] = req.Caption
+	}
+
+	if req.ParseMode != "" {
+		fields["parse_mode"] = req.ParseMode
+	}
+
+	if req.DisableNotification {
+		fields["disable_notification"] = "true"
+	}
+
+	if req.ReplyToMessageID != 0 {
+		fields["reply_to_message_id"] = strconv.Itoa(req.ReplyToMessageID)
+	}
+
+	if req.ReplyMarkup != nil {
+		markup, err := json.Marshal(req.ReplyMarkup)
+		if err != nil {
+			return nil, err
+		}
+
+		fields["reply_markup"] = string(markup)
+	}
+
+	msg := &Message{}
+
+	if file.FileID != "" {
+		fields[field] = file.FileID
+		return msg, c.call(ctx, method, fields, msg)
+	}
+
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+
+	w, err := mw.CreateFormFile(field, file.FileName)
+	if err == nil {
+		_, err = io.Copy(w, file.Content)
+	}
+
+	if err == nil {
+		err = mw.Close()
+	}
+
+	if err != nil {
+		log.Printf("[Telegram] Error building %s request %v", method, err)
+		return nil, err
+	}
+
+	return msg, c.do(ctx, method, mw.FormDataContentType(), body.Bytes(), msg, c.timeout)
+}
+
+// call calls method with JSON params, result is decoded into result if it's not nil
+func (c *Client) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	return c.callTimeout(ctx, method, params, result, c.timeout)
+}
+
+func (c *Client) callTimeout(ctx context.Context, method string, params interface{}, result interface{}, timeout time.Duration) error {
+	var body []byte
+
+	if params != nil {
+		var err error
+		if body, err = json.Marshal(params); err != nil {
+			log.Printf("[Telegram] Error marshaling %s request %v", method, err)
+			return err
+		}
+	}
+
+	return c.do(ctx, method, transport.ApplicationJSON, body, result, timeout)
+}
+
+func (c *Client) do(ctx context.Context, method, contentType string, body []byte, result interface{}, timeout time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(c.botURL + "/" + method)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType(contentType)
+	req.SetBody(body)
+
+	client := transport.GetHTTPClient()
+	err := client.DoTimeout(req, resp, alerts.Timeout(ctx, timeout))
+	transport.PutHTTPClient(client)
+
+	if err != nil {
+		log.Printf("[Telegram] error calling %s %v", method, err)
+		return err
+	}
+
+	response := &apiResponse{}
+	if err = json.Unmarshal(resp.Body(), response); err != nil {
+		log.Printf("[Telegram] error decoding %s response %d %s", method, resp.StatusCode(), resp.Body())
+		return fmt.Errorf("[Telegram] %s failed %d %s", method, resp.StatusCode(), resp.Body())
+	}
+
+	if !response.OK {
+		apiErr := &Error{Method: method, Code: response.ErrorCode, Description: response.Description}
+		if apiErr.Code == 0 {
+			apiErr.Code = resp.StatusCode()
+		}
+
+		if p := response.Parameters; p != nil {
+			apiErr.RetryAfter, apiErr.MigrateToChatID = p.RetryAfter, p.MigrateToChatID
+		}
+
+		log.Printf("[Telegram] %v", apiErr)
+
+		return apiErr
+	}
+
+	if result != nil && len(response.Result) > 0 {
+		return json.Unmarshal(response.Result, result)
+	}
+
+	return nil
+}
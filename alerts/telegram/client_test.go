@@ -0,0 +1,109 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func startFakeAPI(t *testing.T, handler fasthttp.RequestHandler) (*Client, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go fasthttp.Serve(ln, handler)
+
+	c := NewClient(&Config{URL: "http://" + ln.Addr().String() + "/bot%s:%s", BotID: "1", BotToken: "t"}, 1)
+
+	return c, func() { ln.Close() }
+}
+
+func TestClientErrors(t *testing.T) {
+	c, stop := startFakeAPI(t, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusTooManyRequests)
+		ctx.SetBodyString(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 7","parameters":{"retry_after":7}}`)
+	})
+	defer stop()
+
+	_, err := c.SendMessage(context.Background(), &SendMessageRequest{ChatID: "42", Text: "t"})
+
+	apiErr, ok := err.(*Error)
+	if !ok || apiErr.Code != 429 || apiErr.Method != methodSendMessage || RetryAfter(err) != 7*time.Second {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	if IsParseError(err) {
+		t.Fatal("unexpected parse error")
+	}
+}
+
+func TestClientMethods(t *testing.T) {
+	c, stop := startFakeAPI(t, func(ctx *fasthttp.RequestCtx) {
+		path := string(ctx.Path())
+
+		switch {
+		case strings.HasSuffix(path, "/getMe"):
+			ctx.SetBodyString(`{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Alerts","username":"alerts_bot"}}`)
+		case strings.HasSuffix(path, "/editMessageText"):
+			req := &EditMessageTextRequest{}
+			json.Unmarshal(ctx.PostBody(), req)
+
+			if req.ReplyMarkup == nil || req.ReplyMarkup.InlineKeyboard[0][0].CallbackData != "ack" {
+				ctx.SetBodyString(`{"ok":false,"error_code":400,"description":"no keyboard"}`)
+				return
+			}
+
+			ctx.SetBodyString(`{"ok":true,"result":{"message_id":5,"text":"` + req.Text + `"}}`)
+		case strings.HasSuffix(path, "/sendPhoto"):
+			form, err := ctx.MultipartForm()
+			if err != nil || len(form.File["photo"]) != 1 || form.Value["chat_id"][0] != "42" {
+				ctx.SetBodyString(`{"ok":false,"error_code":400,"description":"bad form"}`)
+				return
+			}
+
+			f, _ := form.File["photo"][0].Open()
+			data, _ := ioutil.ReadAll(f)
+			f.Close()
+
+			ctx.SetBodyString(`{"ok":true,"result":{"message_id":6,"caption":"` + string(data) + `"}}`)
+		default:
+			ctx.SetBodyString(`{"ok":true,"result":true}`)
+		}
+	})
+	defer stop()
+
+	bg := context.Background()
+
+	me, err := c.GetMe(bg)
+	if err != nil || me.UserName != "alerts_bot" || !me.IsBot {
+		t.Fatalf("unexpected getMe %+v %v", me, err)
+	}
+
+	msg, err := c.EditMessageText(bg, &EditMessageTextRequest{
+		ChatID: "42", MessageID: 5, Text: "acked",
+		ReplyMarkup: NewInlineKeyboard([]InlineKeyboardButton{NewCallbackButton("Ack", "ack")}),
+	})
+	if err != nil || msg.MessageID != 5 || msg.Text != "acked" {
+		t.Fatalf("unexpected editMessageText %+v %v", msg, err)
+	}
+
+	msg, err = c.SendPhoto(bg, &MediaRequest{ChatID: "42"}, InputFile{FileName: "graph.png", Content: strings.NewReader("png")})
+	if err != nil || msg.Caption != "png" {
+		t.Fatalf("unexpected sendPhoto %+v %v", msg, err)
+	}
+
+	if err = c.DeleteMessage(bg, "42", 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.AnswerCallbackQuery(bg, &AnswerCallbackQueryRequest{CallbackQueryID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+}
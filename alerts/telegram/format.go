@@ -0,0 +1,345 @@
+package telegram
+
+import (
+	"html"
+	"strings"
+
+	"github.com/finnan444/utils/alerts"
+)
+
+// MaxMessageLength is the limit of message text in runes
+const MaxMessageLength = 4096
+
+var markdownUnescaper = strings.NewReplacer("\\_", "_", "\\*", "*", "\\`", "`", "\\[", "[")
+
+// EscapeMarkdownV2 escapes text for MarkdownV2 parse mode
+func EscapeMarkdownV2(s string) string {
+	return alerts.Escape(alerts.FormatMarkdownV2, s)
+}
+
+// EscapeMarkdown escapes text for legacy Markdown parse mode
+func EscapeMarkdown(s string) string {
+	return alerts.Escape(alerts.FormatMarkdown, s)
+}
+
+// EscapeHTML escapes text for HTML parse mode
+func EscapeHTML(s string) string {
+	return alerts.Escape(alerts.FormatHTML, s)
+}
+
+// entity is open formatting entity, opener is repeated in the next chunk
+type entity struct {
+	opener, closer string
+}
+
+// scanner tracks entities open at the end of scanned text
+type scanner struct {
+	parseMode string
+	open      []entity
+}
+
+func (s *scanner) top() string {
+	if len(s.open) == 0 {
+		return ""
+	}
+
+	return s.open[len(s.open)-1].opener
+}
+
+// toggle closes marker if it's open or opens it
+func (s *scanner) toggle(marker string) {
+	for i := len(s.open) - 1; i >= 0; i-- {
+		if s.open[i].opener == marker {
+			s.open = append(s.open[:i], s.open[i+1:]...)
+			return
+		}
+	}
+
+	s.open = append(s.open, entity{marker, marker})
+}
+
+func hasPrefix(runes []rune, i int, prefix string) bool {
+	return strings.HasPrefix(string(runes[i:min(len(runes), i+len(prefix))]), prefix)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// scan updates open entities by runes
+func (s *scanner) scan(runes []rune) {
+	switch s.parseMode {
+	case ParseModeHTML:
+		s.scanHTML(runes)
+	case "", ParseModeMarkdown, ParseModeMarkdownV2:
+		s.scanMarkdown(runes)
+	}
+}
+
+func (s *scanner) scanMarkdown(runes []rune) {
+	v2 := s.parseMode == ParseModeMarkdownV2
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if top := s.top(); strings.HasPrefix(top, "```") || top == "`" {
+			switch {
+			case r == '\\' && v2:
+				i++
+			case strings.HasPrefix(top, "```") && hasPrefix(runes, i, "```"):
+				s.open = s.open[:len(s.open)-1]
+				i += 2
+			case top == "`" && r == '`':
+				s.open = s.open[:len(s.open)-1]
+			}
+
+			continue
+		}
+
+		switch {
+		case r == '\\':
+			i++
+		case hasPrefix(runes, i, "```"):
+			end := i + 3
+			for end < len(runes) && runes[end] != '\n' {
+				end++
+			}
+
+			if strings.Contains(string(runes[i+3:end]), "```") || end == len(runes) {
+				s.open = append(s.open, entity{"```", "```"})
+				i += 2
+
+				continue
+			}
+
+			// language and line break are repeated by the next chunk
+			s.open = append(s.open, entity{string(runes[i : end+1]), "\n```"})
+			i = end
+		case r == '`':
+			s.open = append(s.open, entity{"`", "`"})
+		case v2 && (hasPrefix(runes, i, "||") || hasPrefix(runes, i, "__")):
+			s.toggle(string(runes[i : i+2]))
+			i++
+		case r == '*' || r == '_' || v2 && r == '~':
+			s.toggle(string(r))
+		}
+	}
+}
+
+func (s *scanner) scanHTML(runes []rune) {
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '<' {
+			continue
+		}
+
+		end := i
+		for end < len(runes) && runes[end] != '>' {
+			end++
+		}
+
+		if end == len(runes) {
+			return
+		}
+
+		tag := string(runes[i : end+1])
+		i = end
+
+		name := strings.TrimPrefix(strings.Trim(tag, "<>/ "), "/")
+		if j := strings.IndexAny(name, " \t\n"); j >= 0 {
+			name = name[:j]
+		}
+
+		switch {
+		case strings.HasPrefix(tag, "</"):
+			for j := len(s.open) - 1; j >= 0; j-- {
+				if s.open[j].closer == "</"+name+">" {
+					s.open = s.open[:j]
+					break
+				}
+			}
+		case strings.HasSuffix(tag, "/>"):
+		default:
+			s.open = append(s.open, entity{tag, "</" + name + ">"})
+		}
+	}
+}
+
+func (s *scanner) openers() string {
+	var b strings.Builder
+	for _, e := range s.open {
+		b.WriteString(e.opener)
+	}
+
+	return b.String()
+}
+
+func (s *scanner) closers() string {
+	var b strings.Builder
+	for i := len(s.open) - 1; i >= 0; i-- {
+		b.WriteString(s.open[i].closer)
+	}
+
+	return b.String()
+}
+
+// safeCut moves cut back so it isn't inside escape sequence, marker, HTML tag or entity
+func safeCut(runes []rune, cut int, parseMode string) int {
+	if parseMode == ParseModeHTML {
+		for i := cut - 1; i >= 0 && i >= cut-256; i-- {
+			if runes[i] == '>' || runes[i] == ';' {
+				break
+			}
+
+			if runes[i] == '<' || runes[i] == '&' && cut-i <= 10 {
+				return i
+			}
+		}
+
+		return cut
+	}
+
+	if parseMode == ParseModeNone {
+		return cut
+	}
+
+	backslashes := 0
+	for i := cut - 1; i >= 0 && runes[i] == '\\'; i-- {
+		backslashes++
+	}
+
+	if backslashes%2 == 1 {
+		cut--
+	}
+
+	for cut > 1 && cut < len(runes) && strings.ContainsRune("*_~|`", runes[cut-1]) && runes[cut] == runes[cut-1] {
+		cut--
+	}
+
+	return cut
+}
+
+// breakAt finds the last line break or space before limit in the second half of runes
+func breakAt(runes []rune, limit int) int {
+	for _, sep := range []rune{'\n', ' '} {
+		for i := limit - 1; i > limit/2; i-- {
+			if runes[i] == sep {
+				return i
+			}
+		}
+	}
+
+	return limit
+}
+
+// Split splits text into chunks of at most limit runes at line breaks or spaces.
+// Entities open at the end of chunk are closed and reopened in the next one
+func Split(text, parseMode string, limit int) []string {
+	var (
+		chunks []string
+		runes  = []rune(text)
+		s      = &scanner{parseMode: parseMode}
+	)
+
+	for len(runes) > 0 {
+		prefix := s.openers()
+		if len([]rune(prefix))+len(runes) <= limit {
+			chunks = append(chunks, prefix+string(runes))
+			break
+		}
+
+		budget := limit - len([]rune(prefix))
+		reset := false
+
+		for {
+			if budget <= 0 && reset {
+				// markup doesn't fit even without reopened entities, the text is cut as is
+				cut := min(limit, len(runes))
+				chunks = append(chunks, string(runes[:cut]))
+				runes, s = runes[cut:], &scanner{parseMode: parseMode}
+
+				break
+			}
+
+			if budget <= 0 {
+				// entities are too long to be reopened
+				s.open, prefix, budget, reset = nil, "", limit, true
+			}
+
+			cut := safeCut(runes, breakAt(runes, min(budget, len(runes))), parseMode)
+			if cut <= 0 {
+				cut = min(budget, len(runes))
+			}
+
+			next := &scanner{parseMode: parseMode, open: append([]entity(nil), s.open...)}
+			next.scan(runes[:cut])
+
+			chunk := prefix + string(runes[:cut]) + next.closers()
+			if overflow := len([]rune(chunk)) - limit; overflow > 0 {
+				budget -= overflow
+				continue
+			}
+
+			chunks = append(chunks, chunk)
+			runes, s = runes[cut:], next
+
+			if len(runes) > 0 && runes[0] == '\n' {
+				runes = runes[1:]
+			}
+
+			break
+		}
+	}
+
+	return chunks
+}
+
+// StripMarkup removes formatting of the parse mode leaving plain text
+func StripMarkup(text, parseMode string) string {
+	switch parseMode {
+	case ParseModeHTML:
+		var b strings.Builder
+
+		inTag := false
+		for _, r := range text {
+			switch {
+			case r == '<':
+				inTag = true
+			case r == '>' && inTag:
+				inTag = false
+			case !inTag:
+				b.WriteRune(r)
+			}
+		}
+
+		return html.UnescapeString(b.String())
+	case "", ParseModeMarkdown:
+		// markers of legacy Markdown are readable as is
+		return markdownUnescaper.Replace(text)
+	case ParseModeMarkdownV2:
+		var (
+			b       strings.Builder
+			escaped bool
+		)
+
+		for _, r := range text {
+			switch {
+			case escaped:
+				b.WriteRune(r)
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case strings.ContainsRune("*_`~|", r):
+			default:
+				b.WriteRune(r)
+			}
+		}
+
+		return b.String()
+	}
+
+	return text
+}
@@ -0,0 +1,126 @@
+package telegram
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitPlain(t *testing.T) {
+	text := strings.Repeat("word ", 10) + "\n" + strings.Repeat("next ", 10)
+	chunks := Split(text, ParseModeNone, 40)
+
+	for _, chunk := range chunks {
+		if len([]rune(chunk)) > 40 {
+			t.Fatalf("chunk is too long %q", chunk)
+		}
+	}
+
+	if strings.Join(strings.Fields(strings.Join(chunks, " ")), " ") != strings.Join(strings.Fields(text), " ") {
+		t.Fatalf("text is changed %q", chunks)
+	}
+}
+
+func TestSplitCodeBlock(t *testing.T) {
+	lines := make([]string, 20)
+	for i := range lines {
+		lines[i] = "line number " + string(rune('a'+i))
+	}
+
+	text := "*Stack*\n```go\n" + strings.Join(lines, "\n") + "\n```"
+	chunks := Split(text, ParseModeMarkdownV2, 100)
+
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %q", chunks)
+	}
+
+	for i, chunk := range chunks {
+		if len([]rune(chunk)) > 100 {
+			t.Fatalf("chunk %d is too long %q", i, chunk)
+		}
+
+		if strings.Count(chunk, "```")%2 != 0 {
+			t.Fatalf("chunk %d has unbalanced code block %q", i, chunk)
+		}
+
+		if i > 0 && !strings.HasPrefix(chunk, "```go\n") {
+			t.Fatalf("chunk %d doesn't reopen code block %q", i, chunk)
+		}
+	}
+}
+
+func TestSplitHTML(t *testing.T) {
+	text := "<b>" + strings.Repeat("bold &amp; text ", 10) + "</b> tail"
+	chunks := Split(text, ParseModeHTML, 50)
+
+	for i, chunk := range chunks {
+		if len([]rune(chunk)) > 50 {
+			t.Fatalf("chunk %d is too long %q", i, chunk)
+		}
+
+		if strings.Count(chunk, "<b>") != strings.Count(chunk, "</b>") {
+			t.Fatalf("chunk %d has unbalanced tags %q", i, chunk)
+		}
+
+		if strings.Contains(chunk, "&amp") != strings.Contains(chunk, "&amp;") {
+			t.Fatalf("chunk %d cuts entity %q", i, chunk)
+		}
+	}
+}
+
+func TestSplitEscape(t *testing.T) {
+	for _, chunk := range Split("aaaa\\.bbbb", ParseModeMarkdownV2, 5) {
+		if strings.HasSuffix(chunk, "\\") {
+			t.Fatalf("escape sequence is cut %q", chunk)
+		}
+	}
+}
+
+func TestStripMarkup(t *testing.T) {
+	for _, test := range []struct {
+		text, parseMode, plain string
+	}{
+		{"*bold* a\\_b \\(1\\.5\\)", ParseModeMarkdownV2, "bold a_b (1.5)"},
+		{"<b>a &lt; b</b>", ParseModeHTML, "a < b"},
+		{"*bold* a\\_b", ParseModeMarkdown, "*bold* a_b"},
+	} {
+		if s := StripMarkup(test.text, test.parseMode); s != test.plain {
+			t.Errorf("%s: expected %q, got %q", test.parseMode, test.plain, s)
+		}
+	}
+}
+
+func TestSplitEdgeCases(t *testing.T) {
+	if chunks := Split("||~\n<&&_|\n*\n<&~/~;|", ParseModeMarkdownV2, 10); len(chunks) == 0 {
+		t.Fatal("expected chunks")
+	}
+
+	random := rand.New(rand.NewSource(1))
+	alphabet := []rune("ab *_~|`\\[]()<>&;/\n")
+
+	for i := 0; i < 2000; i++ {
+		runes := make([]rune, 1+random.Intn(40))
+		for j := range runes {
+			runes[j] = alphabet[random.Intn(len(alphabet))]
+		}
+
+		text, limit := string(runes), 1+random.Intn(12)
+
+		for _, parseMode := range []string{ParseModeNone, ParseModeMarkdown, ParseModeMarkdownV2, ParseModeHTML} {
+			done := make(chan []string, 1)
+			go func() { done <- Split(text, parseMode, limit) }()
+
+			select {
+			case chunks := <-done:
+				for _, chunk := range chunks {
+					if len([]rune(chunk)) > limit {
+						t.Fatalf("%s %q limit %d: chunk is too long %q", parseMode, text, limit, chunk)
+					}
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s %q limit %d: split doesn't return", parseMode, text, limit)
+			}
+		}
+	}
+}
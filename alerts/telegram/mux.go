@@ -0,0 +1,190 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Update types
+const (
+	UpdateMessage            = "message"
+	UpdateEditedMessage      = "edited_message"
+	UpdateChannelPost        = "channel_post"
+	UpdateEditedChannelPost  = "edited_channel_post"
+	UpdateInlineQuery        = "inline_query"
+	UpdateChosenInlineResult = "chosen_inline_result"
+	UpdateCallbackQuery      = "callback_query"
+)
+
+// Handler handles update
+type Handler func(ctx context.Context, update *Update)
+
+// CommandHandler handles bot command, args is the text after command
+type CommandHandler func(ctx context.Context, msg *Message, args string)
+
+// CallbackHandler handles callback query of inline keyboard button
+type CallbackHandler func(ctx context.Context, query *CallbackQuery)
+
+// Type returns type of update or empty string
+func (u *Update) Type() string {
+	switch {
+	case u.Message != nil:
+		return UpdateMessage
+	case u.EditedMessage != nil:
+		return UpdateEditedMessage
+	case u.ChannelPost != nil:
+		return UpdateChannelPost
+	case u.EditedChannelPost != nil:
+		return UpdateEditedChannelPost
+	case u.InlineQuery != nil:
+		return UpdateInlineQuery
+	case u.ChosenInlineResult != nil:
+		return UpdateChosenInlineResult
+	case u.CallbackQuery != nil:
+		return UpdateCallbackQuery
+	}
+
+	return ""
+}
+
+// Command returns bot command of message without slash and bot username and its args,
+// /mute@alerts_bot 1h is "mute", "alerts_bot" and "1h"
+func (m *Message) Command() (command, bot, args string) {
+	if !strings.HasPrefix(m.Text, "/") {
+		return "", "", ""
+	}
+
+	command = m.Text[1:]
+	if i := strings.IndexAny(command, " \n\t"); i >= 0 {
+		command, args = command[:i], strings.TrimSpace(command[i+1:])
+	}
+
+	if i := strings.IndexByte(command, '@'); i >= 0 {
+		command, bot = command[:i], command[i+1:]
+	}
+
+	return command, bot, args
+}
+
+// Mux dispatches updates to handlers by update type, bot command and callback data prefix.
+// Commands are handled before message handlers, updates without handlers go to the default one
+type Mux struct {
+	sync.RWMutex
+	username  string
+	commands  map[string]CommandHandler
+	callbacks map[string]CallbackHandler
+	types     map[string]Handler
+	fallback  Handler
+}
+
+// NewMux creates mux
+func NewMux() *Mux {
+	return &Mux{
+		commands:  make(map[string]CommandHandler),
+		callbacks: make(map[string]CallbackHandler),
+		types:     make(map[string]Handler),
+	}
+}
+
+// SetUsername sets bot username, commands addressed to other bots as /status@other_bot are ignored
+func (m *Mux) SetUsername(username string) {
+	m.Lock()
+	m.username = strings.TrimPrefix(username, "@")
+	m.Unlock()
+}
+
+// HandleCommand registers handler of command, leading slash is optional
+func (m *Mux) HandleCommand(command string, handler CommandHandler) {
+	m.Lock()
+	m.commands[strings.ToLower(strings.TrimPrefix(command, "/"))] = handler
+	m.Unlock()
+}
+
+// HandleCallback registers handler of callback queries with data starting with prefix,
+// the longest prefix wins
+func (m *Mux) HandleCallback(prefix string, handler CallbackHandler) {
+	m.Lock()
+	m.callbacks[prefix] = handler
+	m.Unlock()
+}
+
+// Handle registers handler of update type
+func (m *Mux) Handle(updateType string, handler Handler) {
+	m.Lock()
+	m.types[updateType] = handler
+	m.Unlock()
+}
+
+// HandleDefault registers handler of updates without other handlers
+func (m *Mux) HandleDefault(handler Handler) {
+	m.Lock()
+	m.fallback = handler
+	m.Unlock()
+}
+
+// Dispatch calls handler of update. Handler is looked up under the lock
+// and called after it is released, so handlers may register other handlers
+func (m *Mux) Dispatch(ctx context.Context, update *Update) {
+	if handle := m.route(update); handle != nil {
+		handle(ctx)
+	}
+}
+
+func (m *Mux) route(update *Update) func(ctx context.Context) {
+	m.RLock()
+	defer m.RUnlock()
+
+	if msg := update.Message; msg != nil {
+		if command, bot, args := msg.Command(); command != "" && (bot == "" || m.username == "" || strings.EqualFold(bot, m.username)) {
+			if handler, ok := m.commands[strings.ToLower(command)]; ok {
+				return func(ctx context.Context) { handler(ctx, msg, args) }
+			}
+		}
+	}
+
+	if query := update.CallbackQuery; query != nil {
+		var (
+			handler CallbackHandler
+			longest = -1
+		)
+
+		for prefix, h := range m.callbacks {
+			if len(prefix) > longest && strings.HasPrefix(query.Data, prefix) {
+				handler, longest = h, len(prefix)
+			}
+		}
+
+		if handler != nil {
+			return func(ctx context.Context) { handler(ctx, query) }
+		}
+	}
+
+	handler, ok := m.types[update.Type()]
+	if !ok {
+		handler = m.fallback
+	}
+
+	if handler == nil {
+		return nil
+	}
+
+	return func(ctx context.Context) { handler(ctx, update) }
+}
+
+// Reply sends text to the chat of message as reply to it
+func (c *Client) Reply(ctx context.Context, msg *Message, text string) error {
+	if msg == nil || msg.Chat == nil {
+		return fmt.Errorf("[Telegram] Can't reply to message without chat")
+	}
+
+	_, err := c.SendMessage(ctx, &SendMessageRequest{
+		ChatID:           strconv.FormatInt(msg.Chat.ID, 10),
+		Text:             text,
+		ReplyToMessageID: msg.MessageID,
+	})
+
+	return err
+}
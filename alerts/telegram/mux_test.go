@@ -0,0 +1,101 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMessageCommand(t *testing.T) {
+	for text, expected := range map[string][3]string{
+		"/status":               {"status", "", ""},
+		"/mute@alerts_bot 1h":   {"mute", "alerts_bot", "1h"},
+		"/mute  2h  db":         {"mute", "", "2h  db"},
+		"hello /status":         {"", "", ""},
+		"/silence\nall of them": {"silence", "", "all of them"},
+	} {
+		command, bot, args := (&Message{Text: text}).Command()
+		if [3]string{command, bot, args} != expected {
+			t.Errorf("%q: expected %q, got %q", text, expected, [3]string{command, bot, args})
+		}
+	}
+}
+
+func TestMuxDispatch(t *testing.T) {
+	var got []string
+
+	mux := NewMux()
+	mux.SetUsername("@alerts_bot")
+	mux.HandleCommand("/mute", func(ctx context.Context, msg *Message, args string) {
+		d, _ := time.ParseDuration(args)
+		got = append(got, "mute "+d.String())
+	})
+	mux.HandleCallback("ack:", func(ctx context.Context, query *CallbackQuery) {
+		got = append(got, "ack "+query.Data)
+	})
+	mux.HandleCallback("ack:all", func(ctx context.Context, query *CallbackQuery) {
+		got = append(got, "ack all")
+	})
+	mux.Handle(UpdateMessage, func(ctx context.Context, update *Update) {
+		got = append(got, "message "+update.Message.Text)
+	})
+	mux.HandleDefault(func(ctx context.Context, update *Update) {
+		got = append(got, "default "+update.Type())
+	})
+
+	for _, update := range []*Update{
+		{Message: &Message{Text: "/mute@alerts_bot 1h"}},
+		{Message: &Message{Text: "/mute@other_bot 1h"}},
+		{Message: &Message{Text: "/unknown"}},
+		{CallbackQuery: &CallbackQuery{Data: "ack:42"}},
+		{CallbackQuery: &CallbackQuery{Data: "ack:all"}},
+		{InlineQuery: &InlineQuery{}},
+	} {
+		mux.Dispatch(context.Background(), update)
+	}
+
+	expected := []string{
+		"mute 1h0m0s", "message /mute@other_bot 1h", "message /unknown",
+		"ack ack:42", "ack all", "default inline_query",
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Fatalf("expected %q, got %q", expected, got)
+		}
+	}
+}
+
+func TestMuxDispatchRegistersHandler(t *testing.T) {
+	done := make(chan struct{})
+
+	mux := NewMux()
+	mux.HandleCommand("/start", func(ctx context.Context, msg *Message, args string) {
+		mux.HandleCommand("/next", func(ctx context.Context, msg *Message, args string) {
+			close(done)
+		})
+	})
+
+	go func() {
+		mux.Dispatch(context.Background(), &Update{Message: &Message{Text: "/start"}})
+		mux.Dispatch(context.Background(), &Update{Message: &Message{Text: "/next"}})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler registered from handler is not called")
+	}
+}
+
+func TestReplyWithoutChat(t *testing.T) {
+	c := NewClient(&Config{}, 1)
+
+	if err := c.Reply(context.Background(), &Message{Text: "hi"}, "hello"); err == nil {
+		t.Fatal("expected error on message without chat")
+	}
+}
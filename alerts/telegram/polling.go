@@ -0,0 +1,221 @@
+package telegram
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+)
+
+// Modes of receiving updates
+const (
+	ModeWebhook = "webhook"
+	ModePolling = "polling"
+)
+
+// Polling defaults
+const (
+	DefaultPollTimeout = 30 * time.Second
+	DefaultMinBackoff  = time.Second
+	DefaultMaxBackoff  = time.Minute
+)
+
+const methodGetUpdates = "getUpdates"
+
+// GetUpdatesRequest is getUpdates parameters
+type GetUpdatesRequest struct {
+	Offset int `json:"offset,omitempty"`
+	Limit  int `json:"limit,omitempty"`
+	// Timeout of long polling in seconds
+	Timeout        int      `json:"timeout,omitempty"`
+	AllowedUpdates []string `json:"allowed_updates,omitempty"`
+}
+
+// GetUpdates receives updates, the request lasts up to req.Timeout seconds
+func (c *Client) GetUpdates(ctx context.Context, req *GetUpdatesRequest) ([]Update, error) {
+	var updates []Update
+	timeout := c.timeout + time.Duration(req.Timeout)*time.Second
+
+	return updates, c.callTimeout(ctx, methodGetUpdates, req, &updates, timeout)
+}
+
+// PollConfig describes polling
+type PollConfig struct {
+	// Timeout of long polling, rounded up to seconds
+	Timeout time.Duration
+	Limit   int
+	// OffsetFile stores offset between restarts, empty disables it
+	OffsetFile     string
+	AllowedUpdates []string
+	// MinBackoff delay after error doubled up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Poll receives updates with getUpdates and dispatches them to mux until ctx is done.
+// Webhook must be deleted before polling
+func (c *Client) Poll(ctx context.Context, mux *Mux, config PollConfig) {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultPollTimeout
+	}
+
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = DefaultMinBackoff
+	}
+
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+
+	if config.AllowedUpdates == nil {
+		config.AllowedUpdates = DefaultAllowedUpdates
+	}
+
+	req := &GetUpdatesRequest{
+		Offset:         readOffset(config.OffsetFile),
+		Limit:          config.Limit,
+		Timeout:        int((config.Timeout + time.Second - 1) / time.Second),
+		AllowedUpdates: config.AllowedUpdates,
+	}
+
+	backoff := config.MinBackoff
+
+	for ctx.Err() == nil {
+		updates, err := c.getUpdates(ctx, *req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			delay := backoff
+			if retryAfter := RetryAfter(err); retryAfter > delay {
+				delay = retryAfter
+			}
+
+			log.Printf("[Telegram] Error getting updates, retrying in %v %v", delay, err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			if backoff *= 2; backoff > config.MaxBackoff {
+				backoff = config.MaxBackoff
+			}
+
+			continue
+		}
+
+		backoff = config.MinBackoff
+
+		for i := range updates {
+			mux.Dispatch(ctx, &updates[i])
+			req.Offset = updates[i].UpdateID + 1
+		}
+
+		if len(updates) > 0 {
+			writeOffset(config.OffsetFile, req.Offset)
+		}
+	}
+}
+
+// getUpdates returns as soon as ctx is done, updates of abandoned request are received again after restart
+func (c *Client) getUpdates(ctx context.Context, req GetUpdatesRequest) ([]Update, error) {
+	type result struct {
+		updates []Update
+		err     error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		updates, err := c.GetUpdates(ctx, &req)
+		done <- result{updates, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-done:
+		return r.updates, r.err
+	}
+}
+
+func readOffset(path string) int {
+	if path == "" {
+		return 0
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[Telegram] Error reading offset %v", err)
+		}
+
+		return 0
+	}
+
+	offset, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		log.Printf("[Telegram] Error parsing offset %v", err)
+	}
+
+	return offset
+}
+
+// writeOffset replaces offset file atomically
+func writeOffset(path string, offset int) {
+	if path == "" {
+		return
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.Itoa(offset)), 0644); err != nil {
+		log.Printf("[Telegram] Error writing offset %v", err)
+		return
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("[Telegram] Error writing offset %v", err)
+	}
+}
+
+// StartUpdates receives updates in the mode of Config. Webhook mode adds webhook route at path
+// to transport.DefaultRouter and registers Config.WebhookURL unless it's empty. Polling mode deletes webhook and polls
+// in background until ctx is done
+func (mngr *Alert) StartUpdates(ctx context.Context, mux *Mux, path string, middlewares ...transport.Middleware) {
+	mngr.StartUpdatesOn(ctx, transport.DefaultRouter, mux, path, middlewares...)
+}
+
+// StartUpdatesOn does the same as StartUpdates with webhook route added to router
+func (mngr *Alert) StartUpdatesOn(ctx context.Context, router *transport.Router, mux *Mux, path string, middlewares ...transport.Middleware) {
+	if mngr.config.Mode != ModePolling {
+		mngr.RegisterWebhookRouteOn(router, path, mux, middlewares...)
+
+		if mngr.config.WebhookURL == "" {
+			log.Printf("[Telegram] Webhook URL is empty, webhook is not registered")
+			return
+		}
+
+		mngr.RegisterWebhook(mngr.config.WebhookURL)
+
+		return
+	}
+
+	deleteCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+	if err := mngr.DeleteWebhook(deleteCtx, false); err != nil {
+		log.Printf("[Telegram] error deleting webhook %v", err)
+	}
+	cancel()
+
+	go mngr.Poll(ctx, mux, PollConfig{
+		Timeout:    time.Duration(mngr.config.PollTimeoutSeconds) * time.Second,
+		OffsetFile: mngr.config.OffsetFile,
+	})
+}
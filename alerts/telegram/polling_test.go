@@ -0,0 +1,167 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport/transporttest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestPoll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegram")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	offsetFile := filepath.Join(dir, "offset")
+	ioutil.WriteFile(offsetFile, []byte("10"), 0644)
+
+	var (
+		mu       sync.Mutex
+		offsets  []int
+		timeouts []int
+		calls    int
+	)
+
+	c, stop := startFakeAPI(t, func(ctx *fasthttp.RequestCtx) {
+		req := &GetUpdatesRequest{}
+		json.Unmarshal(ctx.PostBody(), req)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		calls++
+		offsets = append(offsets, req.Offset)
+		timeouts = append(timeouts, req.Timeout)
+
+		switch calls {
+		case 1:
+			ctx.SetBodyString(`{"ok":true,"result":[{"update_id":10,"message":{"text":"/status"}},{"update_id":11,"message":{"text":"hi"}}]}`)
+		case 2:
+			ctx.SetStatusCode(fasthttp.StatusBadGateway)
+			ctx.SetBodyString(`{"ok":false,"error_code":502,"description":"Bad Gateway"}`)
+		default:
+			ctx.SetBodyString(`{"ok":true,"result":[]}`)
+		}
+	})
+	defer stop()
+
+	received := make(chan string, 10)
+	mux := NewMux()
+	mux.HandleDefault(func(ctx context.Context, update *Update) {
+		received <- update.Message.Text
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		c.Poll(ctx, mux, PollConfig{Timeout: 100 * time.Millisecond, OffsetFile: offsetFile, MinBackoff: 10 * time.Millisecond})
+		close(done)
+	}()
+
+	if text := <-received; text != "/status" {
+		t.Fatalf("unexpected update %q", text)
+	}
+
+	<-received
+
+	for {
+		mu.Lock()
+		n := calls
+		mu.Unlock()
+
+		if n >= 3 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("polling is not stopped")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if offsets[0] != 10 || offsets[1] != 12 || offsets[2] != 12 {
+		t.Fatalf("unexpected offsets %v", offsets)
+	}
+
+	if timeouts[0] != 1 {
+		t.Fatalf("expected timeout to be rounded up to 1 second, got %v", timeouts)
+	}
+
+	if data, _ := ioutil.ReadFile(offsetFile); string(data) != strconv.Itoa(12) {
+		t.Fatalf("unexpected stored offset %q", data)
+	}
+}
+
+func TestStartUpdatesWithoutWebhookURL(t *testing.T) {
+	var calls int32
+
+	c, stop := startFakeAPI(t, func(ctx *fasthttp.RequestCtx) {
+		atomic.AddInt32(&calls, 1)
+		ctx.SetBodyString(`{"ok":true,"result":true}`)
+	})
+	defer stop()
+
+	a := InitTelegram(&Config{}, 1)
+	a.Client = c
+
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+
+	a.StartUpdatesOn(context.Background(), srv.Router, NewMux(), "/internal/telegram/test")
+
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Fatalf("expected webhook not to be touched, got %d calls", n)
+	}
+
+	srv.Post("/internal/telegram/test").Body([]byte(`{"update_id":1}`), "application/json").Do().AssertStatus(fasthttp.StatusOK)
+}
+
+func TestStartUpdatesPollingDeletesWebhook(t *testing.T) {
+	deleted := make(chan struct{}, 1)
+
+	c, stop := startFakeAPI(t, func(ctx *fasthttp.RequestCtx) {
+		if strings.HasSuffix(string(ctx.Path()), methodDeleteWebhook) {
+			deleted <- struct{}{}
+			ctx.SetBodyString(`{"ok":true,"result":true}`)
+			return
+		}
+
+		ctx.SetBodyString(`{"ok":true,"result":[]}`)
+	})
+	defer stop()
+
+	a := InitTelegram(&Config{Mode: ModePolling, PollTimeoutSeconds: 1}, 1)
+	a.Client = c
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a.StartUpdates(ctx, NewMux(), "/internal/telegram/test")
+
+	select {
+	case <-deleted:
+	case <-time.After(time.Second):
+		t.Fatal("webhook is not deleted in polling mode")
+	}
+}
@@ -1,22 +1,25 @@
 package telegram
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/finnan444/utils/math/ints"
-	"github.com/finnan444/utils/transport"
-	"github.com/valyala/fasthttp"
+	"github.com/finnan444/utils/alerts"
 )
 
-// Pathes
+const maxCaptionLength = 1024
+
+// Parse modes
 const (
-	pathSendMessage    = "/sendMessage"
-	pathGetWebhookInfo = "/getWebhookInfo"
-	pathSetWebhook     = "/setWebhook"
+	ParseModeMarkdown   = "Markdown"
+	ParseModeMarkdownV2 = "MarkdownV2"
+	ParseModeHTML       = "HTML"
+	// ParseModeNone sends plain text
+	ParseModeNone = "none"
 )
 
 // Config describes telegram config
@@ -25,38 +28,103 @@ type Config struct {
 	Chats    map[string]string `json:"chats"`
 	BotID    string            `json:"botId"`
 	BotToken string            `json:"botToken"`
+	// ParseMode is Markdown by default
+	ParseMode string `json:"parseMode"`
+	// Template of alerts, see alerts.DefaultTemplateText
+	Template string `json:"template"`
+	// DocumentThreshold alerts longer than this number of runes are sent as a text document,
+	// 0 splits them into several messages
+	DocumentThreshold int `json:"documentThreshold"`
+	// Mode of receiving updates by StartUpdates, webhook by default
+	Mode string `json:"mode"`
+	// WebhookURL is registered in webhook mode
+	WebhookURL string `json:"webhookUrl"`
+	// WebhookSecret is sent by Telegram in X-Telegram-Bot-Api-Secret-Token header
+	WebhookSecret string `json:"webhookSecret"`
+	// OffsetFile stores offset of polling mode between restarts
+	OffsetFile string `json:"offsetFile"`
+	// PollTimeoutSeconds of long polling, 30 by default
+	PollTimeoutSeconds int `json:"pollTimeoutSeconds"`
 }
 
 // Alert is Telegram structure
 type Alert struct {
-	config  *Config
-	timeout time.Duration
-	botURL  string
-}
-
-type getWebhookInfoResponse struct {
-	URL                  string   `json:"url"`
-	HasCustomCertificate bool     `json:"has_custom_certificate"`
-	PendingUpdateCount   int      `json:"pending_update_count"`
-	LastErrorDate        int      `json:"last_error_date"`
-	LastErrorMessage     string   `json:"last_error_message"`
-	MaxConnections       int      `json:"max_connections"`
-	AllowedUpdates       []string `json:"allowed_updates"`
+	*Client
+	config   *Config
+	template *alerts.Template
+	// plain renders documents
+	plain *alerts.Template
+	acks  *Acks
 }
 
 // InitTelegram initialize Telegram
 func InitTelegram(config *Config, connectionTimeoutSeconds time.Duration) *Alert {
+	format := formatOf(config.ParseMode)
+
+	template, err := alerts.TemplateFor(format, config.Template)
+	if err != nil {
+		log.Printf("[Telegram] Error parsing template, default is used %v", err)
+		template = alerts.DefaultTemplate(format)
+	}
+
+	plain, err := alerts.TemplateFor(alerts.FormatPlain, config.Template)
+	if err != nil {
+		plain = alerts.DefaultTemplate(alerts.FormatPlain)
+	}
+
 	return &Alert{
-		botURL:  fmt.Sprintf(config.URL, config.BotID, config.BotToken),
-		timeout: connectionTimeoutSeconds * time.Second,
-		config:  config,
+		Client:   NewClient(config, connectionTimeoutSeconds),
+		config:   config,
+		template: template,
+		plain:    plain,
+	}
+}
+
+// parseMode returns parse mode of messages
+func (mngr *Alert) parseMode() string {
+	if mngr.config.ParseMode == "" {
+		return ParseModeMarkdown
 	}
+
+	return mngr.config.ParseMode
+}
+
+func formatOf(parseMode string) alerts.Format {
+	switch parseMode {
+	case "", ParseModeMarkdown:
+		return alerts.FormatMarkdown
+	case ParseModeMarkdownV2:
+		return alerts.FormatMarkdownV2
+	case ParseModeHTML:
+		return alerts.FormatHTML
+	}
+
+	return alerts.FormatPlain
+}
+
+// SetAcks enables Ack, Silence and Resolve buttons of alerts, acks must be registered in the mux of updates
+func (mngr *Alert) SetAcks(acks *Acks) {
+	mngr.acks = acks
 }
 
 // PostMessage do a post request to Telegram with message param and using infoLevel
 func (mngr *Alert) PostMessage(message string, infoLevel string) error {
+	return mngr.Send(context.Background(), &alerts.Alert{
+		Message: message,
+		Labels:  map[string]string{alerts.LabelChat: infoLevel},
+	})
+}
+
+// Send posts alert to the chat of its chat label or severity name,
+// the label may be a key of Config.Chats or a numeric chat id
+func (mngr *Alert) Send(ctx context.Context, alert *alerts.Alert) error {
 	var err error
 
+	infoLevel := alert.Label(alerts.LabelChat)
+	if infoLevel == "" {
+		infoLevel = alert.Severity.String()
+	}
+
 	chatID, ok := mngr.config.Chats[infoLevel]
 	if !ok {
 		if _, err = strconv.Atoi(infoLevel); err == nil {
@@ -66,14 +134,52 @@ func (mngr *Alert) PostMessage(message string, infoLevel string) error {
 		}
 	}
 	if chatID != "" {
-		runes := []rune(message)
-		client := transport.GetHTTPClient()
-		defer transport.PutHTTPClient(client)
+		text, err := mngr.template.Render(alert)
+		if err != nil {
+			log.Printf("[Telegram] Error rendering alert %v", err)
+			return err
+		}
+
+		fingerprint := alerts.Fingerprint(alert)
+		if mngr.acks != nil && alert.Label(alerts.LabelStatus) == alerts.StatusResolved {
+			mngr.acks.resolved(ctx, fingerprint)
+		}
+
+		if threshold := mngr.config.DocumentThreshold; threshold > 0 && len([]rune(text)) > threshold {
+			return mngr.sendAlertDocument(ctx, chatID, alert)
+		}
+
+		chunks := Split(text, mngr.parseMode(), MaxMessageLength)
+		for i, chunk := range chunks {
+			req := &SendMessageRequest{ChatID: chatID, Text: chunk, ParseMode: mngr.parseMode()}
+			if req.ParseMode == ParseModeNone {
+				req.ParseMode = ""
+			}
 
-		for i, l := 0, len(runes); i < l; i += 4096 {
-			if err := mngr.makeRequest(chatID, string(runes[i:ints.MinInt(l, i+4096)]), client); err != nil {
+			tracked := mngr.acks != nil && i == len(chunks)-1 && alert.Label(alerts.LabelStatus) != alerts.StatusResolved
+			if tracked {
+				req.ReplyMarkup = mngr.acks.keyboard(ackKey(fingerprint), mngr.acks.status(fingerprint))
+			}
+
+			msg, err := mngr.sendMessage(ctx, req)
+			if IsParseError(err) {
+				log.Printf("[Telegram] Falling back to plain text")
+				req.Text, req.ParseMode = StripMarkup(chunk, req.ParseMode), ""
+				msg, err = mngr.sendMessage(ctx, req)
+			}
+
+			if err != nil {
 				return err
 			}
+
+			if tracked && msg != nil {
+				parseMode := req.ParseMode
+				if parseMode == "" {
+					parseMode = ParseModeNone
+				}
+
+				mngr.acks.track(fingerprint, chatID, msg, alert.Title, req.Text, parseMode)
+			}
 		}
 	} else {
 		log.Printf("[Telegram] Unknown info level %s", infoLevel)
@@ -83,93 +189,46 @@ func (mngr *Alert) PostMessage(message string, infoLevel string) error {
 	return nil
 }
 
-func (mngr *Alert) makeRequest(chatID string, text string, client *fasthttp.Client) error {
-	req := fasthttp.AcquireRequest()
-	defer fasthttp.ReleaseRequest(req)
-
-	req.SetRequestURI(mngr.botURL + pathSendMessage)
-	req.Header.SetMethod(fasthttp.MethodPost)
-	req.Header.SetContentType(transport.ApplicationJSON)
-	reqBody := map[string]string{
-		"chat_id":    chatID,
-		"parse_mode": "Markdown",
-		"text":       text,
-	}
+// sendMessage sends message waiting once for flood control if deadline of ctx allows
+func (mngr *Alert) sendMessage(ctx context.Context, req *SendMessageRequest) (*Message, error) {
+	msg, err := mngr.SendMessage(ctx, req)
 
-	reqBytes, err := json.Marshal(&reqBody)
-	if err != nil {
-		log.Printf("[Telegram] Error marshaling request %v", err)
-		return err
-	}
+	if delay := RetryAfter(err); delay > 0 {
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return nil, err
+		}
 
-	req.SetBody(reqBytes)
-	resp := fasthttp.AcquireResponse()
-	defer fasthttp.ReleaseResponse(resp)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
 
-	err = client.DoTimeout(req, resp, mngr.timeout)
-	if err != nil {
-		log.Printf("[Telegram] error posting message %v", err)
-		return err
-	} else if resp.StatusCode() != 200 {
-		log.Printf("[Telegram] error posting message %s", resp.Body())
-		return fmt.Errorf("[Telegram] error posting message %s", resp.Body())
+		msg, err = mngr.SendMessage(ctx, req)
 	}
 
-	return nil
+	return msg, err
 }
 
-// RegisterWebhook do smth
-func (mngr *Alert) RegisterWebhook(url string) {
-	client := transport.GetHTTPClient()
-	sc, body, err := client.GetTimeout(nil, mngr.botURL+pathGetWebhookInfo, time.Second*5)
-	transport.PutHTTPClient(client)
+// sendAlertDocument sends alert rendered as plain text in a document, title or the first line is the caption
+func (mngr *Alert) sendAlertDocument(ctx context.Context, chatID string, alert *alerts.Alert) error {
+	text, err := mngr.plain.Render(alert)
 	if err != nil {
-		log.Printf("[Telegram] Error checking webhook info %v", err)
-	} else {
-		if sc == 200 {
-			response := &getWebhookInfoResponse{}
-			err = json.Unmarshal(body, response)
-			if err != nil {
-				log.Printf("[Telegram] Error unmarshalling webhook info response %v", err)
-			} else {
-				if response.URL == "" || response.URL != url {
-					// TODO: delete webhook
-					if url != "" {
-						mngr.setWebhook(url)
-					}
-				}
-			}
-		} else {
-			log.Printf("[Telegram] Error quering webhook info code %d\nbody: %s", sc, body)
-		}
+		log.Printf("[Telegram] Error rendering alert %v", err)
+		return err
 	}
-}
 
-// setWebhook do smth
-func (mngr *Alert) setWebhook(url string) {
-	client := transport.GetHTTPClient()
-	req := fasthttp.AcquireRequest()
-	req.SetRequestURI(mngr.botURL + pathSetWebhook)
-	req.Header.SetMethod(fasthttp.MethodPost)
-	req.Header.SetContentType(transport.ApplicationJSON)
-	reqBody := map[string]interface{}{
-		"url":             url,
-		"allowed_updates": []string{"message"},
+	caption := alert.Title
+	if caption == "" {
+		caption = strings.SplitN(text, "\n", 2)[0]
 	}
-	reqBytes, err := json.Marshal(&reqBody)
-	if err != nil {
-		log.Printf("[Telegram] Error marshaling request %v", err)
-	} else {
-		req.SetBody(reqBytes)
-		resp := fasthttp.AcquireResponse()
-		err = client.DoTimeout(req, resp, mngr.timeout)
-		if err != nil {
-			log.Printf("[Telegram] error setting webhook %v", err)
-		} else if resp.StatusCode() != 200 {
-			log.Printf("[Telegram] error setting webhook %s", resp.Body())
-		}
-		fasthttp.ReleaseResponse(resp)
+
+	if runes := []rune(caption); len(runes) > maxCaptionLength {
+		caption = string(runes[:maxCaptionLength-1]) + "…"
 	}
-	fasthttp.ReleaseRequest(req)
-	transport.PutHTTPClient(client)
+
+	_, err = mngr.SendDocument(ctx, &MediaRequest{ChatID: chatID, Caption: caption},
+		InputFile{FileName: "alert.txt", Content: strings.NewReader(text)})
+
+	return err
 }
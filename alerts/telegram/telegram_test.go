@@ -0,0 +1,92 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/finnan444/utils/alerts"
+	"github.com/valyala/fasthttp"
+)
+
+type fakeBot struct {
+	sync.Mutex
+	messages  []map[string]string
+	documents []string
+}
+
+func startFakeBot(t *testing.T) (*fakeBot, string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bot := &fakeBot{}
+
+	go fasthttp.Serve(ln, func(ctx *fasthttp.RequestCtx) {
+		bot.Lock()
+		defer bot.Unlock()
+
+		switch {
+		case bytes.HasSuffix(ctx.Path(), []byte(methodSendMessage)):
+			body := map[string]string{}
+			json.Unmarshal(ctx.PostBody(), &body)
+
+			if body["parse_mode"] != "" && strings.Contains(body["text"], "broken") {
+				ctx.SetStatusCode(fasthttp.StatusBadRequest)
+				ctx.SetBodyString(`{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities"}`)
+
+				return
+			}
+
+			bot.messages = append(bot.messages, body)
+		case bytes.HasSuffix(ctx.Path(), []byte(methodSendDocument)):
+			form, err := ctx.MultipartForm()
+			if err != nil {
+				ctx.SetStatusCode(fasthttp.StatusBadRequest)
+				return
+			}
+
+			bot.documents = append(bot.documents, form.Value["caption"][0])
+		}
+
+		ctx.SetBodyString(`{"ok":true,"result":{}}`)
+	})
+
+	return bot, "http://" + ln.Addr().String() + "/bot%s:%s", func() { ln.Close() }
+}
+
+func TestSendFallback(t *testing.T) {
+	bot, url, stop := startFakeBot(t)
+	defer stop()
+
+	a := InitTelegram(&Config{URL: url, BotID: "1", BotToken: "t", Chats: map[string]string{"ops": "42"}, ParseMode: ParseModeMarkdownV2}, 1)
+
+	if err := a.PostMessage("*broken", "ops"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bot.messages) != 1 || bot.messages[0]["parse_mode"] != "" || bot.messages[0]["text"] != "*broken" {
+		t.Fatalf("unexpected messages %v", bot.messages)
+	}
+}
+
+func TestSendDocument(t *testing.T) {
+	bot, url, stop := startFakeBot(t)
+	defer stop()
+
+	a := InitTelegram(&Config{URL: url, BotID: "1", BotToken: "t", DocumentThreshold: 10}, 1)
+	alert := &alerts.Alert{Title: "Long output", Message: strings.Repeat("x", 100), Labels: map[string]string{alerts.LabelChat: "42"}}
+
+	if err := a.Send(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bot.documents) != 1 || bot.documents[0] != "Long output" {
+		t.Fatalf("unexpected documents %v", bot.documents)
+	}
+}
@@ -0,0 +1,116 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+)
+
+// HeaderSecretToken contains Config.WebhookSecret in webhook requests
+const HeaderSecretToken = "X-Telegram-Bot-Api-Secret-Token"
+
+// Methods
+const (
+	methodGetWebhookInfo = "getWebhookInfo"
+	methodSetWebhook     = "setWebhook"
+	methodDeleteWebhook  = "deleteWebhook"
+)
+
+// DefaultAllowedUpdates are update types requested by RegisterWebhook
+var DefaultAllowedUpdates = []string{UpdateMessage, UpdateCallbackQuery}
+
+type getWebhookInfoResponse struct {
+	URL                  string   `json:"url"`
+	HasCustomCertificate bool     `json:"has_custom_certificate"`
+	PendingUpdateCount   int      `json:"pending_update_count"`
+	LastErrorDate        int      `json:"last_error_date"`
+	LastErrorMessage     string   `json:"last_error_message"`
+	MaxConnections       int      `json:"max_connections"`
+	AllowedUpdates       []string `json:"allowed_updates"`
+}
+
+// SetWebhookRequest is setWebhook parameters
+type SetWebhookRequest struct {
+	URL                string   `json:"url"`
+	MaxConnections     int      `json:"max_connections,omitempty"`
+	AllowedUpdates     []string `json:"allowed_updates,omitempty"`
+	DropPendingUpdates bool     `json:"drop_pending_updates,omitempty"`
+	SecretToken        string   `json:"secret_token,omitempty"`
+}
+
+// SetWebhook sets url receiving updates
+func (c *Client) SetWebhook(ctx context.Context, req *SetWebhookRequest) error {
+	return c.call(ctx, methodSetWebhook, req, nil)
+}
+
+// DeleteWebhook removes webhook, it's required before getUpdates
+func (c *Client) DeleteWebhook(ctx context.Context, dropPendingUpdates bool) error {
+	return c.call(ctx, methodDeleteWebhook, map[string]bool{"drop_pending_updates": dropPendingUpdates}, nil)
+}
+
+// RegisterWebhook sets webhook url if it's changed, empty url is ignored,
+// use DeleteWebhook to remove the webhook
+func (mngr *Alert) RegisterWebhook(url string) {
+	if url == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	response := &getWebhookInfoResponse{}
+	if err := mngr.call(ctx, methodGetWebhookInfo, nil, response); err != nil {
+		log.Printf("[Telegram] Error checking webhook info %v", err)
+		return
+	}
+
+	if response.URL == url {
+		return
+	}
+
+	err := mngr.SetWebhook(ctx, &SetWebhookRequest{
+		URL:            url,
+		AllowedUpdates: DefaultAllowedUpdates,
+		SecretToken:    mngr.config.WebhookSecret,
+	})
+	if err != nil {
+		log.Printf("[Telegram] error setting webhook %v", err)
+	}
+}
+
+// WebhookHandler returns handler of webhook requests dispatching updates to mux,
+// requests without secret are rejected if secret is not empty
+func WebhookHandler(secret string, mux *Mux) transport.RouterFunc {
+	return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		token := ctx.Request.Header.Peek(HeaderSecretToken)
+		if secret != "" && subtle.ConstantTimeCompare(token, []byte(secret)) != 1 {
+			ctx.Error("Forbidden", fasthttp.StatusForbidden)
+			return
+		}
+
+		update := &Update{}
+		if err := json.Unmarshal(ctx.PostBody(), update); err != nil {
+			log.Printf("[Telegram] Error decoding update %v", err)
+			ctx.Error("Bad Request", fasthttp.StatusBadRequest)
+
+			return
+		}
+
+		mux.Dispatch(ctx, update)
+	}
+}
+
+// RegisterWebhookRoute adds POST route of webhook with Config.WebhookSecret to transport.DefaultRouter
+func (mngr *Alert) RegisterWebhookRoute(path string, mux *Mux, middlewares ...transport.Middleware) {
+	mngr.RegisterWebhookRouteOn(transport.DefaultRouter, path, mux, middlewares...)
+}
+
+// RegisterWebhookRouteOn adds POST route of webhook with Config.WebhookSecret to router
+func (mngr *Alert) RegisterWebhookRouteOn(router *transport.Router, path string, mux *Mux, middlewares ...transport.Middleware) {
+	router.AddPostRoute(path, transport.Chain(WebhookHandler(mngr.config.WebhookSecret, mux), middlewares...))
+}
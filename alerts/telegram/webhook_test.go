@@ -0,0 +1,74 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/finnan444/utils/transport/transporttest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+
+	updates := 0
+	mux := NewMux()
+	mux.HandleCommand("status", func(ctx context.Context, msg *Message, args string) {
+		updates++
+	})
+
+	srv.AddPostRoute("/telegram", WebhookHandler("secret", mux))
+
+	update := &Update{UpdateID: 1, Message: &Message{Text: "/status", Chat: &Chat{ID: 42}}}
+
+	srv.Post("/telegram").JSON(update).Do().AssertStatus(fasthttp.StatusForbidden)
+	srv.Post("/telegram").Header(HeaderSecretToken, "wrong").JSON(update).Do().AssertStatus(fasthttp.StatusForbidden)
+	srv.Post("/telegram").Header(HeaderSecretToken, "secret").Body([]byte("{"), "application/json").Do().
+		AssertStatus(fasthttp.StatusBadRequest)
+	srv.Post("/telegram").Header(HeaderSecretToken, "secret").JSON(update).Do().AssertStatus(fasthttp.StatusOK)
+
+	if updates != 1 {
+		t.Fatalf("expected 1 update, got %d", updates)
+	}
+}
+
+func TestRegisterWebhook(t *testing.T) {
+	var calls []string
+
+	c, stop := startFakeAPI(t, func(ctx *fasthttp.RequestCtx) {
+		method := string(ctx.Path()[strings.LastIndexByte(string(ctx.Path()), '/')+1:])
+		calls = append(calls, method)
+
+		if method == methodSetWebhook {
+			req := &SetWebhookRequest{}
+			json.Unmarshal(ctx.PostBody(), req)
+
+			if req.SecretToken != "secret" || len(req.AllowedUpdates) != len(DefaultAllowedUpdates) {
+				calls = append(calls, "invalid")
+				ctx.SetBodyString(`{"ok":false,"error_code":400,"description":"bad request"}`)
+				return
+			}
+		}
+
+		ctx.SetBodyString(`{"ok":true,"result":{"url":"https://old.example.com/telegram"}}`)
+	})
+	defer stop()
+
+	a := &Alert{Client: c, config: &Config{WebhookSecret: "secret"}}
+
+	a.RegisterWebhook("https://old.example.com/telegram")
+	a.RegisterWebhook("https://new.example.com/telegram")
+	a.RegisterWebhook("")
+
+	expected := []string{
+		methodGetWebhookInfo,
+		methodGetWebhookInfo, methodSetWebhook,
+	}
+
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %v, got %v", expected, calls)
+	}
+}
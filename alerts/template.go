@@ -0,0 +1,223 @@
+package alerts
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Format is markup of rendered alert
+type Format string
+
+// Formats
+const (
+	FormatPlain = Format("plain")
+	// FormatMarkdown is legacy Telegram Markdown, Message is rendered verbatim for compatibility
+	FormatMarkdown   = Format("markdown")
+	FormatMarkdownV2 = Format("markdownv2")
+	// FormatHTML is Telegram and email HTML
+	FormatHTML  = Format("html")
+	FormatSlack = Format("slack")
+)
+
+// DefaultTemplateText is used by destinations without template, alert with only Message
+// is rendered as the message. Functions: escape, message, bold, italic, code, pre, upper,
+// lower, labels and emoji, all of them escape their arguments
+const DefaultTemplateText = `
+{{- if .Title -}}
+{{emoji .Severity}} {{bold (upper .Severity.String)}}{{with .Service}} {{escape .}}{{end}}{{with .Host}} @ {{escape .}}{{end}}
+{{bold .Title}}
+{{end -}}
+{{message .Message}}
+{{- range .Fields}}
+{{bold .Name}}: {{escape .Value}}
+{{- end}}
+{{- with .VisibleLabels}}
+{{labels .}}
+{{- end}}
+{{- with .Stack}}
+{{pre .}}
+{{- end}}`
+
+var (
+	markdownV2Replacer = strings.NewReplacer(
+		"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)",
+		"~", "\\~", "`", "\\`", ">", "\\>", "#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=",
+		"|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+	)
+	markdownV2CodeReplacer = strings.NewReplacer("\\", "\\\\", "`", "\\`")
+	markdownReplacer       = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+	slackReplacer          = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+	severityEmoji = map[Severity]string{
+		SeverityDebug:    "🐞",
+		SeverityInfo:     "ℹ️",
+		SeverityWarning:  "⚠️",
+		SeverityError:    "❌",
+		SeverityCritical: "🔥",
+	}
+
+	formats = map[Format]bool{
+		FormatPlain: true, FormatMarkdown: true, FormatMarkdownV2: true, FormatHTML: true, FormatSlack: true,
+	}
+
+	defaultTemplates = map[Format]*Template{}
+)
+
+func init() {
+	for format := range formats {
+		t, err := NewTemplate(format, DefaultTemplateText)
+		if err != nil {
+			panic(err)
+		}
+
+		defaultTemplates[format] = t
+	}
+}
+
+// Escape escapes text for the format
+func Escape(format Format, s string) string {
+	switch format {
+	case FormatMarkdownV2:
+		return markdownV2Replacer.Replace(s)
+	case FormatMarkdown:
+		return markdownReplacer.Replace(s)
+	case FormatHTML:
+		return html.EscapeString(s)
+	case FormatSlack:
+		return slackReplacer.Replace(s)
+	}
+
+	return s
+}
+
+// Template renders alerts in the format
+type Template struct {
+	format Format
+	tmpl   *template.Template
+}
+
+// NewTemplate parses text/template of alert
+func NewTemplate(format Format, text string) (*Template, error) {
+	if !formats[format] {
+		return nil, fmt.Errorf("alerts: unknown format %s", format)
+	}
+
+	tmpl, err := template.New(string(format)).Funcs(funcs(format)).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Template{format: format, tmpl: tmpl}, nil
+}
+
+// DefaultTemplate returns template of the format made of DefaultTemplateText
+func DefaultTemplate(format Format) *Template {
+	if t, ok := defaultTemplates[format]; ok {
+		return t
+	}
+
+	return defaultTemplates[FormatPlain]
+}
+
+// TemplateFor parses text or returns default template if text is empty
+func TemplateFor(format Format, text string) (*Template, error) {
+	if text == "" {
+		return DefaultTemplate(format), nil
+	}
+
+	return NewTemplate(format, text)
+}
+
+// Format returns format of the template
+func (t *Template) Format() Format {
+	return t.format
+}
+
+// Render renders alert
+func (t *Template) Render(alert *Alert) (string, error) {
+	var b strings.Builder
+
+	if err := t.tmpl.Execute(&b, alert); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+func wrap(format Format, s, markdown, htmlTag string) string {
+	switch format {
+	case FormatMarkdownV2, FormatMarkdown, FormatSlack:
+		return markdown + Escape(format, s) + markdown
+	case FormatHTML:
+		return "<" + htmlTag + ">" + Escape(format, s) + "</" + htmlTag + ">"
+	}
+
+	return s
+}
+
+func code(format Format, s string, block bool) string {
+	fence := "`"
+	if block {
+		fence = "```"
+	}
+
+	switch format {
+	case FormatMarkdownV2:
+		s = markdownV2CodeReplacer.Replace(s)
+	case FormatMarkdown:
+		s = strings.Replace(s, "`", "'", -1)
+	case FormatSlack:
+		s = slackReplacer.Replace(s)
+	case FormatHTML:
+		if block {
+			return "<pre>" + html.EscapeString(s) + "</pre>"
+		}
+
+		return "<code>" + html.EscapeString(s) + "</code>"
+	default:
+		return s
+	}
+
+	if block {
+		return fence + "\n" + s + "\n" + fence
+	}
+
+	return fence + s + fence
+}
+
+func funcs(format Format) template.FuncMap {
+	return template.FuncMap{
+		"escape": func(s string) string { return Escape(format, s) },
+		"message": func(s string) string {
+			if format == FormatMarkdown {
+				return s
+			}
+
+			return Escape(format, s)
+		},
+		"bold":   func(s string) string { return wrap(format, s, "*", "b") },
+		"italic": func(s string) string { return wrap(format, s, "_", "i") },
+		"code":   func(s string) string { return code(format, s, false) },
+		"pre":    func(s string) string { return code(format, s, true) },
+		"upper":  strings.ToUpper,
+		"lower":  strings.ToLower,
+		"emoji":  func(s Severity) string { return severityEmoji[s] },
+		"labels": func(labels map[string]string) string {
+			names := make([]string, 0, len(labels))
+			for name := range labels {
+				names = append(names, name)
+			}
+
+			sort.Strings(names)
+
+			for i, name := range names {
+				names[i] = code(format, name+"="+labels[name], false)
+			}
+
+			return strings.Join(names, " ")
+		},
+	}
+}
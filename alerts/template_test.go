@@ -0,0 +1,79 @@
+package alerts
+
+import (
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	for _, test := range []struct {
+		format      Format
+		in, escaped string
+	}{
+		{FormatMarkdownV2, "api_v2 (1.5)!", "api\\_v2 \\(1\\.5\\)\\!"},
+		{FormatMarkdown, "api_v2 *x*", "api\\_v2 \\*x\\*"},
+		{FormatHTML, "a<b & c", "a&lt;b &amp; c"},
+		{FormatSlack, "<!here> & co", "&lt;!here&gt; &amp; co"},
+		{FormatPlain, "a_b", "a_b"},
+	} {
+		if s := Escape(test.format, test.in); s != test.escaped {
+			t.Errorf("%s: expected %q, got %q", test.format, test.escaped, s)
+		}
+	}
+}
+
+func TestDefaultTemplateMessageOnly(t *testing.T) {
+	alert := &Alert{Message: "*bold* legacy_markdown"}
+
+	if s, _ := DefaultTemplate(FormatMarkdown).Render(alert); s != alert.Message {
+		t.Fatalf("legacy message is changed: %q", s)
+	}
+
+	if s, _ := DefaultTemplate(FormatMarkdownV2).Render(alert); s != "\\*bold\\* legacy\\_markdown" {
+		t.Fatalf("unexpected message %q", s)
+	}
+}
+
+func TestDefaultTemplate(t *testing.T) {
+	alert := &Alert{
+		Severity: SeverityError,
+		Title:    "Payment failed",
+		Service:  "billing_api",
+		Host:     "web-1",
+		Message:  "card declined",
+		Fields:   []Field{{"Amount", "10.5"}},
+		Labels:   map[string]string{"region": "eu", LabelChat: "ops"},
+		Stack:    "main.go:10",
+	}
+
+	expected := map[Format]string{
+		FormatMarkdownV2: "❌ *ERROR* billing\\_api @ web\\-1\n*Payment failed*\ncard declined\n*Amount*: 10\\.5\n`region=eu`\n```\nmain.go:10\n```",
+		FormatHTML:       "❌ <b>ERROR</b> billing_api @ web-1\n<b>Payment failed</b>\ncard declined\n<b>Amount</b>: 10.5\n<code>region=eu</code>\n<pre>main.go:10</pre>",
+		FormatPlain:      "❌ ERROR billing_api @ web-1\nPayment failed\ncard declined\nAmount: 10.5\nregion=eu\nmain.go:10",
+	}
+
+	for format, text := range expected {
+		s, err := DefaultTemplate(format).Render(alert)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s != text {
+			t.Errorf("%s: expected\n%s\ngot\n%s", format, text, s)
+		}
+	}
+}
+
+func TestCustomTemplate(t *testing.T) {
+	if _, err := NewTemplate("unknown", ""); err == nil {
+		t.Fatal("expected unknown format error")
+	}
+
+	tmpl, err := TemplateFor(FormatHTML, `{{bold .Service}}: {{italic .Message}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := tmpl.Render(&Alert{Service: "a<b", Message: "m"}); s != "<b>a&lt;b</b>: <i>m</i>" {
+		t.Fatalf("unexpected result %q", s)
+	}
+}
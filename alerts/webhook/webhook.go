@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/finnan444/utils/alerts"
+)
+
+// HeaderSignature contains hex HMAC-SHA256 of the body when Config.Secret is set
+const HeaderSignature = "X-Alert-Signature"
+
+// Config describes webhook config
+type Config struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Secret  string            `json:"secret"`
+}
+
+// Alert is webhook structure
+type Alert struct {
+	config  *Config
+	timeout time.Duration
+}
+
+// InitWebhook initialize webhook
+func InitWebhook(config *Config, connectionTimeoutSeconds time.Duration) *Alert {
+	return &Alert{
+		timeout: connectionTimeoutSeconds * time.Second,
+		config:  config,
+	}
+}
+
+// PostMessage posts alert made by alerts.NewAlert
+func (mngr *Alert) PostMessage(message string, infoLevel string) error {
+	return mngr.Send(context.Background(), alerts.NewAlert(message, infoLevel))
+}
+
+// Send posts alert as JSON
+func (mngr *Alert) Send(ctx context.Context, alert *alerts.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("[Webhook] Error marshaling request %v", err)
+		return err
+	}
+
+	headers := mngr.config.Headers
+
+	if mngr.config.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(mngr.config.Secret))
+		mac.Write(body)
+
+		headers = make(map[string]string, len(mngr.config.Headers)+1)
+		for name, value := range mngr.config.Headers {
+			headers[name] = value
+		}
+
+		headers[HeaderSignature] = hex.EncodeToString(mac.Sum(nil))
+	}
+
+	if err = alerts.PostJSON(ctx, mngr.config.URL, body, headers, mngr.timeout); err != nil {
+		log.Printf("[Webhook] error posting alert %v", err)
+	}
+
+	return err
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/finnan444/utils/alerts"
+	"github.com/valyala/fasthttp"
+)
+
+func TestSend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan *alerts.Alert, 1)
+
+	go fasthttp.Serve(ln, func(ctx *fasthttp.RequestCtx) {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(ctx.PostBody())
+
+		if string(ctx.Request.Header.Peek(HeaderSignature)) != hex.EncodeToString(mac.Sum(nil)) ||
+			string(ctx.Request.Header.Peek("X-Team")) != "ops" {
+			ctx.SetStatusCode(fasthttp.StatusForbidden)
+			return
+		}
+
+		alert := &alerts.Alert{}
+		json.Unmarshal(ctx.PostBody(), alert)
+		received <- alert
+	})
+
+	a := InitWebhook(&Config{
+		URL:     "http://" + ln.Addr().String() + "/alerts",
+		Headers: map[string]string{"X-Team": "ops"},
+		Secret:  "secret",
+	}, 1)
+
+	if err := a.Send(context.Background(), &alerts.Alert{Severity: alerts.SeverityCritical, Message: "down"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if alert := <-received; alert.Severity != alerts.SeverityCritical || alert.Message != "down" {
+		t.Fatalf("unexpected alert %+v", alert)
+	}
+
+	a.config.Secret = "wrong"
+	if err := a.Send(context.Background(), &alerts.Alert{Message: "down"}); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -3,8 +3,10 @@ module github.com/finnan444/utils
 go 1.12
 
 require (
+	github.com/andybalholm/brotli v1.0.0
 	github.com/globalsign/mgo v0.0.0-20181015135952-eeefdecb41b8
 	github.com/jinzhu/gorm v1.9.10
+	github.com/klauspost/compress v1.10.3
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/nyaruka/phonenumbers v1.0.50
 	github.com/sirupsen/logrus v1.4.2
@@ -66,19 +66,19 @@ func SplitPhoneNumberNew(p *string) (*phonenumbers.PhoneNumber, error) {
 func PreCheck(ctx *fasthttp.RequestCtx, req *transport.KernelBaseRequest, logger2 *logrus.Logger, token string) bool {
 	reqBody := &logrus.Fields{}
 	if err := json.Unmarshal(ctx.Request.Body(), reqBody); err != nil {
-		logger2.WithFields(logrus.Fields{"error": err, "body": string(ctx.Request.Body())}).Warn("body not json")
+		logger2.WithFields(logrus.Fields{"error": err, "body": string(transport.RedactBody(ctx.Request.Body()))}).Warn("body not json")
 		ctx.SetStatusCode(fasthttp.StatusBadRequest)
 
 		return false
 	}
 
 	if err := transport.DecodeJSONBody(ctx, req); err != nil {
-		logger2.WithFields(logrus.Fields{"error": err, "body": reqBody}).Warn("request decode error")
+		logger2.WithFields(logrus.Fields{"error": err, "body": redactFields(reqBody)}).Warn("request decode error")
 		return false
 	}
 
 	if !transport.AuthenticateByToken(ctx, req.Token, token) {
-		logger2.WithFields(logrus.Fields{"body": reqBody}).Warn("unauthorized request")
+		logger2.WithFields(logrus.Fields{"body": redactFields(reqBody)}).Warn("unauthorized request")
 		return false
 	}
 
@@ -92,22 +92,27 @@ func PreCheckNew(requestBody []byte, req *transport.KernelBaseRequest, token str
 	reqBody := &logrus.Fields{}
 	if err := json.Unmarshal(requestBody, reqBody); err != nil {
 		resp.SetError(fasthttp.StatusBadRequest, "body not json")
-		return resp, logrus.Fields{"error": err, "body": string(requestBody)}
+		return resp, logrus.Fields{"error": err, "body": string(transport.RedactBody(requestBody))}
 	}
 
 	if err := transport.DecodeJSONBodyNew(requestBody, req); err != nil {
 		resp.SetError(fasthttp.StatusBadRequest, "request decode error")
-		return resp, logrus.Fields{"error": err, "body": reqBody}
+		return resp, logrus.Fields{"error": err, "body": redactFields(reqBody)}
 	}
 
 	if err := transport.AuthenticateByTokenNew(req.Token, token); err != nil {
 		resp.SetError(fasthttp.StatusUnauthorized, "unauthorized request")
-		return resp, logrus.Fields{"error": err, "body": reqBody}
+		return resp, logrus.Fields{"error": err, "body": redactFields(reqBody)}
 	}
 
 	return resp, nil
 }
 
+// redactFields маскирует чувствительные поля тела запроса перед логированием
+func redactFields(fields *logrus.Fields) interface{} {
+	return transport.RedactValue(map[string]interface{}(*fields))
+}
+
 // Elapsed можно вызывать в начале ф-ции defer Elapsed("functionName")
 func Elapsed(what string) func() {
 	start := time.Now()
@@ -0,0 +1,226 @@
+package transport
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/finnan444/utils/math/ints"
+	"github.com/valyala/fasthttp"
+)
+
+// user value keys used by access log
+const (
+	accessIdentityKey = "transport.identity"
+	accessResponseKey = "transport.response"
+)
+
+// AccessFormat format of access records
+type AccessFormat int
+
+// AccessFormats
+const (
+	FormatJSON AccessFormat = iota
+	FormatLogfmt
+)
+
+// AccessRecord is a single access log entry
+type AccessRecord struct {
+	Time      time.Time `json:"time"`
+	RequestID uint64    `json:"requestId"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Route     string    `json:"route"`
+	Status    int       `json:"status"`
+	LatencyMs float64   `json:"latencyMs"`
+	Bytes     int       `json:"bytes"`
+	ClientIP  string    `json:"clientIp"`
+	UserAgent string    `json:"userAgent"`
+	Identity  string    `json:"identity,omitempty"`
+	Request   string    `json:"request,omitempty"`
+	Response  string    `json:"response,omitempty"`
+}
+
+// AccessSink receives access records. Records are pooled, so the record is valid only
+// until WriteAccess returns, sinks which keep it or pass it to other goroutines must copy it
+type AccessSink interface {
+	WriteAccess(record *AccessRecord)
+}
+
+// AccessLogConfig describes access log
+type AccessLogConfig struct {
+	Sink AccessSink
+	// SampleRate share of successful requests to be logged, 0 means all.
+	// Requests finished with 5xx are always logged
+	SampleRate float64
+}
+
+var (
+	accessLog     *AccessLogConfig
+	accessRecords = sync.Pool{
+		New: func() interface{} {
+			return &AccessRecord{}
+		},
+	}
+)
+
+// SetAccessLog enables structured access log instead of free text logging
+// in ProcessRouting, ProcessStandardRouting and SendResponse. Nil disables it.
+// Which requests are logged and whether bodies are truncated is still defined by LogFlag
+func SetAccessLog(config *AccessLogConfig) {
+	accessLog = config
+}
+
+// SetAccessIdentity sets identity of the authenticated client for the access log
+func SetAccessIdentity(ctx *fasthttp.RequestCtx, identity string) {
+	ctx.SetUserValue(accessIdentityKey, identity)
+}
+
+// writerSink writes formatted records to io.Writer
+type writerSink struct {
+	sync.Mutex
+	w      io.Writer
+	format AccessFormat
+	buf    []byte
+}
+
+// NewWriterSink creates sink writing records line by line in a given format
+func NewWriterSink(w io.Writer, format AccessFormat) AccessSink {
+	return &writerSink{w: w, format: format}
+}
+
+// WriteAccess impl
+func (s *writerSink) WriteAccess(record *AccessRecord) {
+	s.Lock()
+
+	if s.format == FormatLogfmt {
+		s.buf = appendLogfmt(s.buf[:0], record)
+	} else {
+		js, _ := json.Marshal(record)
+		s.buf = append(s.buf[:0], js...)
+	}
+
+	s.buf = append(s.buf, '\n')
+	_, _ = s.w.Write(s.buf)
+	s.Unlock()
+}
+
+type multiSink []AccessSink
+
+// MultiSink duplicates records to all sinks
+func MultiSink(sinks ...AccessSink) AccessSink {
+	return multiSink(sinks)
+}
+
+// WriteAccess impl
+func (ms multiSink) WriteAccess(record *AccessRecord) {
+	for _, s := range ms {
+		s.WriteAccess(record)
+	}
+}
+
+func appendLogfmt(dst []byte, r *AccessRecord) []byte {
+	dst = appendLogfmtPair(dst, "time", r.Time.Format(time.RFC3339Nano))
+	dst = appendLogfmtPair(dst, "request_id", strconv.FormatUint(r.RequestID, 10))
+	dst = appendLogfmtPair(dst, "method", r.Method)
+	dst = appendLogfmtPair(dst, "path", r.Path)
+	dst = appendLogfmtPair(dst, "route", r.Route)
+	dst = appendLogfmtPair(dst, "status", strconv.Itoa(r.Status))
+	dst = appendLogfmtPair(dst, "latency_ms", strconv.FormatFloat(r.LatencyMs, 'f', 3, 64))
+	dst = appendLogfmtPair(dst, "bytes", strconv.Itoa(r.Bytes))
+	dst = appendLogfmtPair(dst, "client_ip", r.ClientIP)
+	dst = appendLogfmtPair(dst, "user_agent", r.UserAgent)
+
+	if r.Identity != "" {
+		dst = appendLogfmtPair(dst, "identity", r.Identity)
+	}
+
+	if r.Request != "" {
+		dst = appendLogfmtPair(dst, "request", r.Request)
+	}
+
+	if r.Response != "" {
+		dst = appendLogfmtPair(dst, "response", r.Response)
+	}
+
+	return dst
+}
+
+func appendLogfmtPair(dst []byte, key, value string) []byte {
+	if len(dst) > 0 {
+		dst = append(dst, ' ')
+	}
+
+	dst = append(dst, key...)
+	dst = append(dst, '=')
+
+	if value == "" || strings.ContainsAny(value, " =\"\\\t\r\n") {
+		return strconv.AppendQuote(dst, value)
+	}
+
+	return append(dst, value...)
+}
+
+func truncateLog(body []byte, logFlag LogFlag) []byte {
+	if (logFlag & FullLog) != 0 {
+		return body
+	}
+
+	return body[:ints.MinInt(len(body), 255)]
+}
+
+// setAccessResponse keeps response body for the access record, it is redacted by writeAccess if logged
+func setAccessResponse(ctx *fasthttp.RequestCtx, body []byte) {
+	ctx.SetUserValue(accessResponseKey, body)
+}
+
+// writeAccess sends record about finished request to the access log sink
+func writeAccess(ctx *fasthttp.RequestCtx, start time.Time, route string, logFlag LogFlag) {
+	config := accessLog
+	if config == nil || (logFlag&ToLog) == 0 {
+		return
+	}
+
+	status := ctx.Response.StatusCode()
+	if status < fasthttp.StatusInternalServerError && config.SampleRate > 0 && rand.Float64() >= config.SampleRate {
+		return
+	}
+
+	record := accessRecords.Get().(*AccessRecord)
+	*record = AccessRecord{
+		Time:      start,
+		RequestID: ctx.ID(),
+		Method:    string(ctx.Method()),
+		Path:      string(ctx.Path()),
+		Route:     route,
+		Status:    status,
+		LatencyMs: float64(time.Since(start)) / float64(time.Millisecond),
+		ClientIP:  ctx.RemoteIP().String(),
+		UserAgent: string(ctx.UserAgent()),
+	}
+
+	if !ctx.Response.IsBodyStream() {
+		record.Bytes = len(ctx.Response.Body())
+	}
+
+	if identity, ok := ctx.UserValue(accessIdentityKey).(string); ok {
+		record.Identity = identity
+	}
+
+	if body := ctx.Request.Body(); len(body) > 0 {
+		record.Request = string(RedactBody(truncateLog(body, logFlag)))
+	} else {
+		record.Request = string(RedactBody(ctx.QueryArgs().QueryString()))
+	}
+
+	if body, ok := ctx.UserValue(accessResponseKey).([]byte); ok {
+		record.Response = string(RedactBody(truncateLog(body, logFlag)))
+	}
+
+	config.Sink.WriteAccess(record)
+	accessRecords.Put(record)
+}
@@ -0,0 +1,162 @@
+package transport_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/finnan444/utils/transport/transporttest"
+	"github.com/valyala/fasthttp"
+)
+
+type captureSink struct {
+	sync.Mutex
+	records []transport.AccessRecord
+}
+
+func (s *captureSink) WriteAccess(record *transport.AccessRecord) {
+	s.Lock()
+	s.records = append(s.records, *record)
+	s.Unlock()
+}
+
+func TestAccessLog(t *testing.T) {
+	var jsonOut, logfmtOut bytes.Buffer
+
+	capture := &captureSink{}
+	transport.SetAccessLog(&transport.AccessLogConfig{Sink: transport.MultiSink(
+		transport.NewWriterSink(&jsonOut, transport.FormatJSON),
+		transport.NewWriterSink(&logfmtOut, transport.FormatLogfmt),
+		capture,
+	)})
+	defer transport.SetAccessLog(nil)
+
+	pathes := &transport.LogPathes{FullLogPathes: map[string]transport.LogFlag{
+		"/login":  transport.ToLog,
+		"/full":   transport.ToLog | transport.FullLog,
+		"/silent": 0,
+		"/new":    transport.ToLog,
+	}}
+
+	srv := transporttest.NewServer(t, pathes)
+	defer srv.Close()
+
+	handler := func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		transport.SetAccessIdentity(ctx, "user-1")
+
+		resp := transport.GetResponse()
+		resp.Payload = strings.Repeat("x", 300)
+		transport.SendResponse(ctx, resp, now, pathes)
+	}
+
+	srv.AddPostRoute("/login", handler)
+	srv.AddPostRoute("/full", handler)
+	srv.AddPostRoute("/silent", handler)
+	srv.AddGetRoute("/new", func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		resp := transport.GetResponse()
+		resp.Payload = map[string]string{"token": "t1", "name": "n1"}
+		transport.SendResponseNew(ctx, resp, pathes)
+	})
+
+	srv.Post("/login").Header("User-Agent", "test agent").Body([]byte(`{"login":"a","password":"secret"}`), "application/json").Do().
+		AssertStatus(fasthttp.StatusOK)
+	srv.Post("/full").Body([]byte(`{}`), "application/json").Do().AssertStatus(fasthttp.StatusOK)
+	srv.Post("/silent").Body([]byte(`{}`), "application/json").Do().AssertStatus(fasthttp.StatusOK)
+	srv.Get("/new").Query("q", "1").Do().AssertStatus(fasthttp.StatusOK)
+
+	capture.Lock()
+	defer capture.Unlock()
+
+	if len(capture.records) != 3 {
+		t.Fatalf("Expecting records of logged pathes only, got %+v", capture.records)
+	}
+
+	login, full, standard := capture.records[0], capture.records[1], capture.records[2]
+	if login.Method != fasthttp.MethodPost || login.Route != "/login" || login.Status != fasthttp.StatusOK ||
+		login.Identity != "user-1" || login.UserAgent != "test agent" || login.Bytes == 0 {
+		t.Errorf("Unexpected record %+v", login)
+	}
+
+	if strings.Contains(login.Request, "secret") || !strings.Contains(login.Request, `"login":"a"`) {
+		t.Errorf("Expecting redacted request, got %s", login.Request)
+	}
+
+	if len(login.Response) != 255 || len(full.Response) <= 300 {
+		t.Errorf("Expecting truncated response only without FullLog, got %d and %d", len(login.Response), len(full.Response))
+	}
+
+	if standard.Request != "q=1" || !strings.Contains(standard.Response, `"name":"n1"`) || strings.Contains(standard.Response, "t1") {
+		t.Errorf("Expecting query and redacted response of SendResponseNew, got %+v", standard)
+	}
+
+	lines := strings.Split(strings.TrimSpace(jsonOut.String()), "\n")
+	decoded := transport.AccessRecord{}
+
+	if err := json.Unmarshal([]byte(lines[0]), &decoded); err != nil || len(lines) != 3 || decoded.Route != "/login" {
+		t.Errorf("Unexpected JSON output %q: %v", jsonOut.String(), err)
+	}
+
+	logfmt := strings.Split(logfmtOut.String(), "\n")[0]
+	for _, pair := range []string{"method=POST", "path=/login", "status=200", "identity=user-1", `user_agent="test agent"`} {
+		if !strings.Contains(logfmt, pair) {
+			t.Errorf("Expecting %s in logfmt output %q", pair, logfmt)
+		}
+	}
+}
+
+func TestAccessLogRequestBody(t *testing.T) {
+	capture := &captureSink{}
+	transport.SetAccessLog(&transport.AccessLogConfig{Sink: capture})
+	defer transport.SetAccessLog(nil)
+
+	pathes := &transport.LogPathes{FullLogPathes: map[string]transport.LogFlag{"/orders": transport.ToLog}}
+
+	srv := transporttest.NewServer(t, pathes)
+	defer srv.Close()
+
+	srv.AddPutRoute("/orders", func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		transport.SendResponse(ctx, transport.GetResponse(), now, pathes)
+	})
+
+	srv.Put("/orders").Query("q", "1").Body([]byte(`{"id":1,"password":"secret"}`), "application/json").Do().
+		AssertStatus(fasthttp.StatusOK)
+
+	capture.Lock()
+	defer capture.Unlock()
+
+	if len(capture.records) != 1 {
+		t.Fatalf("Expecting one record, got %+v", capture.records)
+	}
+
+	if req := capture.records[0].Request; !strings.Contains(req, `"id":1`) || strings.Contains(req, "secret") {
+		t.Errorf("Expecting redacted body of PUT request, got %s", req)
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	capture := &captureSink{}
+	transport.SetAccessLog(&transport.AccessLogConfig{Sink: capture, SampleRate: 1e-9})
+	defer transport.SetAccessLog(nil)
+
+	srv := transporttest.NewServer(t, &transport.LogPathes{FullLogPathes: map[string]transport.LogFlag{"*": transport.ToLog}})
+	defer srv.Close()
+
+	srv.AddGetRoute("/ok", func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {})
+	srv.AddGetRoute("/fail", func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		ctx.Error("fail", fasthttp.StatusInternalServerError)
+	})
+
+	srv.Get("/ok").Do()
+	srv.Get("/fail").Do()
+
+	capture.Lock()
+	defer capture.Unlock()
+
+	if len(capture.records) != 1 || capture.records[0].Status != fasthttp.StatusInternalServerError {
+		t.Errorf("Expecting only failed request to be logged, got %+v", capture.records)
+	}
+}
@@ -2,6 +2,7 @@ package transport
 
 import (
 	"crypto"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -84,19 +85,26 @@ func EnsureIntegerFieldLogger(field int, fieldName string, logger2 *logrus.Logge
 	return true
 }
 
-// Authenticate do smth
-func Authenticate(request request.BasicRequester, response response.BasicResponser, secret string, server PathesLogger) bool {
+// Sign returns md5 hex of concatenated parts, signature checked by Authenticate
+// is Sign(time, secret) and by AuthenticateUser is Sign(user, secret, time)
+func Sign(parts ...string) string {
 	h := hashPool.Get().(hash.Hash)
 
-	_, _ = io.WriteString(h, strconv.Itoa(request.GetTime()))
-	_, _ = io.WriteString(h, secret)
+	for _, part := range parts {
+		_, _ = io.WriteString(h, part)
+	}
 
-	var sign = fmt.Sprintf("%x", h.Sum(nil))
+	sign := fmt.Sprintf("%x", h.Sum(nil))
 
 	h.Reset()
 	hashPool.Put(h)
 
-	if sign != request.GetSignature() {
+	return sign
+}
+
+// Authenticate do smth
+func Authenticate(request request.BasicRequester, response response.BasicResponser, secret string, server PathesLogger) bool {
+	if Sign(strconv.Itoa(request.GetTime()), secret) != request.GetSignature() {
 		response.SetError(SignatureMismatch, "Signature mismatched")
 		return false
 	}
@@ -106,17 +114,7 @@ func Authenticate(request request.BasicRequester, response response.BasicRespons
 
 // AuthenticateUser do smth
 func AuthenticateUser(request request.UserBasicRequester, response response.BasicResponser, secret string, server PathesLogger) bool {
-	h := hashPool.Get().(hash.Hash)
-	_, _ = io.WriteString(h, request.GetUser())
-	_, _ = io.WriteString(h, secret)
-	_, _ = io.WriteString(h, strconv.Itoa(request.GetTime()))
-
-	sign := fmt.Sprintf("%x", h.Sum(nil))
-
-	h.Reset()
-	hashPool.Put(h)
-
-	if sign != request.GetSignature() {
+	if Sign(request.GetUser(), secret, strconv.Itoa(request.GetTime())) != request.GetSignature() {
 		response.SetCode(SignatureMismatch)
 		response.SetMessage("Signature mismatched")
 
@@ -138,14 +136,19 @@ func SendResponse(ctx *fasthttp.RequestCtx, response pool.Reusable, startTime ti
 	ctx.SetContentType(ApplicationJSONUTF8)
 	ctx.SetBody(js)
 
+	if accessLog != nil {
+		setAccessResponse(ctx, js)
+		return
+	}
+
 	path := string(ctx.Path())
 	reqID := ctx.ID()
 
 	if logFlag := server.GetLogFlag(path); (logFlag & ToLog) != 0 {
 		if (logFlag & FullLog) != 0 {
-			logger.Printf("[%s %s %d][Response %s] %s\n", ctx.Method(), path, reqID, time.Since(startTime), js)
+			logger.Printf("[%s %s %d][Response %s] %s\n", ctx.Method(), path, reqID, time.Since(startTime), RedactBody(js))
 		} else {
-			logger.Printf("[%s %s %d][Response %s] %s\n", ctx.Method(), path, reqID, time.Since(startTime), js[:ints.MinInt(len(js), 255)])
+			logger.Printf("[%s %s %d][Response %s] %s\n", ctx.Method(), path, reqID, time.Since(startTime), RedactBody(js[:ints.MinInt(len(js), 255)]))
 		}
 	}
 }
@@ -162,14 +165,19 @@ func SendResponseNew(ctx *fasthttp.RequestCtx, response pool.Reusable, server Pa
 	ctx.SetContentType(ApplicationJSONUTF8)
 	ctx.SetBody(js)
 
+	if accessLog != nil {
+		setAccessResponse(ctx, js)
+		return
+	}
+
 	path := string(ctx.Path())
 	reqID := ctx.ID()
 
 	if logFlag := server.GetLogFlag(path); (logFlag & ToLog) != 0 {
 		if (logFlag & FullLog) != 0 {
-			logger.Printf("[%s %s %d] %s\n", ctx.Method(), path, reqID, js)
+			logger.Printf("[%s %s %d] %s\n", ctx.Method(), path, reqID, RedactBody(js))
 		} else {
-			logger.Printf("[%s %s %d] %s\n", ctx.Method(), path, reqID, js[:ints.MinInt(len(js), 255)])
+			logger.Printf("[%s %s %d] %s\n", ctx.Method(), path, reqID, RedactBody(js[:ints.MinInt(len(js), 255)]))
 		}
 	}
 }
@@ -208,3 +216,23 @@ func AuthenticateByTokenNew(token, tokenControl string) error {
 
 	return errors.New("unauthorized request")
 }
+
+// AdminTokenHeader заголовок с секретом администратора для внутренних ручек
+const AdminTokenHeader = "X-Admin-Token"
+
+// AuthenticateAdmin авторизация внутренних ручек по секрету администратора из заголовка X-Admin-Token
+// или параметра token, если не прошла, устанавливает статус 401
+func AuthenticateAdmin(ctx *fasthttp.RequestCtx, adminSecret string) bool {
+	token := ctx.Request.Header.Peek(AdminTokenHeader)
+	if len(token) == 0 {
+		token = ctx.QueryArgs().Peek("token")
+	}
+
+	if adminSecret != "" && subtle.ConstantTimeCompare(token, []byte(adminSecret)) == 1 {
+		return true
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusUnauthorized)
+
+	return false
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+)
+
+// DefaultMaxBytes default memory bound of the cache
+const DefaultMaxBytes = 64 << 20
+
+type entryState int
+
+const (
+	stateMiss entryState = iota
+	stateFresh
+	stateStale
+)
+
+// header is response header set by the cached handler
+type header struct {
+	key, value string
+}
+
+type entry struct {
+	key        string
+	path       string
+	header     []header
+	body       []byte
+	etag       string
+	stored     time.Time
+	freshUntil time.Time
+	staleUntil time.Time
+	size       int64
+}
+
+// flight is a handler call in progress for the key, other requests wait for it
+type flight struct {
+	done chan struct{}
+}
+
+// Cache is in-memory LRU cache of responses bounded by size
+type Cache struct {
+	sync.Mutex
+	maxBytes int64
+	size     int64
+	items    map[string]*list.Element
+	lru      *list.List
+	flights  map[string]*flight
+
+	hits, stale, misses int64
+}
+
+// New creates cache, stats are shown by /internal/stats as "[CACHE name]"
+func New(name string, maxBytes int64) *Cache {
+	return NewOn(transport.DefaultRouter, name, maxBytes)
+}
+
+// NewOn creates cache with stats added to router
+func NewOn(router *transport.Router, name string, maxBytes int64) *Cache {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+
+	c := &Cache{
+		maxBytes: maxBytes,
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+		flights:  make(map[string]*flight),
+	}
+
+	router.AddStats("[CACHE "+name+"]", c)
+
+	return c
+}
+
+// String is shown by /internal/stats
+func (c *Cache) String() string {
+	c.Lock()
+	defer c.Unlock()
+
+	return fmt.Sprintf("{\"entries\":%d, \"bytes\":%d, \"hits\":%d, \"stale\":%d, \"misses\":%d}",
+		c.lru.Len(), c.size, c.hits, c.stale, c.misses)
+}
+
+// Len returns number of entries
+func (c *Cache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.lru.Len()
+}
+
+// Purge removes entries with path starting with prefix, empty prefix removes everything.
+// Returns number of removed entries
+func (c *Cache) Purge(prefix string) int {
+	c.Lock()
+	defer c.Unlock()
+
+	count := 0
+
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*entry); strings.HasPrefix(e.path, prefix) {
+			c.remove(el)
+			count++
+		}
+		el = next
+	}
+
+	return count
+}
+
+func (c *Cache) lookup(key string, now time.Time) (*entry, entryState) {
+	c.Lock()
+	defer c.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, stateMiss
+	}
+
+	e := el.Value.(*entry)
+
+	switch {
+	case now.Before(e.freshUntil):
+		c.hits++
+		c.lru.MoveToFront(el)
+
+		return e, stateFresh
+	case now.Before(e.staleUntil):
+		c.stale++
+		c.lru.MoveToFront(el)
+
+		return e, stateStale
+	}
+
+	c.remove(el)
+	c.misses++
+
+	return nil, stateMiss
+}
+
+func (c *Cache) add(e *entry) {
+	if e.size > c.maxBytes {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.items[e.key]; ok {
+		c.remove(el)
+	}
+
+	c.items[e.key] = c.lru.PushFront(e)
+	c.size += e.size
+
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.items, e.key)
+	c.size -= e.size
+}
+
+// acquireFlight returns flight of the key and whether the caller is the leader which must release it
+func (c *Cache) acquireFlight(key string) (*flight, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if f, ok := c.flights[key]; ok {
+		return f, false
+	}
+
+	f := &flight{done: make(chan struct{})}
+	c.flights[key] = f
+
+	return f, true
+}
+
+func (c *Cache) releaseFlight(key string, f *flight) {
+	c.Lock()
+	delete(c.flights, key)
+	c.Unlock()
+
+	close(f.done)
+}
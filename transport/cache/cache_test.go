@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/finnan444/utils/transport/ratelimit"
+	"github.com/finnan444/utils/transport/transporttest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMiddleware(t *testing.T) {
+	var calls int32
+
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+
+	c := NewOn(srv.Router, "test", 0)
+	srv.AddInternalRoutes()
+
+	srv.AddGetRoute("/report", c.Middleware(Config{TTL: 50 * time.Millisecond, StaleWhileRevalidate: time.Second, Query: []string{"id"}})(
+		func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			n := atomic.AddInt32(&calls, 1)
+			time.Sleep(10 * time.Millisecond)
+			ctx.SetBodyString("report " + strconv.Itoa(int(n)))
+		}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srv.Get("/report").Query("id", "1").Query("ignored", "x").Do().AssertStatus(fasthttp.StatusOK)
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Expecting single handler call for concurrent misses, got %d", calls)
+	}
+
+	resp := srv.Get("/report").Query("id", "1").Do().AssertHeader(HeaderCache, "HIT")
+	etag := string(resp.Header.Peek(fasthttp.HeaderETag))
+
+	srv.Get("/report").Query("id", "1").Header(fasthttp.HeaderIfNoneMatch, etag).Do().
+		AssertStatus(fasthttp.StatusNotModified)
+
+	srv.Get("/report").Query("id", "1").Header(fasthttp.HeaderCacheControl, "no-cache").Do().
+		AssertHeader(HeaderCache, "MISS")
+
+	time.Sleep(60 * time.Millisecond)
+
+	if body := string(srv.Get("/report").Query("id", "1").Do().AssertHeader(HeaderCache, "STALE").Body()); body != "report 2" {
+		t.Errorf("Expecting stale response, got %q", body)
+	}
+
+	for i := 0; i < 200 && (atomic.LoadInt32(&calls) < 3 || inFlight(c)); i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if body := string(srv.Get("/report").Query("id", "1").Do().AssertHeader(HeaderCache, "HIT").Body()); body != "report 3" {
+		t.Errorf("Expecting revalidated response, got %q", body)
+	}
+
+	if n := c.Purge("/rep"); n != 1 || c.Len() != 0 {
+		t.Errorf("Expecting single entry to be purged, got %d", n)
+	}
+
+	if stats := string(srv.Get("/internal/stats").Do().Body()); !strings.Contains(stats, "[CACHE test]") {
+		t.Errorf("Expecting cache stats on the router, got %q", stats)
+	}
+}
+
+func TestMiddlewareKeepsOuterHeaders(t *testing.T) {
+	c := New("headers", 0)
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+
+	limiter := ratelimit.NewSlidingWindow(5, time.Minute, ratelimit.NewMemoryStore(0))
+
+	srv.AddGetRoute("/report", transport.Chain(
+		func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			ctx.Response.Header.Set("X-Report", "1")
+			ctx.SetContentType("application/json")
+			ctx.SetBodyString("{}")
+		},
+		ratelimit.Middleware("report", limiter, ratelimit.KeyByHeader("X-Client")),
+		c.Middleware(Config{TTL: time.Minute}),
+	))
+
+	srv.Get("/report").Header("X-Client", "a").Do().AssertHeader(HeaderCache, "MISS")
+	srv.Get("/report").Header("X-Client", "a").Do().AssertHeader(HeaderCache, "HIT").
+		AssertHeader(ratelimit.HeaderRemaining, "3")
+
+	srv.Get("/report").Header("X-Client", "b").Do().
+		AssertHeader(HeaderCache, "HIT").
+		AssertHeader(ratelimit.HeaderLimit, "5").
+		AssertHeader(ratelimit.HeaderRemaining, "4").
+		AssertHeader("X-Report", "1").
+		AssertHeader(fasthttp.HeaderContentType, "application/json")
+}
+
+func TestMiddlewareWaitTimeout(t *testing.T) {
+	var calls int32
+
+	c := New("wait", 0)
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	srv.AddGetRoute("/slow", c.Middleware(Config{TTL: time.Minute, WaitTimeout: 20 * time.Millisecond})(
+		func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				<-release
+			}
+			ctx.SetBodyString("ok")
+		}))
+
+	go srv.Get("/slow").Do()
+
+	for i := 0; i < 200 && !inFlight(c); i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	srv.Get("/slow").Do().AssertStatus(fasthttp.StatusOK)
+
+	if elapsed := time.Since(start); elapsed > time.Second || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("Expecting request to stop waiting for hung leader, took %v with %d calls", elapsed, calls)
+	}
+}
+
+func inFlight(c *Cache) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	return len(c.flights) > 0
+}
+
+func TestLRU(t *testing.T) {
+	c := New("lru", 100)
+	now := time.Now()
+
+	for _, key := range []string{"a", "b", "c"} {
+		c.add(&entry{key: key, size: 40, freshUntil: now.Add(time.Minute)})
+		c.lookup("a", now)
+	}
+
+	if _, state := c.lookup("b", now); state != stateMiss {
+		t.Error("Expecting least recently used entry to be evicted")
+	}
+
+	if _, state := c.lookup("a", now); state != stateFresh {
+		t.Error("Expecting recently used entry to be kept")
+	}
+}
+
+func TestParseCacheControl(t *testing.T) {
+	cc := parseCacheControl([]byte(`public, max-age=60, s-maxage="120", stale-while-revalidate=30`))
+	if !cc.hasMaxAge || cc.maxAge != time.Minute || cc.sMaxAge != 2*time.Minute || cc.swr != 30*time.Second || cc.noStore {
+		t.Errorf("Unexpected directives %+v", cc)
+	}
+}
@@ -0,0 +1,383 @@
+package cache
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+)
+
+// HeaderCache shows whether response was served from cache: HIT, STALE or MISS
+const HeaderCache = "X-Cache"
+
+// DefaultWaitTimeout default time requests wait for the handler call of the same key
+const DefaultWaitTimeout = 10 * time.Second
+
+// Config describes cached route
+type Config struct {
+	// TTL freshness of responses without max-age or s-maxage directive, 0 caches only such responses
+	TTL time.Duration
+	// StaleWhileRevalidate period after expiration when stale response is served
+	// while it is refreshed in background, stale-while-revalidate directive takes precedence
+	StaleWhileRevalidate time.Duration
+	// Query args included in the key, nil means all args
+	Query []string
+	// Headers included in the key, e.g. Accept-Language or Accept-Encoding
+	// if cache is outside of compression middleware
+	Headers []string
+	// WaitTimeout of requests waiting for the handler call of the same key on miss,
+	// the handler is called by the request itself after it, DefaultWaitTimeout by default
+	WaitTimeout time.Duration
+}
+
+type cacheControl struct {
+	noStore, noCache, private bool
+	maxAge, sMaxAge, swr      time.Duration
+	hasMaxAge, hasSMaxAge     bool
+	hasSWR                    bool
+}
+
+func parseCacheControl(header []byte) (cc cacheControl) {
+	for _, directive := range strings.Split(string(header), ",") {
+		name, value := strings.TrimSpace(directive), ""
+		if i := strings.IndexByte(name, '='); i >= 0 {
+			name, value = strings.TrimSpace(name[:i]), strings.Trim(strings.TrimSpace(name[i+1:]), "\"")
+		}
+
+		seconds, err := strconv.Atoi(value)
+		duration := time.Duration(seconds) * time.Second
+
+		switch strings.ToLower(name) {
+		case "no-store":
+			cc.noStore = true
+		case "no-cache":
+			cc.noCache = true
+		case "private":
+			cc.private = true
+		case "max-age":
+			cc.maxAge, cc.hasMaxAge = duration, err == nil
+		case "s-maxage":
+			cc.sMaxAge, cc.hasSMaxAge = duration, err == nil
+		case "stale-while-revalidate":
+			cc.swr, cc.hasSWR = duration, err == nil
+		}
+	}
+
+	return cc
+}
+
+// Middleware caches GET responses. Requests with the same key wait for the single
+// handler call on miss. Cache-Control of requests (no-store, no-cache) and responses
+// (no-store, no-cache, private, max-age, s-maxage, stale-while-revalidate) is honoured.
+// Only 200 responses without Set-Cookie are stored, ETag is added if handler
+// hasn't set it and If-None-Match is answered with 304.
+// Only headers set by the handler are stored, headers of outer middlewares are kept on hit
+func (c *Cache) Middleware(config Config) transport.Middleware {
+	if config.WaitTimeout <= 0 {
+		config.WaitTimeout = DefaultWaitTimeout
+	}
+
+	return func(next transport.RouterFunc) transport.RouterFunc {
+		return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			if !ctx.IsGet() {
+				next(ctx, now, adds...)
+				return
+			}
+
+			reqCC := parseCacheControl(ctx.Request.Header.Peek(fasthttp.HeaderCacheControl))
+			if reqCC.noStore {
+				next(ctx, now, adds...)
+				return
+			}
+
+			key := config.key(ctx)
+
+			if !reqCC.noCache {
+				if e, state := c.lookup(key, now); e != nil {
+					if state == stateStale {
+						c.revalidate(ctx, key, &config, next, adds)
+					}
+
+					serve(ctx, e, now, state)
+
+					return
+				}
+			}
+
+			f, leader := c.acquireFlight(key)
+			if !leader {
+				timer := time.NewTimer(config.WaitTimeout)
+
+				select {
+				case <-f.done:
+					timer.Stop()
+
+					if e, state := c.lookup(key, time.Now()); state == stateFresh {
+						serve(ctx, e, now, state)
+						return
+					}
+				case <-timer.C:
+				}
+
+				next(ctx, now, adds...)
+
+				return
+			}
+
+			defer c.releaseFlight(key, f)
+
+			before := snapshot(&ctx.Response.Header)
+
+			next(ctx, now, adds...)
+
+			ctx.Response.Header.Set(HeaderCache, "MISS")
+
+			if e := c.store(ctx, before, key, &config, time.Now()); e != nil {
+				ctx.Response.Header.Set(fasthttp.HeaderETag, e.etag)
+
+				if notModified(ctx, e.etag) {
+					ctx.NotModified()
+				}
+			}
+		}
+	}
+}
+
+// revalidate refreshes stale entry in background with a copy of the request
+func (c *Cache) revalidate(ctx *fasthttp.RequestCtx, key string, config *Config, next transport.RouterFunc, adds []string) {
+	f, leader := c.acquireFlight(key)
+	if !leader {
+		return
+	}
+
+	req := &fasthttp.Request{}
+	ctx.Request.CopyTo(req)
+	req.Header.Del(fasthttp.HeaderIfNoneMatch)
+	req.Header.Del(fasthttp.HeaderCacheControl)
+
+	remoteAddr := ctx.RemoteAddr()
+
+	go func() {
+		defer c.releaseFlight(key, f)
+
+		bg := &fasthttp.RequestCtx{}
+		bg.Init(req, remoteAddr, nil)
+		before := snapshot(&bg.Response.Header)
+
+		next(bg, time.Now(), adds...)
+		c.store(bg, before, key, config, time.Now())
+	}()
+}
+
+// snapshot returns headers of response as set of "key: value" lines
+func snapshot(h *fasthttp.ResponseHeader) map[string]bool {
+	result := make(map[string]bool)
+	h.VisitAll(func(k, v []byte) {
+		result[string(k)+": "+string(v)] = true
+	})
+
+	return result
+}
+
+// handlerHeaders returns headers which are not in before snapshot, i.e. set by the handler
+func handlerHeaders(h *fasthttp.ResponseHeader, before map[string]bool) []header {
+	var result []header
+
+	h.VisitAll(func(k, v []byte) {
+		key := string(k)
+
+		switch key {
+		case fasthttp.HeaderContentLength, fasthttp.HeaderConnection, fasthttp.HeaderETag, HeaderCache:
+			return
+		}
+
+		if line := key + ": " + string(v); !before[line] {
+			result = append(result, header{key: key, value: string(v)})
+		}
+	})
+
+	return result
+}
+
+// store saves response if it's cacheable, before is snapshot of headers set before the handler
+func (c *Cache) store(ctx *fasthttp.RequestCtx, before map[string]bool, key string, config *Config, now time.Time) *entry {
+	resp := &ctx.Response
+	if resp.StatusCode() != fasthttp.StatusOK || resp.IsBodyStream() || ctx.Hijacked() ||
+		len(resp.Header.Peek("Set-Cookie")) > 0 {
+		return nil
+	}
+
+	cc := parseCacheControl(resp.Header.Peek(fasthttp.HeaderCacheControl))
+	if cc.noStore || cc.noCache || cc.private {
+		return nil
+	}
+
+	ttl, swr := config.TTL, config.StaleWhileRevalidate
+
+	switch {
+	case cc.hasSMaxAge:
+		ttl = cc.sMaxAge
+	case cc.hasMaxAge:
+		ttl = cc.maxAge
+	}
+
+	if cc.hasSWR {
+		swr = cc.swr
+	}
+
+	if ttl <= 0 {
+		return nil
+	}
+
+	e := &entry{
+		key:        key,
+		path:       string(ctx.Path()),
+		header:     handlerHeaders(&resp.Header, before),
+		body:       append([]byte(nil), resp.Body()...),
+		etag:       string(resp.Header.Peek(fasthttp.HeaderETag)),
+		stored:     now,
+		freshUntil: now.Add(ttl),
+		staleUntil: now.Add(ttl + swr),
+	}
+
+	if e.etag == "" {
+		h := fnv.New64a()
+		h.Write(e.body)
+		e.etag = "W/\"" + strconv.FormatUint(h.Sum64(), 16) + "\""
+	}
+
+	e.size = int64(len(e.key) + len(e.body) + len(e.etag))
+	for _, h := range e.header {
+		e.size += int64(len(h.key) + len(h.value))
+	}
+	c.add(e)
+
+	return e
+}
+
+// serve writes cached response, headers are merged into the ones set by outer middlewares
+func serve(ctx *fasthttp.RequestCtx, e *entry, now time.Time, state entryState) {
+	seen := make(map[string]bool, len(e.header))
+
+	for _, h := range e.header {
+		if seen[h.key] {
+			ctx.Response.Header.Add(h.key, h.value)
+		} else {
+			ctx.Response.Header.Set(h.key, h.value)
+			seen[h.key] = true
+		}
+	}
+
+	ctx.Response.Header.Set(fasthttp.HeaderETag, e.etag)
+
+	age := int(now.Sub(e.stored) / time.Second)
+	if age < 0 {
+		age = 0
+	}
+
+	ctx.Response.Header.Set("Age", strconv.Itoa(age))
+
+	if state == stateStale {
+		ctx.Response.Header.Set(HeaderCache, "STALE")
+	} else {
+		ctx.Response.Header.Set(HeaderCache, "HIT")
+	}
+
+	if notModified(ctx, e.etag) {
+		ctx.NotModified()
+		return
+	}
+
+	ctx.Response.SetBody(e.body)
+}
+
+func notModified(ctx *fasthttp.RequestCtx, etag string) bool {
+	inm := ctx.Request.Header.Peek(fasthttp.HeaderIfNoneMatch)
+	if len(inm) == 0 {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for _, candidate := range strings.Split(string(inm), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (config *Config) key(ctx *fasthttp.RequestCtx) string {
+	var b strings.Builder
+
+	b.Write(ctx.Method())
+	b.WriteByte(' ')
+	b.Write(ctx.Path())
+	b.WriteByte('?')
+
+	args := ctx.QueryArgs()
+
+	if config.Query == nil {
+		pairs := make([]string, 0, args.Len())
+		args.VisitAll(func(k, v []byte) {
+			pairs = append(pairs, string(k)+"="+string(v))
+		})
+		sort.Strings(pairs)
+		b.WriteString(strings.Join(pairs, "&"))
+	} else {
+		for i, name := range config.Query {
+			if i > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(name)
+			b.WriteByte('=')
+			b.Write(args.Peek(name))
+		}
+	}
+
+	for _, name := range config.Headers {
+		b.WriteByte('|')
+		b.Write(ctx.Request.Header.Peek(name))
+	}
+
+	return b.String()
+}
+
+// RegisterPurge adds POST route removing cached entries. Body {"prefix":"/reports"}
+// purges pathes with the prefix, empty body purges everything.
+// Requests are authenticated with adminSecret, see transport.AuthenticateAdmin
+func RegisterPurge(path string, c *Cache, adminSecret string, server transport.PathesLogger) {
+	RegisterPurgeOn(transport.DefaultRouter, path, c, adminSecret, server)
+}
+
+// RegisterPurgeOn adds purge route to router, see RegisterPurge
+func RegisterPurgeOn(router *transport.Router, path string, c *Cache, adminSecret string, server transport.PathesLogger) {
+	router.AddPostRoute(path, func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		if !transport.AuthenticateAdmin(ctx, adminSecret) {
+			return
+		}
+
+		resp := transport.GetResponse()
+		req := &struct {
+			Prefix string `json:"prefix"`
+		}{}
+
+		if len(ctx.PostBody()) > 0 {
+			if err := transport.DecodeJSONBody(ctx, req); err != nil {
+				resp.SetError(transport.RequestError, err.Error())
+				transport.SendResponse(ctx, resp, now, server)
+
+				return
+			}
+		}
+
+		resp.Payload = map[string]int{"purged": c.Purge(req.Prefix)}
+		transport.SendResponse(ctx, resp, now, server)
+	})
+}
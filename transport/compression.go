@@ -0,0 +1,358 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+	"github.com/valyala/fasthttp"
+)
+
+// Encodings
+const (
+	EncodingGzip     = "gzip"
+	EncodingBrotli   = "br"
+	EncodingZstd     = "zstd"
+	EncodingDeflate  = "deflate"
+	EncodingIdentity = "identity"
+)
+
+// CompressionConfig describes response compression and request decompression
+type CompressionConfig struct {
+	// MinSize responses with smaller body are sent as is
+	MinSize int `json:"minSize"`
+	// Encodings supported response encodings in order of server preference
+	Encodings []string `json:"encodings"`
+	// MaxRequestBodySize limits size of the decompressed request body
+	MaxRequestBodySize int `json:"maxRequestBodySize"`
+}
+
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var (
+	// DefaultCompression used by CompressHandler when config is nil
+	DefaultCompression = CompressionConfig{
+		MinSize:            1024,
+		Encodings:          []string{EncodingBrotli, EncodingZstd, EncodingGzip},
+		MaxRequestBodySize: fasthttp.DefaultMaxRequestBodySize,
+	}
+	noCompressionPathes = make(map[string]struct{})
+	compressors         = map[string]*sync.Pool{
+		EncodingGzip: {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+			return w
+		}},
+		EncodingBrotli: {New: func() interface{} {
+			return brotli.NewWriterLevel(nil, 4)
+		}},
+		EncodingZstd: {New: func() interface{} {
+			w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+			return w
+		}},
+	}
+	compressBuffers = sync.Pool{
+		New: func() interface{} {
+			return &bytes.Buffer{}
+		},
+	}
+)
+
+// DisableCompression turns off response compression for path
+func DisableCompression(path string) {
+	noCompressionPathes[path] = struct{}{}
+}
+
+// CompressHandler decompresses request bodies sent with Content-Encoding
+// and compresses responses according to Accept-Encoding.
+// Streamed responses (SendResponseStream, SendNDJSON) are compressed on the fly.
+// If config is nil DefaultCompression is used
+func CompressHandler(handler fasthttp.RequestHandler, config *CompressionConfig) fasthttp.RequestHandler {
+	if config == nil {
+		config = &DefaultCompression
+	}
+
+	return func(ctx *fasthttp.RequestCtx) {
+		if !decompressRequest(ctx, config) {
+			return
+		}
+
+		ctx.SetUserValue(compressionConfigKey, config)
+		handler(ctx)
+		compressResponse(ctx, config)
+	}
+}
+
+func acquireCompressor(encoding string, w io.Writer) compressWriter {
+	p, ok := compressors[encoding]
+	if !ok {
+		return nil
+	}
+
+	cw := p.Get().(compressWriter)
+	cw.Reset(w)
+
+	return cw
+}
+
+func releaseCompressor(encoding string, cw compressWriter) {
+	cw.Reset(nil)
+	compressors[encoding].Put(cw)
+}
+
+func acquireCompressBuffer() *bytes.Buffer {
+	return compressBuffers.Get().(*bytes.Buffer)
+}
+
+func releaseCompressBuffer(buf *bytes.Buffer) {
+	buf.Reset()
+	compressBuffers.Put(buf)
+}
+
+// NegotiateEncoding returns the best response encoding for the request
+// or empty string if response should not be compressed
+func NegotiateEncoding(ctx *fasthttp.RequestCtx, config *CompressionConfig) string {
+	if config == nil {
+		config = &DefaultCompression
+	}
+
+	if _, ok := noCompressionPathes[string(ctx.Path())]; ok {
+		return ""
+	}
+
+	return negotiateEncoding(string(ctx.Request.Header.Peek(fasthttp.HeaderAcceptEncoding)), config.Encodings)
+}
+
+// negotiateEncoding picks encoding with the highest q-value, ties are
+// resolved by the server preference order
+func negotiateEncoding(header string, supported []string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, q := part, 1.0
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			name = strings.TrimSpace(part[:i])
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		accepted[strings.ToLower(name)] = q
+	}
+
+	var (
+		best  string
+		bestQ float64
+	)
+
+	for _, enc := range supported {
+		q, ok := accepted[enc]
+		if !ok {
+			if q, ok = accepted["*"]; !ok {
+				continue
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+
+	return best
+}
+
+// AddVary adds header name to Vary unless it's already listed
+func AddVary(h *fasthttp.ResponseHeader, name string) {
+	found := false
+
+	h.VisitAll(func(k, v []byte) {
+		if found || string(k) != fasthttp.HeaderVary {
+			return
+		}
+
+		for _, listed := range strings.Split(string(v), ",") {
+			if listed = strings.TrimSpace(listed); listed == "*" || strings.EqualFold(listed, name) {
+				found = true
+			}
+		}
+	})
+
+	if !found {
+		h.Add(fasthttp.HeaderVary, name)
+	}
+}
+
+func isCompressible(contentType []byte) bool {
+	ct := string(contentType)
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+
+	ct = strings.ToLower(strings.TrimSpace(ct))
+
+	switch {
+	case strings.HasPrefix(ct, "text/"),
+		strings.HasSuffix(ct, "+json"),
+		strings.HasSuffix(ct, "+xml"),
+		ct == ApplicationJSON,
+		ct == ApplicationXML,
+		ct == "application/javascript",
+		ct == ApplicationNDJSON,
+		ct == "image/svg+xml":
+		return true
+	}
+
+	return false
+}
+
+func compressResponse(ctx *fasthttp.RequestCtx, config *CompressionConfig) {
+	resp := &ctx.Response
+
+	if ctx.IsHead() || resp.IsBodyStream() || len(resp.Header.Peek(fasthttp.HeaderContentEncoding)) > 0 {
+		return
+	}
+
+	if sc := resp.StatusCode(); sc < 200 || sc == fasthttp.StatusNoContent || sc == fasthttp.StatusNotModified {
+		return
+	}
+
+	if _, ok := noCompressionPathes[string(ctx.Path())]; ok || !isCompressible(resp.Header.ContentType()) {
+		return
+	}
+
+	AddVary(&resp.Header, fasthttp.HeaderAcceptEncoding)
+
+	body := resp.Body()
+	if len(body) < config.MinSize {
+		return
+	}
+
+	encoding := negotiateEncoding(string(ctx.Request.Header.Peek(fasthttp.HeaderAcceptEncoding)), config.Encodings)
+	if encoding == "" {
+		return
+	}
+
+	buf := acquireCompressBuffer()
+	defer releaseCompressBuffer(buf)
+
+	cw := acquireCompressor(encoding, buf)
+	if cw == nil {
+		return
+	}
+
+	_, err := cw.Write(body)
+	if err == nil {
+		err = cw.Close()
+	}
+
+	releaseCompressor(encoding, cw)
+
+	if err != nil || buf.Len() >= len(body) {
+		return
+	}
+
+	resp.SetBody(buf.Bytes())
+	resp.Header.Set(fasthttp.HeaderContentEncoding, encoding)
+
+	// the compressed representation is no longer byte-equal to the original one
+	if etag := resp.Header.Peek(fasthttp.HeaderETag); len(etag) > 0 && !bytes.HasPrefix(etag, []byte("W/")) {
+		resp.Header.Set(fasthttp.HeaderETag, "W/"+string(etag))
+	}
+}
+
+func decompressRequest(ctx *fasthttp.RequestCtx, config *CompressionConfig) bool {
+	encoding := strings.ToLower(strings.TrimSpace(string(ctx.Request.Header.Peek(fasthttp.HeaderContentEncoding))))
+	if encoding == "" || encoding == EncodingIdentity {
+		return true
+	}
+
+	body, err := decompressBody(encoding, ctx.Request.Body(), config.MaxRequestBodySize)
+	if err != nil {
+		logger.Printf("[%s %s %d] request decompression error: %v\n", ctx.Method(), ctx.Path(), ctx.ID(), err)
+
+		if err == errBodyTooLarge {
+			ctx.Error(err.Error(), fasthttp.StatusRequestEntityTooLarge)
+		} else {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		}
+
+		return false
+	}
+
+	ctx.Request.SetBody(body)
+	ctx.Request.Header.Del(fasthttp.HeaderContentEncoding)
+
+	return true
+}
+
+func decompressBody(encoding string, body []byte, limit int) ([]byte, error) {
+	var (
+		r   io.Reader
+		src = bytes.NewReader(body)
+	)
+
+	switch encoding {
+	case EncodingGzip:
+		zr, err := gzip.NewReader(src)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case EncodingDeflate:
+		zr, err := zlib.NewReader(src)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case EncodingBrotli:
+		r = brotli.NewReader(src)
+	case EncodingZstd:
+		zr, err := zstd.NewReader(src, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, errUnsupportedEncoding
+	}
+
+	if limit <= 0 {
+		limit = fasthttp.DefaultMaxRequestBodySize
+	}
+
+	result, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) > limit {
+		return nil, errBodyTooLarge
+	}
+
+	return result, nil
+}
@@ -0,0 +1,163 @@
+package transport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/valyala/fasthttp"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+
+	cases := []struct {
+		header, expected string
+	}{
+		{"", ""},
+		{"gzip", EncodingGzip},
+		{"gzip, br", EncodingBrotli},
+		{"gzip;q=1, br;q=0.5", EncodingGzip},
+		{"br;q=0", ""},
+		{"br;q=0, zstd", EncodingZstd},
+		{"*", EncodingBrotli},
+		{"*;q=0.1, gzip", EncodingGzip},
+		{"identity", ""},
+		{"deflate", ""},
+		{"GZIP", EncodingGzip},
+	}
+
+	for _, c := range cases {
+		if actual := negotiateEncoding(c.header, supported); actual != c.expected {
+			t.Errorf("%q: expecting %q, got %q", c.header, c.expected, actual)
+		}
+	}
+}
+
+func compressRequest(path, acceptEncoding string, handler fasthttp.RequestHandler, config *CompressionConfig) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(path)
+	ctx.Request.Header.Set(fasthttp.HeaderAcceptEncoding, acceptEncoding)
+
+	CompressHandler(handler, config)(ctx)
+
+	return ctx
+}
+
+func TestCompressHandler(t *testing.T) {
+	body := strings.Repeat(`{"name":"value"}`, 200)
+	config := &CompressionConfig{MinSize: 100, Encodings: []string{EncodingBrotli, EncodingZstd, EncodingGzip}}
+
+	handler := func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType(ApplicationJSON)
+		ctx.Response.Header.Set(fasthttp.HeaderVary, "Origin, accept-encoding")
+		ctx.Response.Header.Set(fasthttp.HeaderETag, `"v1"`)
+		ctx.SetBodyString(body)
+	}
+
+	for _, encoding := range []string{EncodingBrotli, EncodingZstd, EncodingGzip} {
+		ctx := compressRequest("/data", encoding, handler, config)
+
+		if actual := string(ctx.Response.Header.Peek(fasthttp.HeaderContentEncoding)); actual != encoding {
+			t.Fatalf("Expecting %s encoding, got %q", encoding, actual)
+		}
+
+		plain, err := decompressBody(encoding, ctx.Response.Body(), 0)
+		if err != nil || string(plain) != body {
+			t.Errorf("%s: unexpected body %v", encoding, err)
+		}
+
+		if etag := string(ctx.Response.Header.Peek(fasthttp.HeaderETag)); etag != `W/"v1"` {
+			t.Errorf("Expecting weak etag, got %s", etag)
+		}
+
+		vary := 0
+		ctx.Response.Header.VisitAll(func(k, v []byte) {
+			if string(k) == fasthttp.HeaderVary {
+				vary++
+			}
+		})
+
+		if vary != 1 {
+			t.Errorf("Expecting Accept-Encoding not to be added to Vary twice, got %d headers", vary)
+		}
+	}
+
+	ctx := compressRequest("/data", "identity", handler, config)
+	if len(ctx.Response.Header.Peek(fasthttp.HeaderContentEncoding)) > 0 || string(ctx.Response.Body()) != body {
+		t.Error("Expecting identity response")
+	}
+
+	small := compressRequest("/small", EncodingGzip, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType(ApplicationJSON)
+		ctx.SetBodyString(`{}`)
+	}, config)
+
+	if len(small.Response.Header.Peek(fasthttp.HeaderContentEncoding)) > 0 ||
+		string(small.Response.Header.Peek(fasthttp.HeaderVary)) != fasthttp.HeaderAcceptEncoding {
+		t.Error("Expecting response smaller than MinSize to be sent as is with Vary")
+	}
+
+	image := compressRequest("/image", EncodingGzip, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType("image/png")
+		ctx.SetBodyString(body)
+	}, config)
+
+	if len(image.Response.Header.Peek(fasthttp.HeaderContentEncoding)) > 0 {
+		t.Error("Expecting incompressible content type to be sent as is")
+	}
+
+	DisableCompression("/disabled")
+	defer delete(noCompressionPathes, "/disabled")
+
+	disabled := compressRequest("/disabled", EncodingGzip, handler, config)
+	if len(disabled.Response.Header.Peek(fasthttp.HeaderContentEncoding)) > 0 {
+		t.Error("Expecting compression to be disabled for path")
+	}
+}
+
+func TestDecompressRequest(t *testing.T) {
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(strings.Repeat("a", 100)))
+	zw.Close()
+
+	var received string
+
+	handler := CompressHandler(func(ctx *fasthttp.RequestCtx) {
+		received = string(ctx.PostBody())
+	}, &CompressionConfig{MaxRequestBodySize: 100})
+
+	request := func(encoding string, body []byte) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.Header.Set(fasthttp.HeaderContentEncoding, encoding)
+		ctx.Request.SetBody(body)
+		handler(ctx)
+
+		return ctx
+	}
+
+	if ctx := request(EncodingGzip, buf.Bytes()); ctx.Response.StatusCode() != fasthttp.StatusOK || received != strings.Repeat("a", 100) {
+		t.Errorf("Expecting decompressed body, got %d %q", ctx.Response.StatusCode(), received)
+	}
+
+	buf.Reset()
+	zw = gzip.NewWriter(&buf)
+	zw.Write([]byte(strings.Repeat("a", 101)))
+	zw.Close()
+
+	if ctx := request(EncodingGzip, buf.Bytes()); ctx.Response.StatusCode() != fasthttp.StatusRequestEntityTooLarge {
+		t.Errorf("Expecting 413 for body over the limit, got %d", ctx.Response.StatusCode())
+	}
+
+	if ctx := request(EncodingGzip, []byte("not gzip")); ctx.Response.StatusCode() != fasthttp.StatusBadRequest {
+		t.Errorf("Expecting 400 for broken body, got %d", ctx.Response.StatusCode())
+	}
+
+	if ctx := request("lz4", []byte("x")); ctx.Response.StatusCode() != fasthttp.StatusBadRequest {
+		t.Errorf("Expecting 400 for unsupported encoding, got %d", ctx.Response.StatusCode())
+	}
+}
@@ -1,14 +1,36 @@
 package transport
 
-import "sync"
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
 
 // LogFlag alias
 type LogFlag int
 
-// LogPathes struct
+// LogPathes struct.
+// Keys of FullLogPathes are exact pathes or patterns: key ending with "*" matches
+// every path with such prefix, other keys with "*", "?" or "[" are path.Match patterns.
+// Exact path wins, then the longest pattern.
+// After the first lookup FullLogPathes must be changed only with the setters
 type LogPathes struct {
 	sync.RWMutex
 	FullLogPathes map[string]LogFlag `json:"fullLogPathes"`
+	temporary     map[string]*logFlagChange
+	// patterns pattern keys of FullLogPathes, indexed on the first lookup
+	// and kept up to date by the setters, so misses don't scan the whole map
+	patterns        []string
+	patternsIndexed bool
+}
+
+// logFlagChange temporary change which is reverted after expiration
+type logFlagChange struct {
+	previous    LogFlag
+	hadPrevious bool
+	expires     time.Time
+	timer       *time.Timer
 }
 
 // LogFlags
@@ -39,8 +61,18 @@ func (srv *LogPathes) GetLogFlag(path string) (result LogFlag) {
 
 	srv.RLock()
 
+	if !srv.patternsIndexed {
+		srv.RUnlock()
+		srv.Lock()
+		srv.indexPatterns()
+		srv.Unlock()
+		srv.RLock()
+	}
+
 	if srv.FullLogPathes != nil {
-		result, ok = srv.FullLogPathes[path]
+		if result, ok = srv.FullLogPathes[path]; !ok {
+			result, ok = srv.matchPattern(path)
+		}
 	}
 
 	srv.RUnlock()
@@ -52,14 +84,154 @@ func (srv *LogPathes) GetLogFlag(path string) (result LogFlag) {
 	return result
 }
 
+func (srv *LogPathes) matchPattern(p string) (result LogFlag, found bool) {
+	var best string
+
+	for _, pattern := range srv.patterns {
+		if len(pattern) <= len(best) {
+			continue
+		}
+
+		var matched bool
+		if strings.HasSuffix(pattern, "*") && !strings.ContainsAny(pattern[:len(pattern)-1], "*?[") {
+			matched = strings.HasPrefix(p, pattern[:len(pattern)-1])
+		} else {
+			matched, _ = path.Match(pattern, p)
+		}
+
+		if matched {
+			best, result, found = pattern, srv.FullLogPathes[pattern], true
+		}
+	}
+
+	return result, found
+}
+
+func isLogPattern(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// indexPatterns collects pattern keys of FullLogPathes once
+func (srv *LogPathes) indexPatterns() {
+	if srv.patternsIndexed {
+		return
+	}
+
+	srv.patterns = srv.patterns[:0]
+	for p := range srv.FullLogPathes {
+		if isLogPattern(p) {
+			srv.patterns = append(srv.patterns, p)
+		}
+	}
+
+	srv.patternsIndexed = true
+}
+
+// addPattern indexes the key added to FullLogPathes
+func (srv *LogPathes) addPattern(p string) {
+	if !srv.patternsIndexed || !isLogPattern(p) {
+		return
+	}
+
+	for _, pattern := range srv.patterns {
+		if pattern == p {
+			return
+		}
+	}
+
+	srv.patterns = append(srv.patterns, p)
+}
+
+// removePattern drops the key removed from FullLogPathes
+func (srv *LogPathes) removePattern(p string) {
+	if !srv.patternsIndexed || !isLogPattern(p) {
+		return
+	}
+
+	for i, pattern := range srv.patterns {
+		if pattern == p {
+			srv.patterns = append(srv.patterns[:i], srv.patterns[i+1:]...)
+			return
+		}
+	}
+}
+
 // SetLogFlag changes log level for path
 func (srv *LogPathes) SetLogFlag(path string, flag LogFlag) {
 	srv.Lock()
+	srv.setLogFlag(path, flag)
+	srv.Unlock()
+}
 
+func (srv *LogPathes) setLogFlag(path string, flag LogFlag) {
 	if srv.FullLogPathes == nil {
 		srv.FullLogPathes = make(map[string]LogFlag)
 	}
 
+	if change, ok := srv.temporary[path]; ok {
+		change.timer.Stop()
+		delete(srv.temporary, path)
+	}
+
 	srv.FullLogPathes[path] = flag
+	srv.addPattern(path)
+}
+
+// SetLogFlagTTL changes log level for path for ttl, then the previous level is restored
+func (srv *LogPathes) SetLogFlagTTL(path string, flag LogFlag, ttl time.Duration) {
+	srv.Lock()
+	defer srv.Unlock()
+
+	if srv.FullLogPathes == nil {
+		srv.FullLogPathes = make(map[string]LogFlag)
+	}
+
+	if srv.temporary == nil {
+		srv.temporary = make(map[string]*logFlagChange)
+	}
+
+	change, ok := srv.temporary[path]
+	if ok {
+		change.timer.Stop()
+	} else {
+		change = &logFlagChange{}
+		change.previous, change.hadPrevious = srv.FullLogPathes[path]
+		srv.temporary[path] = change
+	}
+
+	change.expires = time.Now().Add(ttl)
+	change.timer = time.AfterFunc(ttl, func() { srv.revert(path, change) })
+	srv.FullLogPathes[path] = flag
+	srv.addPattern(path)
+}
+
+func (srv *LogPathes) revert(path string, change *logFlagChange) {
+	srv.Lock()
+
+	if srv.temporary[path] == change {
+		delete(srv.temporary, path)
+
+		if change.hadPrevious {
+			srv.FullLogPathes[path] = change.previous
+		} else {
+			delete(srv.FullLogPathes, path)
+			srv.removePattern(path)
+		}
+	}
+
+	srv.Unlock()
+}
+
+// DeleteLogFlag removes path, so default level is used
+func (srv *LogPathes) DeleteLogFlag(path string) {
+	srv.Lock()
+
+	if change, ok := srv.temporary[path]; ok {
+		change.timer.Stop()
+		delete(srv.temporary, path)
+	}
+
+	delete(srv.FullLogPathes, path)
+	srv.removePattern(path)
 	srv.Unlock()
 }
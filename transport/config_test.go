@@ -0,0 +1,84 @@
+package transport
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogPathesGetLogFlag(t *testing.T) {
+	srv := &LogPathes{FullLogPathes: map[string]LogFlag{
+		"/api/users":  0,
+		"/api/*":      ToLog | FullLog,
+		"/api/ping/*": 0,
+		"/v?/item":    FullLog,
+	}}
+	tests := []struct {
+		path string
+		want LogFlag
+	}{
+		{path: "/api/users", want: 0},
+		{path: "/api/orders/1", want: ToLog | FullLog},
+		{path: "/api/ping/x", want: 0},
+		{path: "/v1/item", want: FullLog},
+		{path: "/other", want: ToLog},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := srv.GetLogFlag(tt.path); got != tt.want {
+				t.Errorf("GetLogFlag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogPathesSetLogFlagTTL(t *testing.T) {
+	srv := &LogPathes{}
+	srv.SetLogFlag("/a", 0)
+	srv.SetLogFlagTTL("/a", ToLog|FullLog, 20*time.Millisecond)
+	srv.SetLogFlagTTL("/b", ToLog|FullLog, 20*time.Millisecond)
+
+	if got := srv.GetLogFlag("/a"); got != ToLog|FullLog {
+		t.Errorf("Expecting temporary flag, got %v", got)
+	}
+	if flags := srv.permanentLogFlags(); len(flags) != 1 || flags["/a"] != 0 {
+		t.Errorf("Expecting only permanent flags, got %v", flags)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if got := srv.GetLogFlag("/a"); got != 0 {
+		t.Errorf("Expecting previous flag to be restored, got %v", got)
+	}
+	if flags := srv.LogFlags(); len(flags) != 1 {
+		t.Errorf("Expecting temporary path to be removed, got %v", flags)
+	}
+}
+
+func TestLogPathesPatternIndex(t *testing.T) {
+	srv := &LogPathes{FullLogPathes: map[string]LogFlag{"/api/*": 0}}
+
+	if got := srv.GetLogFlag("/api/users"); got != 0 {
+		t.Errorf("Expecting configured pattern, got %v", got)
+	}
+
+	srv.SetLogFlag("/api/users/*", FullLog)
+	if got := srv.GetLogFlag("/api/users/1"); got != FullLog {
+		t.Errorf("Expecting pattern added after lookup, got %v", got)
+	}
+
+	srv.SetLogFlagTTL("/tmp/*", 0, 20*time.Millisecond)
+	if got := srv.GetLogFlag("/tmp/x"); got != 0 {
+		t.Errorf("Expecting temporary pattern, got %v", got)
+	}
+
+	srv.DeleteLogFlag("/api/users/*")
+	if got := srv.GetLogFlag("/api/users/1"); got != 0 {
+		t.Errorf("Expecting deleted pattern not to match, got %v", got)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if got := srv.GetLogFlag("/tmp/x"); got != ToLog || len(srv.patterns) != 1 {
+		t.Errorf("Expecting expired pattern to be removed, got %v %v", got, srv.patterns)
+	}
+}
@@ -6,5 +6,6 @@ const (
 	ApplicationJSONUTF8    = "application/json; charset=UTF-8"
 	ApplicationOctetStream = "application/octet-stream"
 	ApplicationXML         = "application/xml"
+	ApplicationNDJSON      = "application/x-ndjson"
 	TextPlain              = "text/plain"
 )
@@ -1,7 +1,14 @@
 package transport
 
+import "errors"
+
 // Base errors
 const (
 	SignatureMismatch = 1 + iota
 	RequestError
 )
+
+var (
+	errBodyTooLarge        = errors.New("request body too large")
+	errUnsupportedEncoding = errors.New("unsupported content encoding")
+)
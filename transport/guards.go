@@ -0,0 +1,168 @@
+package transport
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// requestContextKey user value key under which Timeout stores request context
+const requestContextKey = "transport.context"
+
+// Guards describes per route limits
+type Guards struct {
+	// MaxBodySize requests with bigger body get 413, see LimitBodySize
+	MaxBodySize int `json:"maxBodySize"`
+	// Timeout of the handler, on expiration TimeoutStatus is sent
+	Timeout       time.Duration `json:"timeout"`
+	TimeoutStatus int           `json:"timeoutStatus"`
+	// MaxConcurrent handlers running at once
+	MaxConcurrent int `json:"maxConcurrent"`
+	// MaxQueue requests waiting for a free slot, others are shed with 503
+	MaxQueue int `json:"maxQueue"`
+	// QueueTimeout max time request waits in the queue
+	QueueTimeout time.Duration `json:"queueTimeout"`
+}
+
+// Middleware returns middleware applying all configured guards
+func (g Guards) Middleware() Middleware {
+	var middlewares []Middleware
+
+	if g.MaxBodySize > 0 {
+		middlewares = append(middlewares, LimitBodySize(g.MaxBodySize))
+	}
+
+	// timeout goes first so that timed out handler still holds its concurrency slot
+	if g.Timeout > 0 {
+		middlewares = append(middlewares, Timeout(g.Timeout, g.TimeoutStatus))
+	}
+
+	if g.MaxConcurrent > 0 {
+		middlewares = append(middlewares, LimitConcurrency(g.MaxConcurrent, g.MaxQueue, g.QueueTimeout))
+	}
+
+	return func(next RouterFunc) RouterFunc {
+		return Chain(next, middlewares...)
+	}
+}
+
+// RequestContext returns context of the request.
+// It is cancelled when handler deadline set by Timeout expires or server shuts down
+func RequestContext(ctx *fasthttp.RequestCtx) context.Context {
+	if result, ok := ctx.UserValue(requestContextKey).(context.Context); ok {
+		return result
+	}
+
+	return ctx
+}
+
+// LimitBodySize rejects requests with body bigger than maxSize with 413.
+// fasthttp reads the whole body before routing, so it doesn't save memory or reading time,
+// it only sets lower limit for the route. fasthttp.Server.MaxRequestBodySize is the real limit
+// of the body read from the connection
+func LimitBodySize(maxSize int) Middleware {
+	return func(next RouterFunc) RouterFunc {
+		return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			if ctx.Request.Header.ContentLength() > maxSize || len(ctx.PostBody()) > maxSize {
+				ctx.Error(errBodyTooLarge.Error(), fasthttp.StatusRequestEntityTooLarge)
+				return
+			}
+
+			next(ctx, now, adds...)
+		}
+	}
+}
+
+// Timeout runs handler with deadline. If handler doesn't finish in time,
+// statusCode (503 by default) is sent and context returned by RequestContext is cancelled.
+// Changes made by handler to the response after the deadline are ignored
+func Timeout(timeout time.Duration, statusCode int) Middleware {
+	if statusCode == 0 {
+		statusCode = fasthttp.StatusServiceUnavailable
+	}
+
+	return func(next RouterFunc) RouterFunc {
+		return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			reqCtx, cancel := context.WithTimeout(RequestContext(ctx), timeout)
+			defer cancel()
+
+			ctx.SetUserValue(requestContextKey, reqCtx)
+
+			done := make(chan struct{})
+			go func() {
+				next(ctx, now, adds...)
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-reqCtx.Done():
+				logger.Printf("[%s %s %d] handler timeout %s\n", ctx.Method(), ctx.Path(), ctx.ID(), timeout)
+				ctx.TimeoutErrorWithCode(fasthttp.StatusMessage(statusCode), statusCode)
+			}
+		}
+	}
+}
+
+// LimitConcurrency allows at most maxConcurrent handlers to run at once.
+// Up to maxQueue requests wait for a slot no longer than queueTimeout
+// (or until request context is done if 0), the rest are shed with 503
+func LimitConcurrency(maxConcurrent, maxQueue int, queueTimeout time.Duration) Middleware {
+	slots := make(chan struct{}, maxConcurrent)
+
+	var queued int32
+
+	return func(next RouterFunc) RouterFunc {
+		return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			select {
+			case slots <- struct{}{}:
+			default:
+				if atomic.AddInt32(&queued, 1) > int32(maxQueue) {
+					atomic.AddInt32(&queued, -1)
+					shed(ctx)
+
+					return
+				}
+
+				ok := waitSlot(slots, queueTimeout, RequestContext(ctx).Done())
+				atomic.AddInt32(&queued, -1)
+
+				if !ok {
+					shed(ctx)
+					return
+				}
+			}
+
+			defer func() { <-slots }()
+
+			next(ctx, now, adds...)
+		}
+	}
+}
+
+func waitSlot(slots chan struct{}, timeout time.Duration, done <-chan struct{}) bool {
+	var expired <-chan time.Time
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		expired = timer.C
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-expired:
+		return false
+	case <-done:
+		return false
+	}
+}
+
+func shed(ctx *fasthttp.RequestCtx) {
+	ctx.Error("Too many concurrent requests", fasthttp.StatusServiceUnavailable)
+	ctx.Response.Header.Set(fasthttp.HeaderRetryAfter, "1")
+}
@@ -0,0 +1,107 @@
+package transport_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/finnan444/utils/transport/transporttest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestLimitBodySize(t *testing.T) {
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+
+	srv.AddPostRoute("/upload", transport.LimitBodySize(4)(func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {}))
+
+	srv.Post("/upload").Body([]byte("1234"), "text/plain").Do().AssertStatus(fasthttp.StatusOK)
+	srv.Post("/upload").Body([]byte("12345"), "text/plain").Do().AssertStatus(fasthttp.StatusRequestEntityTooLarge)
+}
+
+func TestTimeout(t *testing.T) {
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+
+	cancelled := make(chan bool, 1)
+
+	srv.AddGetRoute("/slow", transport.Timeout(20*time.Millisecond, fasthttp.StatusGatewayTimeout)(
+		func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			select {
+			case <-transport.RequestContext(ctx).Done():
+				cancelled <- true
+			case <-time.After(time.Second):
+				cancelled <- false
+			}
+		}))
+
+	srv.AddGetRoute("/fast", transport.Timeout(time.Second, 0)(
+		func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			ctx.SetBodyString("ok")
+		}))
+
+	srv.Get("/slow").Do().AssertStatus(fasthttp.StatusGatewayTimeout)
+
+	if !<-cancelled {
+		t.Error("Expecting request context to be cancelled on timeout")
+	}
+
+	if body := string(srv.Get("/fast").Do().AssertStatus(fasthttp.StatusOK).Body()); body != "ok" {
+		t.Errorf("Expecting handler response, got %q", body)
+	}
+}
+
+func TestLimitConcurrency(t *testing.T) {
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+
+	entered := make(chan struct{}, 3)
+	release := make(chan struct{})
+
+	handler := func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		entered <- struct{}{}
+		<-release
+	}
+
+	srv.AddGetRoute("/queue", transport.LimitConcurrency(1, 1, 0)(handler))
+	srv.AddGetRoute("/timeout", transport.LimitConcurrency(1, 1, 20*time.Millisecond)(handler))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		srv.Get("/queue").Do().AssertStatus(fasthttp.StatusOK)
+	}()
+
+	<-entered
+
+	go func() {
+		defer wg.Done()
+		srv.Get("/queue").Do().AssertStatus(fasthttp.StatusOK)
+	}()
+
+	// the second request takes the only place in the queue
+	time.Sleep(20 * time.Millisecond)
+
+	srv.Get("/queue").Do().AssertStatus(fasthttp.StatusServiceUnavailable).AssertHeader(fasthttp.HeaderRetryAfter, "1")
+
+	release <- struct{}{}
+	<-entered
+	release <- struct{}{}
+	wg.Wait()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		srv.Get("/timeout").Do().AssertStatus(fasthttp.StatusOK)
+	}()
+
+	<-entered
+
+	srv.Get("/timeout").Do().AssertStatus(fasthttp.StatusServiceUnavailable)
+
+	release <- struct{}{}
+	wg.Wait()
+}
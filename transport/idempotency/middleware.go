@@ -0,0 +1,222 @@
+package idempotency
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+)
+
+// Headers
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+const pollInterval = 20 * time.Millisecond
+
+// Config describes idempotency middleware
+type Config struct {
+	Store Store
+	// TTL of the completed request record
+	TTL time.Duration
+	// LockTTL of the in-progress reservation, protects from handlers that never finished
+	LockTTL time.Duration
+	// Wait for the concurrent duplicate to finish, if 0 duplicate gets 409 at once
+	Wait time.Duration
+	// Required rejects requests without Idempotency-Key with 400
+	Required bool
+}
+
+// Middleware executes request with the same Idempotency-Key only once.
+// Completed response is stored with status, headers set by the handler and body
+// and replayed to later duplicates. Duplicate waiting for the request which is cancelled
+// is executed itself.
+// Responses with status 5xx, timed out and streamed responses are not stored,
+// so such requests can be retried
+func Middleware(config Config) transport.Middleware {
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+
+	if config.LockTTL <= 0 {
+		config.LockTTL = time.Minute
+	}
+
+	return func(next transport.RouterFunc) transport.RouterFunc {
+		return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			key := string(ctx.Request.Header.Peek(HeaderKey))
+			if key == "" {
+				if config.Required {
+					ctx.Error("Missing "+HeaderKey+" header", fasthttp.StatusBadRequest)
+				} else {
+					next(ctx, now, adds...)
+				}
+
+				return
+			}
+
+			storeKey := string(ctx.Method()) + " " + string(ctx.Path()) + " " + key
+			fingerprint, owner := requestFingerprint(ctx), newOwner()
+
+			// the second attempt is made if the request the duplicate waited for is cancelled
+			for attempt := 0; ; attempt++ {
+				existing, started, err := config.Store.Start(storeKey, fingerprint, owner, config.LockTTL)
+				if err != nil {
+					log.Printf("[Idempotency] error starting %s: %v", storeKey, err)
+					ctx.Error("Idempotency store error", fasthttp.StatusInternalServerError)
+
+					return
+				}
+
+				if started {
+					break
+				}
+
+				if handleDuplicate(ctx, &config, existing, fingerprint, attempt == 0) {
+					return
+				}
+			}
+
+			before := snapshot(&ctx.Response.Header)
+
+			next(ctx, now, adds...)
+
+			resp := &ctx.Response
+			if resp.IsBodyStream() || resp.StatusCode() >= fasthttp.StatusInternalServerError || ctx.LastTimeoutErrorResponse() != nil {
+				if err := config.Store.Cancel(storeKey, owner); err != nil {
+					log.Printf("[Idempotency] error cancelling %s: %v", storeKey, err)
+				}
+
+				return
+			}
+
+			record := &Record{
+				Key:         storeKey,
+				Fingerprint: fingerprint,
+				StatusCode:  resp.StatusCode(),
+				ContentType: string(resp.Header.ContentType()),
+				Body:        append([]byte(nil), resp.Body()...),
+				Header:      handlerHeader(&resp.Header, before),
+				Owner:       owner,
+			}
+
+			if err := config.Store.Complete(record, config.TTL); err != nil {
+				log.Printf("[Idempotency] error completing %s: %v", storeKey, err)
+			}
+		}
+	}
+}
+
+// snapshot returns headers of response as set of "key: value" lines
+func snapshot(h *fasthttp.ResponseHeader) map[string]bool {
+	result := make(map[string]bool)
+	h.VisitAll(func(k, v []byte) {
+		result[string(k)+": "+string(v)] = true
+	})
+
+	return result
+}
+
+// handlerHeader returns headers which are not in before snapshot, i.e. set by the handler
+func handlerHeader(h *fasthttp.ResponseHeader, before map[string]bool) map[string][]string {
+	var result map[string][]string
+
+	h.VisitAll(func(k, v []byte) {
+		key := string(k)
+
+		switch key {
+		case fasthttp.HeaderContentLength, fasthttp.HeaderContentType, fasthttp.HeaderConnection:
+			return
+		}
+
+		if line := key + ": " + string(v); !before[line] {
+			if result == nil {
+				result = make(map[string][]string)
+			}
+
+			result[key] = append(result[key], string(v))
+		}
+	})
+
+	return result
+}
+
+// handleDuplicate replays completed request or rejects the duplicate.
+// It returns false if the duplicate waited for the request which is cancelled and canRetry is set
+func handleDuplicate(ctx *fasthttp.RequestCtx, config *Config, record *Record, fingerprint string, canRetry bool) bool {
+	if record.Fingerprint != fingerprint {
+		ctx.Error(HeaderKey+" is already used for another request", fasthttp.StatusUnprocessableEntity)
+		return true
+	}
+
+	if !record.Done && config.Wait > 0 {
+		if record = waitDone(config.Store, record, config.Wait); record == nil && canRetry {
+			return false
+		}
+	}
+
+	if record == nil || !record.Done {
+		ctx.Error("Request with the same "+HeaderKey+" is in progress", fasthttp.StatusConflict)
+		return true
+	}
+
+	ctx.SetStatusCode(record.StatusCode)
+
+	for key, values := range record.Header {
+		for i, value := range values {
+			if i == 0 {
+				ctx.Response.Header.Set(key, value)
+			} else {
+				ctx.Response.Header.Add(key, value)
+			}
+		}
+	}
+
+	ctx.SetContentType(record.ContentType)
+	ctx.SetBody(record.Body)
+	ctx.Response.Header.Set(HeaderReplayed, "true")
+
+	return true
+}
+
+func waitDone(store Store, record *Record, wait time.Duration) *Record {
+	deadline := time.Now().Add(wait)
+	key := record.Key
+
+	for time.Now().Before(deadline) {
+		time.Sleep(pollInterval)
+
+		current, err := store.Get(key)
+		if err != nil {
+			log.Printf("[Idempotency] error getting %s: %v", key, err)
+			return record
+		}
+
+		if current == nil || current.Done {
+			return current
+		}
+	}
+
+	return record
+}
+
+// newOwner returns random token identifying the request which reserves the key
+func newOwner() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func requestFingerprint(ctx *fasthttp.RequestCtx) string {
+	h := md5.New()
+	_, _ = h.Write(ctx.RequestURI())
+	_, _ = h.Write(ctx.PostBody())
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,177 @@
+package idempotency
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport/transporttest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMiddlewareReplay(t *testing.T) {
+	var calls int32
+
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+
+	srv.AddPostRoute("/orders", Middleware(Config{Store: NewMemoryStore(0), Required: true})(
+		func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			n := atomic.AddInt32(&calls, 1)
+			ctx.SetStatusCode(fasthttp.StatusCreated)
+			ctx.SetContentType("application/json")
+			ctx.Response.Header.Set("Location", "/orders/"+strconv.Itoa(int(n)))
+			ctx.SetBodyString(`{"id":` + strconv.Itoa(int(n)) + `}`)
+		}))
+
+	srv.Post("/orders").Body([]byte(`{"sum":1}`), "application/json").Do().AssertStatus(fasthttp.StatusBadRequest)
+
+	srv.Post("/orders").Header(HeaderKey, "a").Body([]byte(`{"sum":1}`), "application/json").Do().
+		AssertStatus(fasthttp.StatusCreated).
+		AssertHeader(HeaderReplayed, "")
+
+	resp := srv.Post("/orders").Header(HeaderKey, "a").Body([]byte(`{"sum":1}`), "application/json").Do().
+		AssertStatus(fasthttp.StatusCreated).
+		AssertHeader(HeaderReplayed, "true").
+		AssertHeader("Location", "/orders/1").
+		AssertHeader(fasthttp.HeaderContentType, "application/json")
+
+	if body := string(resp.Body()); body != `{"id":1}` || calls != 1 {
+		t.Errorf("Expecting replayed response of the single call, got %q after %d calls", body, calls)
+	}
+
+	srv.Post("/orders").Header(HeaderKey, "a").Body([]byte(`{"sum":2}`), "application/json").Do().
+		AssertStatus(fasthttp.StatusUnprocessableEntity)
+}
+
+func TestMiddlewareConcurrentDuplicate(t *testing.T) {
+	var calls int32
+
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+
+	started := make(chan struct{})
+	store := NewMemoryStore(0)
+
+	srv.AddPostRoute("/pay", Middleware(Config{Store: store, Wait: time.Second})(
+		func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+				time.Sleep(100 * time.Millisecond)
+			}
+			ctx.SetBodyString("paid")
+		}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.Post("/pay").Header(HeaderKey, "k").Do().AssertStatus(fasthttp.StatusOK)
+	}()
+
+	<-started
+
+	resp := srv.Post("/pay").Header(HeaderKey, "k").Do().AssertStatus(fasthttp.StatusOK).AssertHeader(HeaderReplayed, "true")
+	<-done
+
+	if body := string(resp.Body()); body != "paid" || calls != 1 {
+		t.Errorf("Expecting duplicate to wait for the response, got %q after %d calls", body, calls)
+	}
+}
+
+func TestMiddlewareCancel(t *testing.T) {
+	var calls int32
+
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+
+	started := make(chan struct{})
+
+	srv.AddPostRoute("/pay", Middleware(Config{Store: NewMemoryStore(0), Wait: time.Second})(
+		func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+				time.Sleep(100 * time.Millisecond)
+				ctx.Error("upstream is down", fasthttp.StatusBadGateway)
+
+				return
+			}
+			ctx.SetBodyString("paid")
+		}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.Post("/pay").Header(HeaderKey, "k").Do().AssertStatus(fasthttp.StatusBadGateway)
+	}()
+
+	<-started
+
+	// the waiting duplicate is executed after the failed request is cancelled
+	srv.Post("/pay").Header(HeaderKey, "k").Do().AssertStatus(fasthttp.StatusOK).AssertHeader(HeaderReplayed, "")
+	<-done
+
+	srv.Post("/pay").Header(HeaderKey, "k").Do().AssertStatus(fasthttp.StatusOK).AssertHeader(HeaderReplayed, "true")
+
+	if calls != 2 {
+		t.Errorf("Expecting 2 calls, got %d", calls)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(0)
+
+	if _, started, _ := store.Start("k", "f", "a", time.Minute); !started {
+		t.Fatal("Expecting key to be started")
+	}
+
+	existing, started, _ := store.Start("k", "f", "b", time.Minute)
+	if started || existing == nil || existing.Done {
+		t.Fatalf("Expecting in-progress record, got %+v %v", existing, started)
+	}
+
+	if err := store.Complete(&Record{Key: "k", Fingerprint: "f", Owner: "b"}, time.Minute); err != ErrNotOwner {
+		t.Fatalf("Expecting ErrNotOwner, got %v", err)
+	}
+
+	store.Complete(&Record{Key: "k", Fingerprint: "f", StatusCode: 200, Header: map[string][]string{"X-A": {"1"}}, Owner: "a"}, time.Minute)
+
+	if record, _ := store.Get("k"); record == nil || !record.Done || record.Header["X-A"][0] != "1" {
+		t.Fatalf("Expecting completed record, got %+v", record)
+	}
+
+	if err := store.Cancel("k", "b"); err != ErrNotOwner {
+		t.Fatalf("Expecting ErrNotOwner, got %v", err)
+	}
+
+	store.Cancel("k", "a")
+
+	if record, _ := store.Get("k"); record != nil {
+		t.Fatalf("Expecting cancelled record to be removed, got %+v", record)
+	}
+
+	store.Start("expired", "f", "a", -time.Second)
+	store.cleanup()
+
+	if _, started, _ = store.Start("expired", "f", "b", time.Minute); !started {
+		t.Fatal("Expecting expired key to be started again")
+	}
+
+	store.Start("taken", "f", "a", -time.Second)
+
+	if _, started, _ = store.Start("taken", "f", "b", time.Minute); !started {
+		t.Fatal("Expecting expired reservation to be taken over")
+	}
+
+	if err := store.Complete(&Record{Key: "taken", Fingerprint: "f", Owner: "a"}, time.Minute); err != ErrNotOwner {
+		t.Fatalf("Expecting late request not to overwrite the new one, got %v", err)
+	}
+
+	if err := store.Cancel("taken", "a"); err != ErrNotOwner {
+		t.Fatalf("Expecting late request not to cancel the new one, got %v", err)
+	}
+
+	if record, _ := store.Get("taken"); record == nil || record.Owner != "b" || record.Done {
+		t.Fatalf("Expecting reservation of the new request, got %+v", record)
+	}
+}
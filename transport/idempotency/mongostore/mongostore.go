@@ -0,0 +1,115 @@
+package mongostore
+
+import (
+	"time"
+
+	"github.com/finnan444/utils/database/mongo"
+	"github.com/finnan444/utils/transport/idempotency"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// Store keeps idempotency records in mongo collection
+type Store struct {
+	db         *mongo.DBWrapper
+	collection string
+}
+
+// New creates store, expired records are removed by the ttl index
+func New(db *mongo.DBWrapper, collection string) (*Store, error) {
+	result := &Store{db: db, collection: collection}
+
+	d, closeDB := db.Get()
+	defer closeDB()
+
+	err := d.C(collection).EnsureIndex(mgo.Index{Key: []string{"expires"}, ExpireAfter: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Start impl
+func (s *Store) Start(key, fingerprint, owner string, ttl time.Duration) (*idempotency.Record, bool, error) {
+	d, closeDB := s.db.Get()
+	defer closeDB()
+
+	now := time.Now()
+	record := &idempotency.Record{Key: key, Fingerprint: fingerprint, Owner: owner, Expires: now.Add(ttl)}
+
+	err := d.C(s.collection).Insert(record)
+	if err == nil {
+		return nil, true, nil
+	}
+
+	if !mgo.IsDup(err) {
+		return nil, false, err
+	}
+
+	// ttl index removes documents with a delay, expired record can be taken over
+	err = d.C(s.collection).Update(bson.M{"_id": key, "expires": bson.M{"$lt": now}}, record)
+	if err == nil {
+		return nil, true, nil
+	}
+
+	if err != mgo.ErrNotFound {
+		return nil, false, err
+	}
+
+	existing := &idempotency.Record{}
+	if err = d.C(s.collection).FindId(key).One(existing); err != nil {
+		return nil, false, err
+	}
+
+	return existing, false, nil
+}
+
+// Complete impl
+func (s *Store) Complete(record *idempotency.Record, ttl time.Duration) error {
+	d, closeDB := s.db.Get()
+	defer closeDB()
+
+	copied := *record
+	copied.Done = true
+	copied.Expires = time.Now().Add(ttl)
+
+	err := d.C(s.collection).Update(bson.M{"_id": record.Key, "owner": record.Owner}, &copied)
+	if err == mgo.ErrNotFound {
+		return idempotency.ErrNotOwner
+	}
+
+	return err
+}
+
+// Cancel impl
+func (s *Store) Cancel(key, owner string) error {
+	d, closeDB := s.db.Get()
+	defer closeDB()
+
+	err := d.C(s.collection).Remove(bson.M{"_id": key, "owner": owner})
+	if err == mgo.ErrNotFound {
+		return idempotency.ErrNotOwner
+	}
+
+	return err
+}
+
+// Get impl
+func (s *Store) Get(key string) (*idempotency.Record, error) {
+	d, closeDB := s.db.Get()
+	defer closeDB()
+
+	result := &idempotency.Record{}
+
+	err := d.C(s.collection).Find(bson.M{"_id": key, "expires": bson.M{"$gt": time.Now()}}).One(result)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
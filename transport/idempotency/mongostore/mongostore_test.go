@@ -0,0 +1,73 @@
+package mongostore
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/database/mongo"
+	"github.com/finnan444/utils/transport/idempotency"
+)
+
+func TestStore(t *testing.T) {
+	if os.Getenv("MONGO_CONFIG") == "" {
+		t.Skip("MONGO_CONFIG is not set")
+	}
+
+	store, err := New(mongo.DB, "idempotency_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "test " + time.Now().String()
+	defer store.Cancel(key, "b")
+
+	if _, started, err := store.Start(key, "f", "a", time.Minute); err != nil || !started {
+		t.Fatalf("Expecting key to be started, got %v %v", started, err)
+	}
+
+	existing, started, err := store.Start(key, "f", "b", time.Minute)
+	if err != nil || started || existing == nil || existing.Done {
+		t.Fatalf("Expecting in-progress record, got %+v %v %v", existing, started, err)
+	}
+
+	record := &idempotency.Record{Key: key, Fingerprint: "f", StatusCode: 201, Body: []byte("ok"),
+		Header: map[string][]string{"Location": {"/orders/1"}}, Owner: "b"}
+	if err = store.Complete(record, time.Minute); err != idempotency.ErrNotOwner {
+		t.Fatalf("Expecting ErrNotOwner, got %v", err)
+	}
+
+	record.Owner = "a"
+	if err = store.Complete(record, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if existing, err = store.Get(key); err != nil || existing == nil || !existing.Done ||
+		string(existing.Body) != "ok" || existing.Header["Location"][0] != "/orders/1" {
+		t.Fatalf("Expecting completed record, got %+v %v", existing, err)
+	}
+
+	if err = store.Cancel(key, "b"); err != idempotency.ErrNotOwner {
+		t.Fatalf("Expecting ErrNotOwner, got %v", err)
+	}
+
+	if err = store.Cancel(key, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if existing, err = store.Get(key); err != nil || existing != nil {
+		t.Fatalf("Expecting cancelled record to be removed, got %+v %v", existing, err)
+	}
+
+	if _, started, err = store.Start(key, "f", "a", -time.Second); err != nil || !started {
+		t.Fatalf("Expecting key to be started, got %v %v", started, err)
+	}
+
+	if _, started, err = store.Start(key, "f", "b", time.Minute); err != nil || !started {
+		t.Fatalf("Expecting expired record to be taken over, got %v %v", started, err)
+	}
+
+	if err = store.Cancel(key, "a"); err != idempotency.ErrNotOwner {
+		t.Fatalf("Expecting late request not to cancel the new one, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package idempotency
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/finnan444/utils/time/cron"
+)
+
+// Record describes request executed with idempotency key
+type Record struct {
+	Key string `json:"key" bson:"_id"`
+	// Fingerprint of the request, the same key can't be reused for other request
+	Fingerprint string    `json:"fingerprint" bson:"fingerprint"`
+	Done        bool      `json:"done" bson:"done"`
+	StatusCode  int       `json:"statusCode" bson:"statusCode"`
+	ContentType string    `json:"contentType" bson:"contentType"`
+	Body        []byte    `json:"body" bson:"body"`
+	Expires     time.Time `json:"expires" bson:"expires"`
+	// Header set by the handler except Content-Type
+	Header map[string][]string `json:"header,omitempty" bson:"header,omitempty"`
+	// Owner token of the request which reserved the key
+	Owner string `json:"-" bson:"owner"`
+}
+
+// ErrNotOwner returned by Complete and Cancel if the reservation expired
+// and the key is taken over by another request
+var ErrNotOwner = errors.New("idempotency: key is reserved by another request")
+
+// Store keeps idempotency records
+type Store interface {
+	// Start reserves key for the request identified by owner token. If key is already reserved
+	// the existing record is returned and started is false
+	Start(key, fingerprint, owner string, ttl time.Duration) (existing *Record, started bool, err error)
+	// Complete saves response of the started request, record.Owner must be the owner
+	// of the reservation, ErrNotOwner is returned otherwise
+	Complete(record *Record, ttl time.Duration) error
+	// Cancel removes reservation of the owner, so the request can be retried
+	Cancel(key, owner string) error
+	// Get returns record, nil if key is unknown
+	Get(key string) (*Record, error)
+}
+
+// MemoryStore in-memory Store
+type MemoryStore struct {
+	sync.Mutex
+	records map[string]*Record
+}
+
+// NewMemoryStore creates memory store, expired records are removed every cleanupPeriod
+func NewMemoryStore(cleanupPeriod time.Duration) *MemoryStore {
+	result := &MemoryStore{records: make(map[string]*Record)}
+
+	if cleanupPeriod > 0 {
+		cron.Add(cleanupPeriod, time.UTC, result.cleanup)
+	}
+
+	return result
+}
+
+// Start impl
+func (s *MemoryStore) Start(key, fingerprint, owner string, ttl time.Duration) (*Record, bool, error) {
+	now := time.Now()
+
+	s.Lock()
+	defer s.Unlock()
+
+	if record, ok := s.records[key]; ok && record.Expires.After(now) {
+		copied := *record
+		return &copied, false, nil
+	}
+
+	s.records[key] = &Record{Key: key, Fingerprint: fingerprint, Owner: owner, Expires: now.Add(ttl)}
+
+	return nil, true, nil
+}
+
+// Complete impl
+func (s *MemoryStore) Complete(record *Record, ttl time.Duration) error {
+	copied := *record
+	copied.Done = true
+	copied.Expires = time.Now().Add(ttl)
+
+	s.Lock()
+	defer s.Unlock()
+
+	if current, ok := s.records[record.Key]; !ok || current.Owner != record.Owner {
+		return ErrNotOwner
+	}
+
+	s.records[record.Key] = &copied
+
+	return nil
+}
+
+// Cancel impl
+func (s *MemoryStore) Cancel(key, owner string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if current, ok := s.records[key]; !ok || current.Owner != owner {
+		return ErrNotOwner
+	}
+
+	delete(s.records, key)
+
+	return nil
+}
+
+// Get impl
+func (s *MemoryStore) Get(key string) (*Record, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if record, ok := s.records[key]; ok && record.Expires.After(time.Now()) {
+		copied := *record
+		return &copied, nil
+	}
+
+	return nil, nil
+}
+
+func (s *MemoryStore) cleanup() {
+	now := time.Now()
+
+	s.Lock()
+
+	for k, v := range s.records {
+		if v.Expires.Before(now) {
+			delete(s.records, k)
+		}
+	}
+
+	s.Unlock()
+}
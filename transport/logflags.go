@@ -0,0 +1,233 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// LogFlagsPath path of the log flags admin api
+const LogFlagsPath = "/internal/logflags"
+
+// LogFlagInfo describes log flag of the path
+type LogFlagInfo struct {
+	Path    string     `json:"path"`
+	Flag    LogFlag    `json:"flag"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// logFlagsRequest body of PUT /internal/logflags.
+// Flags are changed permanently and persisted to the config file unless ttl is set
+type logFlagsRequest struct {
+	Pathes map[string]LogFlag `json:"pathes"`
+	Delete []string           `json:"delete"`
+	TTL    string             `json:"ttl"`
+}
+
+var saveLogFlagsLock sync.Mutex
+
+// LogFlags returns all configured flags sorted by path
+func (srv *LogPathes) LogFlags() []LogFlagInfo {
+	srv.RLock()
+
+	result := make([]LogFlagInfo, 0, len(srv.FullLogPathes))
+	for p, flag := range srv.FullLogPathes {
+		info := LogFlagInfo{Path: p, Flag: flag}
+		if change, ok := srv.temporary[p]; ok {
+			expires := change.expires
+			info.Expires = &expires
+		}
+		result = append(result, info)
+	}
+
+	srv.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+
+	return result
+}
+
+// permanentLogFlags returns flags without temporary changes
+func (srv *LogPathes) permanentLogFlags() map[string]LogFlag {
+	srv.RLock()
+	defer srv.RUnlock()
+
+	result := make(map[string]LogFlag, len(srv.FullLogPathes))
+	for p, flag := range srv.FullLogPathes {
+		if change, ok := srv.temporary[p]; ok {
+			if !change.hadPrevious {
+				continue
+			}
+			flag = change.previous
+		}
+		result[p] = flag
+	}
+
+	return result
+}
+
+// SaveLogFlags writes permanent flags to the "fullLogPathes" key of json config file,
+// other keys of the file are kept as is
+func (srv *LogPathes) SaveLogFlags(filename string) error {
+	saveLogFlagsLock.Lock()
+	defer saveLogFlagsLock.Unlock()
+
+	return saveLogFlags(filename, srv.permanentLogFlags())
+}
+
+// saveLogFlags writes flags to the config file, caller holds saveLogFlagsLock
+func saveLogFlags(filename string, permanent map[string]LogFlag) error {
+	conf := make(map[string]json.RawMessage)
+	mode := os.FileMode(0644)
+
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode()
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		if err = json.Unmarshal(data, &conf); err != nil {
+			return err
+		}
+	}
+
+	flags, err := json.Marshal(permanent)
+	if err != nil {
+		return err
+	}
+
+	conf["fullLogPathes"] = flags
+
+	data, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Chmod(mode)
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// RegisterLogFlagsAdmin adds GET and PUT /internal/logflags routes to list and change log flags.
+// Requests are authenticated with adminSecret, see AuthenticateAdmin.
+// If configFile is not empty permanent changes are saved to it
+func RegisterLogFlagsAdmin(pathes *LogPathes, adminSecret, configFile string) {
+	DefaultRouter.RegisterLogFlagsAdmin(pathes, adminSecret, configFile)
+}
+
+// RegisterLogFlagsAdmin adds log flags admin routes to the router, see RegisterLogFlagsAdmin
+func (r *Router) RegisterLogFlagsAdmin(pathes *LogPathes, adminSecret, configFile string) {
+	r.AddGetRoute(LogFlagsPath, func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		if !AuthenticateAdmin(ctx, adminSecret) {
+			return
+		}
+
+		resp := GetResponse()
+		resp.Payload = pathes.LogFlags()
+		SendResponse(ctx, resp, now, pathes)
+	})
+
+	r.AddPutRoute(LogFlagsPath, func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		if !AuthenticateAdmin(ctx, adminSecret) {
+			return
+		}
+
+		resp := GetResponse()
+
+		if status, err := changeLogFlags(ctx, pathes, configFile); err != nil {
+			logger.Printf("[PUT %s %d] log flags change error: %v\n", LogFlagsPath, ctx.ID(), err)
+			ctx.SetStatusCode(status)
+			resp.SetError(RequestError, err.Error())
+		} else {
+			resp.Payload = pathes.LogFlags()
+		}
+
+		SendResponse(ctx, resp, now, pathes)
+	})
+}
+
+// changeLogFlags applies the request, permanent changes are saved to configFile
+// before they are applied, so failed save leaves flags unchanged.
+// Status code of the failure is returned with the error
+func changeLogFlags(ctx *fasthttp.RequestCtx, pathes *LogPathes, configFile string) (int, error) {
+	req := &logFlagsRequest{}
+	if err := DecodeJSONBody(ctx, req); err != nil {
+		return fasthttp.StatusBadRequest, err
+	}
+
+	if len(req.Pathes) == 0 && len(req.Delete) == 0 {
+		return fasthttp.StatusBadRequest, errors.New("nothing to change")
+	}
+
+	var ttl time.Duration
+
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil {
+			return fasthttp.StatusBadRequest, err
+		}
+
+		if ttl <= 0 {
+			return fasthttp.StatusBadRequest, errors.New("ttl must be positive")
+		}
+	}
+
+	if configFile != "" && (ttl == 0 || len(req.Delete) > 0) {
+		saveLogFlagsLock.Lock()
+		defer saveLogFlagsLock.Unlock()
+
+		permanent := pathes.permanentLogFlags()
+		for _, p := range req.Delete {
+			delete(permanent, p)
+		}
+
+		if ttl == 0 {
+			for p, flag := range req.Pathes {
+				permanent[p] = flag
+			}
+		}
+
+		if err := saveLogFlags(configFile, permanent); err != nil {
+			return fasthttp.StatusInternalServerError, err
+		}
+	}
+
+	for _, p := range req.Delete {
+		pathes.DeleteLogFlag(p)
+	}
+
+	for p, flag := range req.Pathes {
+		if ttl > 0 {
+			pathes.SetLogFlagTTL(p, flag, ttl)
+		} else {
+			pathes.SetLogFlag(p, flag)
+		}
+	}
+
+	return 0, nil
+}
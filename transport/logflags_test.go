@@ -0,0 +1,119 @@
+package transport_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/finnan444/utils/transport/transporttest"
+	"github.com/valyala/fasthttp"
+)
+
+func newLogFlagsServer(t *testing.T, configFile string) (*transporttest.Server, *transport.LogPathes) {
+	pathes := &transport.LogPathes{FullLogPathes: map[string]transport.LogFlag{"/a": transport.ToLog, "/b": 0}}
+
+	srv := transporttest.NewServer(t, nil)
+	srv.RegisterLogFlagsAdmin(pathes, "admin", configFile)
+
+	return srv, pathes
+}
+
+func readLogFlags(t *testing.T, filename string) (conf map[string]json.RawMessage, flags map[string]transport.LogFlag) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = json.Unmarshal(data, &conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = json.Unmarshal(conf["fullLogPathes"], &flags); err != nil {
+		t.Fatal(err)
+	}
+
+	return conf, flags
+}
+
+func TestLogFlagsAdminAuth(t *testing.T) {
+	srv, _ := newLogFlagsServer(t, "")
+	defer srv.Close()
+
+	srv.Get(transport.LogFlagsPath).Do().AssertStatus(fasthttp.StatusUnauthorized)
+	srv.Get(transport.LogFlagsPath).Header(transport.AdminTokenHeader, "wrong").Do().AssertStatus(fasthttp.StatusUnauthorized)
+	srv.Put(transport.LogFlagsPath).JSON(map[string]interface{}{"pathes": map[string]int{"/c": 0}}).Do().
+		AssertStatus(fasthttp.StatusUnauthorized)
+
+	var flags []transport.LogFlagInfo
+	srv.Get(transport.LogFlagsPath).Query("token", "admin").Do().AssertStatus(fasthttp.StatusOK).DecodePayload(&flags)
+
+	if len(flags) != 2 || flags[0].Path != "/a" || flags[1].Path != "/b" {
+		t.Errorf("Unexpected flags %+v", flags)
+	}
+}
+
+func TestLogFlagsAdminChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logflags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(configFile, []byte(`{"port":"8080","fullLogPathes":{"/a":1,"/b":0}}`), 0600)
+
+	srv, pathes := newLogFlagsServer(t, configFile)
+	defer srv.Close()
+
+	put := func(body interface{}) *transporttest.Response {
+		return srv.Put(transport.LogFlagsPath).Header(transport.AdminTokenHeader, "admin").JSON(body).Do()
+	}
+
+	put(map[string]interface{}{}).AssertStatus(fasthttp.StatusBadRequest)
+	put(map[string]interface{}{"pathes": map[string]int{"/c": 3}, "ttl": "-1s"}).AssertStatus(fasthttp.StatusBadRequest)
+
+	put(map[string]interface{}{"pathes": map[string]int{"/c": 3}, "delete": []string{"/b"}}).AssertStatus(fasthttp.StatusOK)
+
+	conf, flags := readLogFlags(t, configFile)
+	if string(conf["port"]) != `"8080"` || len(flags) != 2 || flags["/a"] != transport.ToLog || flags["/c"] != 3 {
+		t.Errorf("Unexpected saved config %s %v", conf["port"], flags)
+	}
+
+	if pathes.GetLogFlag("/b") != transport.ToLog || pathes.GetLogFlag("/c") != 3 {
+		t.Errorf("Expecting changes to be applied, got %+v", pathes.LogFlags())
+	}
+
+	var changed []transport.LogFlagInfo
+	put(map[string]interface{}{"pathes": map[string]int{"/a": 0}, "ttl": "50ms"}).AssertStatus(fasthttp.StatusOK).DecodePayload(&changed)
+
+	if len(changed) != 2 || changed[0].Path != "/a" || changed[0].Flag != 0 || changed[0].Expires == nil {
+		t.Errorf("Expecting temporary flag, got %+v", changed)
+	}
+
+	if _, flags = readLogFlags(t, configFile); flags["/a"] != transport.ToLog {
+		t.Errorf("Expecting temporary change not to be saved, got %v", flags)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if flag := pathes.GetLogFlag("/a"); flag != transport.ToLog {
+		t.Errorf("Expecting temporary change to expire, got %v", flag)
+	}
+}
+
+func TestLogFlagsAdminSaveError(t *testing.T) {
+	srv, pathes := newLogFlagsServer(t, filepath.Join(os.TempDir(), "missing-logflags-dir", "config.json"))
+	defer srv.Close()
+
+	srv.Put(transport.LogFlagsPath).Header(transport.AdminTokenHeader, "admin").
+		JSON(map[string]interface{}{"pathes": map[string]int{"/a": 0}, "delete": []string{"/b"}}).Do().
+		AssertStatus(fasthttp.StatusInternalServerError)
+
+	if pathes.GetLogFlag("/a") != transport.ToLog || len(pathes.LogFlags()) != 2 {
+		t.Errorf("Expecting flags to stay unchanged after failed save, got %+v", pathes.LogFlags())
+	}
+}
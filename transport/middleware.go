@@ -0,0 +1,104 @@
+package transport
+
+import "regexp"
+
+// Middleware wraps RouterFunc with additional behaviour
+type Middleware func(RouterFunc) RouterFunc
+
+// Chain wraps handler with middlewares, the first middleware is the outermost one
+func Chain(handler RouterFunc, middlewares ...Middleware) RouterFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// Group registers routes with common path prefix and middlewares
+type Group struct {
+	router      *Router
+	prefix      string
+	middlewares []Middleware
+}
+
+// NewGroup creates routes group of DefaultRouter
+func NewGroup(prefix string, middlewares ...Middleware) *Group {
+	return DefaultRouter.NewGroup(prefix, middlewares...)
+}
+
+// NewGroup creates routes group
+func (r *Router) NewGroup(prefix string, middlewares ...Middleware) *Group {
+	return &Group{router: r, prefix: prefix, middlewares: middlewares}
+}
+
+// Group creates subgroup which inherits prefix and middlewares
+func (g *Group) Group(prefix string, middlewares ...Middleware) *Group {
+	all := make([]Middleware, 0, len(g.middlewares)+len(middlewares))
+	all = append(all, g.middlewares...)
+	all = append(all, middlewares...)
+
+	return &Group{router: g.router, prefix: g.prefix + prefix, middlewares: all}
+}
+
+// Use adds middlewares for routes added after the call
+func (g *Group) Use(middlewares ...Middleware) {
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+// AddGetRoute adds get route
+func (g *Group) AddGetRoute(path string, handler RouterFunc, middlewares ...Middleware) {
+	g.router.AddGetRoute(g.prefix+path, g.wrap(handler, middlewares))
+}
+
+// AddGetPrefixRoute adds get route for all pathes starting with prefix
+func (g *Group) AddGetPrefixRoute(prefix string, handler RouterFunc, middlewares ...Middleware) {
+	g.router.AddGetPrefixRoute(g.prefix+prefix, g.wrap(handler, middlewares))
+}
+
+// AddHeadPrefixRoute adds head route for all pathes starting with prefix
+func (g *Group) AddHeadPrefixRoute(prefix string, handler RouterFunc, middlewares ...Middleware) {
+	g.router.AddHeadPrefixRoute(g.prefix+prefix, g.wrap(handler, middlewares))
+}
+
+// AddPrefixRoute adds route for all methods and pathes starting with prefix
+func (g *Group) AddPrefixRoute(prefix string, handler RouterFunc, middlewares ...Middleware) {
+	g.router.AddPrefixRoute(g.prefix+prefix, g.wrap(handler, middlewares))
+}
+
+// AddPostRoute adds post route
+func (g *Group) AddPostRoute(path string, handler RouterFunc, middlewares ...Middleware) {
+	g.router.AddPostRoute(g.prefix+path, g.wrap(handler, middlewares))
+}
+
+// AddPutRoute adds put route
+func (g *Group) AddPutRoute(path string, handler RouterFunc, middlewares ...Middleware) {
+	g.router.AddPutRoute(g.prefix+path, g.wrap(handler, middlewares))
+}
+
+// AddGetRegexpRoute adds get regexp route, prefix is matched literally
+func (g *Group) AddGetRegexpRoute(path string, handler RouterFunc, middlewares ...Middleware) {
+	g.router.AddGetRegexpRoute(g.regexpPath(path), g.wrap(handler, middlewares))
+}
+
+// AddPostRegexpRoute adds post regexp route, prefix is matched literally
+func (g *Group) AddPostRegexpRoute(path string, handler RouterFunc, middlewares ...Middleware) {
+	g.router.AddPostRegexpRoute(g.regexpPath(path), g.wrap(handler, middlewares))
+}
+
+// Describe adds metadata of the group route, see transport.Describe.
+// Regexp routes are described with the full expression by transport.Describe
+func (g *Group) Describe(method, path string, doc *RouteDoc) {
+	g.router.Describe(method, g.prefix+path, doc)
+}
+
+func (g *Group) wrap(handler RouterFunc, middlewares []Middleware) RouterFunc {
+	return Chain(Chain(handler, middlewares...), g.middlewares...)
+}
+
+func (g *Group) regexpPath(path string) string {
+	if len(path) > 0 && path[0] == '^' {
+		return "^" + regexp.QuoteMeta(g.prefix) + path[1:]
+	}
+
+	return regexp.QuoteMeta(g.prefix) + path
+}
@@ -0,0 +1,467 @@
+package transport
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finnan444/utils/transport/response"
+	"github.com/valyala/fasthttp"
+)
+
+// OpenAPIPath path of the generated OpenAPI document
+const OpenAPIPath = "/internal/openapi.json"
+
+// RouteDoc describes route in the OpenAPI document
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	// Request value of the request body type, for GET routes its fields are query parameters
+	Request interface{}
+	// Response value of the BasicResponse payload type
+	Response interface{}
+	// Kernel means request is wrapped into KernelBaseRequest
+	Kernel bool
+	// Raw means response is sent as is, without BasicResponse envelope
+	Raw bool
+	// Codes BasicResponse codes with descriptions
+	Codes map[int]string
+	// Statuses additional http statuses with descriptions
+	Statuses map[int]string
+}
+
+// OpenAPIInfo info section of the document
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+var (
+	openAPIInfo = OpenAPIInfo{Title: "API", Version: "1.0"}
+	timeType    = reflect.TypeOf(time.Time{})
+	rawType     = reflect.TypeOf(json.RawMessage{})
+)
+
+// Describe adds metadata of the route, path is the same as passed to Add*Route
+// (regexp for regexp routes, prefix for prefix routes)
+func Describe(method, path string, doc *RouteDoc) {
+	DefaultRouter.Describe(method, path, doc)
+}
+
+// Describe adds metadata of the route, path is the same as passed to Add*Route
+// (regexp for regexp routes, prefix for prefix routes)
+func (r *Router) Describe(method, path string, doc *RouteDoc) {
+	r.routeDocs["["+method+"] "+path] = doc
+}
+
+// SetOpenAPIInfo sets info section of the document
+func SetOpenAPIInfo(info OpenAPIInfo) {
+	openAPIInfo = info
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       OpenAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas,omitempty"`
+	} `json:"components"`
+}
+
+type operation struct {
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*parameter         `json:"parameters,omitempty"`
+	RequestBody *requestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*apiReply `json:"responses"`
+	Codes       map[string]string    `json:"x-codes,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type apiReply struct {
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+}
+
+// schemaBuilder reflects go types into schemas, named structs are put into components
+type schemaBuilder struct {
+	components map[string]*schema
+	names      map[reflect.Type]string
+}
+
+// OpenAPIDocument generates OpenAPI 3 document of the registered routes
+func OpenAPIDocument() ([]byte, error) {
+	return DefaultRouter.OpenAPIDocument()
+}
+
+// OpenAPIDocument generates OpenAPI 3 document of the router routes
+func (r *Router) OpenAPIDocument() ([]byte, error) {
+	doc := &openAPIDocument{OpenAPI: "3.0.3", Info: openAPIInfo, Paths: make(map[string]map[string]*operation)}
+	b := &schemaBuilder{components: make(map[string]*schema), names: make(map[reflect.Type]string)}
+
+	add := func(method, key, apiPath string, params []*parameter) {
+		op := b.operation(method, r.routeDocs["["+method+"] "+key], params)
+
+		if doc.Paths[apiPath] == nil {
+			doc.Paths[apiPath] = make(map[string]*operation)
+		}
+
+		doc.Paths[apiPath][strings.ToLower(method)] = op
+	}
+
+	for p := range r.getRoutes {
+		add(fasthttp.MethodGet, p, p, nil)
+	}
+
+	for p := range r.getSimpleRoutes {
+		add(fasthttp.MethodGet, p, p, nil)
+	}
+
+	for p := range r.postRoutes {
+		add(fasthttp.MethodPost, p, p, nil)
+	}
+
+	for p := range r.postSimpleRoutes {
+		add(fasthttp.MethodPost, p, p, nil)
+	}
+
+	for p := range r.putRoutes {
+		add(fasthttp.MethodPut, p, p, nil)
+	}
+
+	for p := range r.getPrefixRoutes {
+		add(fasthttp.MethodGet, p, p+"{path}", []*parameter{{
+			Name: "path", In: "path", Required: true, Description: "rest of the path", Schema: &schema{Type: "string"},
+		}})
+	}
+
+	regexps := []struct {
+		method string
+		routes map[*regexp.Regexp]RouterFunc
+	}{{fasthttp.MethodGet, r.getRegRoutes}, {fasthttp.MethodPost, r.postRegRoutes}}
+
+	for _, rr := range regexps {
+		for re := range rr.routes {
+			if apiPath, params, ok := regexpToPath(re.String()); ok {
+				add(rr.method, re.String(), apiPath, params)
+			}
+		}
+	}
+
+	doc.Components.Schemas = b.components
+
+	return json.Marshal(doc)
+}
+
+func (b *schemaBuilder) operation(method string, doc *RouteDoc, params []*parameter) *operation {
+	op := &operation{Parameters: params, Responses: make(map[string]*apiReply)}
+
+	if doc == nil {
+		op.Responses["200"] = &apiReply{Description: "OK"}
+		return op
+	}
+
+	op.Summary, op.Description, op.Tags = doc.Summary, doc.Description, doc.Tags
+
+	if doc.Request != nil {
+		if method == fasthttp.MethodGet {
+			op.Parameters = append(op.Parameters, b.queryParameters(reflect.TypeOf(doc.Request))...)
+		} else {
+			s := b.schema(reflect.TypeOf(doc.Request))
+			if doc.Kernel {
+				s = b.envelope(reflect.TypeOf(KernelBaseRequest{}), s)
+			}
+
+			op.RequestBody = &requestBody{Required: true, Content: map[string]*mediaType{ApplicationJSON: {Schema: s}}}
+		}
+	}
+
+	reply := &apiReply{Description: "OK"}
+
+	if doc.Response != nil || !doc.Raw {
+		var s *schema
+		if doc.Response != nil {
+			s = b.schema(reflect.TypeOf(doc.Response))
+		}
+
+		if !doc.Raw {
+			s = b.envelope(reflect.TypeOf(response.BasicResponse{}), s)
+		}
+
+		reply.Content = map[string]*mediaType{ApplicationJSON: {Schema: s}}
+	}
+
+	op.Responses["200"] = reply
+
+	for status, description := range doc.Statuses {
+		op.Responses[strconv.Itoa(status)] = &apiReply{Description: description}
+	}
+
+	if len(doc.Codes) > 0 {
+		op.Codes = make(map[string]string, len(doc.Codes))
+		for code, description := range doc.Codes {
+			op.Codes[strconv.Itoa(code)] = description
+		}
+	}
+
+	return op
+}
+
+// envelope builds schema of wrapper type with Payload field replaced by the payload schema
+func (b *schemaBuilder) envelope(t reflect.Type, payload *schema) *schema {
+	s := b.structSchema(t)
+	if payload != nil {
+		s.Properties["payload"] = payload
+	}
+
+	return s
+}
+
+func (b *schemaBuilder) queryParameters(t reflect.Type) []*parameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var result []*parameter
+
+	s := b.structSchema(t)
+	for _, name := range sortedKeys(s.Properties) {
+		result = append(result, &parameter{Name: name, In: "query", Schema: s.Properties[name]})
+	}
+
+	return result
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) *schema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t, nullable = t.Elem(), true
+	}
+
+	var s *schema
+
+	switch {
+	case t == timeType:
+		s = &schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		s = &schema{}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		return b.ref(t)
+	}
+
+	if s == nil {
+		switch t.Kind() {
+		case reflect.Bool:
+			s = &schema{Type: "boolean"}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+			s = &schema{Type: "integer", Format: "int32"}
+		case reflect.Int64, reflect.Uint64:
+			s = &schema{Type: "integer", Format: "int64"}
+		case reflect.Float32:
+			s = &schema{Type: "number", Format: "float"}
+		case reflect.Float64:
+			s = &schema{Type: "number", Format: "double"}
+		case reflect.String:
+			s = &schema{Type: "string"}
+		case reflect.Slice, reflect.Array:
+			if t.Elem().Kind() == reflect.Uint8 {
+				s = &schema{Type: "string", Format: "byte"}
+			} else {
+				s = &schema{Type: "array", Items: b.schema(t.Elem())}
+			}
+		case reflect.Map:
+			s = &schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+		case reflect.Struct:
+			s = b.structSchema(t)
+		default:
+			s = &schema{}
+		}
+	}
+
+	s.Nullable = nullable
+
+	return s
+}
+
+// ref puts named struct into components and returns reference to it
+func (b *schemaBuilder) ref(t reflect.Type) *schema {
+	name, ok := b.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := b.components[name]; taken {
+			name = path.Base(t.PkgPath()) + "." + name
+		}
+
+		b.names[t] = name
+		b.components[name] = &schema{}
+		*b.components[name] = *b.structSchema(t)
+	}
+
+	return &schema{Ref: "#/components/schemas/" + name}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) *schema {
+	s := &schema{Type: "object", Properties: make(map[string]*schema)}
+	b.addFields(s, t)
+
+	return s
+}
+
+// addFields adds fields the way encoding/json sees them, embedded structs are flattened
+func (b *schemaBuilder) addFields(s *schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if comma := strings.IndexByte(tag, ','); comma >= 0 {
+			name, opts = tag[:comma], tag[comma:]
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			b.addFields(s, ft)
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = b.schema(f.Type)
+
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr && f.Type.Kind() != reflect.Interface {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// regexpToPath converts regexp route like ^/accounts/([0-9]+)/suggest/$ to /accounts/{p1}/suggest/,
+// regexps which are not a sequence of literals and groups are not supported
+func regexpToPath(expr string) (string, []*parameter, bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return "", nil, false
+	}
+
+	parts := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		parts = re.Sub
+	}
+
+	var (
+		result strings.Builder
+		params []*parameter
+	)
+
+	for _, part := range parts {
+		switch part.Op {
+		case syntax.OpBeginLine, syntax.OpBeginText, syntax.OpEndLine, syntax.OpEndText:
+		case syntax.OpLiteral:
+			result.WriteString(string(part.Rune))
+		case syntax.OpCapture:
+			name := part.Name
+			if name == "" {
+				name = "p" + strconv.Itoa(part.Cap)
+			}
+
+			param := &parameter{Name: name, In: "path", Required: true, Description: part.String(), Schema: &schema{Type: "string"}}
+			if digitsOnly(part.Sub[0]) {
+				param.Schema = &schema{Type: "integer"}
+			}
+
+			params = append(params, param)
+			result.WriteString("{" + name + "}")
+		default:
+			return "", nil, false
+		}
+	}
+
+	return result.String(), params, strings.HasPrefix(result.String(), "/")
+}
+
+func digitsOnly(re *syntax.Regexp) bool {
+	if re.Op == syntax.OpPlus || re.Op == syntax.OpStar || re.Op == syntax.OpRepeat {
+		re = re.Sub[0]
+	}
+
+	return re.Op == syntax.OpCharClass && len(re.Rune) == 2 && re.Rune[0] == '0' && re.Rune[1] == '9'
+}
+
+func sortedKeys(m map[string]*schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (r *Router) handlerOpenAPI(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+	r.handlerOpenAPISimple(ctx)
+}
+
+func (r *Router) handlerOpenAPISimple(ctx *fasthttp.RequestCtx) {
+	doc, err := r.OpenAPIDocument()
+	if err != nil {
+		ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	ctx.SetContentType(ApplicationJSONUTF8)
+	ctx.SetBody(doc)
+}
@@ -0,0 +1,126 @@
+package transport
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport/request"
+	"github.com/valyala/fasthttp"
+)
+
+type testAccount struct {
+	ID      int          `json:"id"`
+	Name    string       `json:"name,omitempty"`
+	Created time.Time    `json:"created"`
+	Parent  *testAccount `json:"parent"`
+	secret  string
+}
+
+type testAccountRequest struct {
+	request.BasicRequest
+	Account testAccount `json:"account"`
+}
+
+func TestRegexpToPath(t *testing.T) {
+	cases := map[string]string{
+		`^/accounts/([0-9]+)/suggest/$`: "/accounts/{p1}/suggest/",
+		`/users/(?P<name>[a-z]+)`:       "/users/{name}",
+		`/files/.*`:                     "",
+	}
+
+	for expr, expected := range cases {
+		result, _, ok := regexpToPath(expr)
+		if ok != (expected != "") || result != expected && ok {
+			t.Errorf("%s: expecting %q, got %q %v", expr, expected, result, ok)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	noop := func(*fasthttp.RequestCtx, time.Time, ...string) {}
+
+	AddPostRoute("/test/openapi/account", noop)
+	Describe(fasthttp.MethodPost, "/test/openapi/account", &RouteDoc{
+		Summary:  "Update account",
+		Request:  testAccountRequest{},
+		Response: &testAccount{},
+		Kernel:   true,
+		Codes:    map[int]string{SignatureMismatch: "Signature mismatched"},
+	})
+	AddGetRegexpRoute(`^/test/openapi/accounts/([0-9]+)$`, noop)
+
+	body, err := OpenAPIDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Paths      map[string]map[string]*operation
+		Components struct{ Schemas map[string]*schema }
+	}
+	if err = json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	op := doc.Paths["/test/openapi/account"]["post"]
+	if op == nil || op.Summary != "Update account" || op.Codes["1"] == "" {
+		t.Fatalf("Unexpected operation %+v", op)
+	}
+
+	req := op.RequestBody.Content[ApplicationJSON].Schema
+	if req.Properties["token"] == nil || req.Properties["payload"].Ref != "#/components/schemas/testAccountRequest" {
+		t.Errorf("Expecting KernelBaseRequest envelope, got %+v", req)
+	}
+
+	resp := op.Responses["200"].Content[ApplicationJSON].Schema
+	if resp.Properties["code"] == nil || resp.Properties["payload"].Ref != "#/components/schemas/testAccount" {
+		t.Errorf("Expecting BasicResponse envelope, got %+v", resp)
+	}
+
+	account := doc.Components.Schemas["testAccount"]
+	if account == nil || account.Properties["created"].Format != "date-time" || account.Properties["secret"] != nil ||
+		len(account.Required) != 2 {
+		t.Errorf("Unexpected account schema %+v", account)
+	}
+
+	if fields := doc.Components.Schemas["testAccountRequest"]; fields == nil || fields.Properties["signature"] == nil {
+		t.Errorf("Expecting embedded fields to be flattened, got %+v", fields)
+	}
+
+	if op = doc.Paths["/test/openapi/accounts/{p1}"]["get"]; op == nil || op.Parameters[0].Schema.Type != "integer" {
+		t.Errorf("Expecting regexp route with integer parameter, got %+v", op)
+	}
+}
+
+func TestGroupDescribe(t *testing.T) {
+	noop := func(*fasthttp.RequestCtx, time.Time, ...string) {}
+
+	r := NewRouter()
+	g := r.NewGroup("/test/group")
+	g.AddGetRoute("/items", noop)
+	g.Describe(fasthttp.MethodGet, "/items", &RouteDoc{Summary: "List items"})
+
+	body, err := r.OpenAPIDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]*operation
+	}
+	if err = json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if op := doc.Paths["/test/group/items"]["get"]; op == nil || op.Summary != "List items" {
+		t.Fatalf("Expecting group route described on its router, got %+v", op)
+	}
+
+	var defaultDoc struct {
+		Paths map[string]map[string]*operation
+	}
+	if body, _ = OpenAPIDocument(); json.Unmarshal(body, &defaultDoc) == nil && defaultDoc.Paths["/test/group/items"] != nil {
+		t.Error("Expecting group route not to be described on DefaultRouter")
+	}
+}
@@ -0,0 +1,336 @@
+package proxy
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+)
+
+// Defaults
+const (
+	DefaultTimeout             = 30 * time.Second
+	DefaultRetries             = 2
+	DefaultHealthCheckInterval = 10 * time.Second
+	DefaultHealthCheckTimeout  = 2 * time.Second
+)
+
+// HeaderRequestID request id header passed to upstreams
+const HeaderRequestID = "X-Request-ID"
+
+var (
+	errNoUpstreams = errors.New("proxy: no upstreams")
+
+	// hopHeaders are meaningful only for a single connection
+	hopHeaders = []string{
+		fasthttp.HeaderConnection, "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+		"Proxy-Connection", "Te", "Trailer", fasthttp.HeaderTransferEncoding, "Upgrade",
+	}
+
+	idempotentMethods = map[string]bool{
+		fasthttp.MethodGet: true, fasthttp.MethodHead: true, fasthttp.MethodOptions: true,
+		fasthttp.MethodPut: true, fasthttp.MethodDelete: true, fasthttp.MethodTrace: true,
+	}
+)
+
+// HealthCheck describes active upstream health checks
+type HealthCheck struct {
+	// Path requested with GET, empty disables checks
+	Path     string
+	Interval time.Duration
+	Timeout  time.Duration
+	// Fails consecutive failures marking upstream unhealthy, 2 by default
+	Fails int
+	// Passes consecutive successes marking upstream healthy, 1 by default
+	Passes int
+}
+
+// Config describes proxy
+type Config struct {
+	// Upstreams addresses: host:port, http://host:port or https://host:port
+	Upstreams []string
+	Balance   Balance
+	// StripPrefix is removed from the path before forwarding
+	StripPrefix string
+	// AddPrefix is prepended to the path after StripPrefix
+	AddPrefix string
+	// Rewrite rewrites the path after StripPrefix and AddPrefix
+	Rewrite func(path []byte) []byte
+	// PreserveHost sends original Host header instead of the upstream address
+	PreserveHost bool
+	// SetHeaders and RemoveHeaders are applied to the request sent to upstream
+	SetHeaders    map[string]string
+	RemoveHeaders []string
+	// SetResponseHeaders and RemoveResponseHeaders are applied to the response sent to client
+	SetResponseHeaders    map[string]string
+	RemoveResponseHeaders []string
+	// Timeout of a single upstream request
+	Timeout time.Duration
+	// Retries of idempotent requests on other upstreams after connection errors and 502-504,
+	// negative disables them
+	Retries     int
+	MaxConns    int
+	HealthCheck HealthCheck
+}
+
+// Proxy forwards requests to upstreams
+type Proxy struct {
+	config    Config
+	upstreams []*Upstream
+	next      uint32
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// New creates proxy, upstream stats are shown by /internal/stats as "[PROXY name] addr"
+func New(name string, config Config) (*Proxy, error) {
+	return NewOn(transport.DefaultRouter, name, config)
+}
+
+// NewOn creates proxy with upstream stats added to router
+func NewOn(router *transport.Router, name string, config Config) (*Proxy, error) {
+	if len(config.Upstreams) == 0 {
+		return nil, errNoUpstreams
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	if config.Retries == 0 {
+		config.Retries = DefaultRetries
+	}
+
+	hc := &config.HealthCheck
+	if hc.Interval <= 0 {
+		hc.Interval = DefaultHealthCheckInterval
+	}
+
+	if hc.Timeout <= 0 {
+		hc.Timeout = DefaultHealthCheckTimeout
+	}
+
+	if hc.Fails <= 0 {
+		hc.Fails = 2
+	}
+
+	if hc.Passes <= 0 {
+		hc.Passes = 1
+	}
+
+	p := &Proxy{config: config, stop: make(chan struct{})}
+
+	for _, addr := range config.Upstreams {
+		u, err := newUpstream(addr, &p.config)
+		if err != nil {
+			return nil, err
+		}
+
+		p.upstreams = append(p.upstreams, u)
+		router.AddStats("[PROXY "+name+"] "+u.Addr, u)
+	}
+
+	if hc.Path != "" {
+		go p.healthChecks()
+	}
+
+	return p, nil
+}
+
+// Register creates proxy serving all methods of pathes starting with prefix
+func Register(prefix string, config Config, middlewares ...transport.Middleware) (*Proxy, error) {
+	return RegisterOn(transport.DefaultRouter, prefix, config, middlewares...)
+}
+
+// RegisterOn creates proxy serving all methods of pathes starting with prefix of router
+func RegisterOn(router *transport.Router, prefix string, config Config, middlewares ...transport.Middleware) (*Proxy, error) {
+	p, err := NewOn(router, prefix, config)
+	if err != nil {
+		return nil, err
+	}
+
+	router.AddPrefixRoute(prefix, transport.Chain(p.Handler(), middlewares...))
+
+	return p, nil
+}
+
+// Upstreams returns upstreams of the proxy
+func (p *Proxy) Upstreams() []*Upstream {
+	return p.upstreams
+}
+
+// Close stops health checks
+func (p *Proxy) Close() {
+	p.closeOnce.Do(func() { close(p.stop) })
+}
+
+func (p *Proxy) healthChecks() {
+	ticker := time.NewTicker(p.config.HealthCheck.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			for _, u := range p.upstreams {
+				go u.check(&p.config.HealthCheck)
+			}
+		}
+	}
+}
+
+// Handler returns handler forwarding requests
+func (p *Proxy) Handler() transport.RouterFunc {
+	return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		p.Serve(ctx)
+	}
+}
+
+// Serve forwards request to upstream and copies its response
+func (p *Proxy) Serve(ctx *fasthttp.RequestCtx) {
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	p.prepareRequest(ctx, req)
+
+	attempts := 1
+	if idempotentMethods[string(ctx.Method())] && p.config.Retries > 0 {
+		attempts += p.config.Retries
+	}
+
+	var (
+		tried []*Upstream
+		err   error
+	)
+
+	for i := 0; i < attempts; i++ {
+		u := p.pick(tried)
+		if u == nil {
+			break
+		}
+
+		tried = append(tried, u)
+
+		if !p.config.PreserveHost {
+			req.SetHost(u.Addr)
+		}
+
+		resp.Reset()
+		start := time.Now()
+
+		atomic.AddInt64(&u.active, 1)
+		err = u.client.DoTimeout(req, resp, p.config.Timeout)
+		atomic.AddInt64(&u.active, -1)
+
+		failed := err != nil || resp.StatusCode() >= fasthttp.StatusBadGateway && resp.StatusCode() <= fasthttp.StatusGatewayTimeout
+		u.update(time.Since(start), failed, i > 0)
+
+		if !failed {
+			break
+		}
+	}
+
+	switch {
+	case err == fasthttp.ErrTimeout:
+		ctx.Error("Gateway Timeout", fasthttp.StatusGatewayTimeout)
+	case err != nil || len(tried) == 0:
+		ctx.Error("Bad Gateway", fasthttp.StatusBadGateway)
+	default:
+		p.copyResponse(ctx, resp)
+	}
+}
+
+func (p *Proxy) prepareRequest(ctx *fasthttp.RequestCtx, req *fasthttp.Request) {
+	ctx.Request.CopyTo(req)
+
+	path := ctx.Path()
+	if p.config.StripPrefix != "" && len(path) >= len(p.config.StripPrefix) &&
+		string(path[:len(p.config.StripPrefix)]) == p.config.StripPrefix {
+		path = path[len(p.config.StripPrefix):]
+	}
+
+	if p.config.AddPrefix != "" {
+		path = append([]byte(p.config.AddPrefix), path...)
+	}
+
+	if p.config.Rewrite != nil {
+		path = p.config.Rewrite(path)
+	}
+
+	if len(path) == 0 || path[0] != '/' {
+		path = append([]byte{'/'}, path...)
+	}
+
+	req.URI().SetPathBytes(path)
+
+	h := &req.Header
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+
+	for _, name := range p.config.RemoveHeaders {
+		h.Del(name)
+	}
+
+	for name, value := range p.config.SetHeaders {
+		h.Set(name, value)
+	}
+
+	clientIP := ctx.RemoteIP().String()
+	if prior := h.Peek("X-Forwarded-For"); len(prior) > 0 {
+		clientIP = string(prior) + ", " + clientIP
+	}
+
+	h.Set("X-Forwarded-For", clientIP)
+	h.Set("X-Forwarded-Host", string(ctx.Host()))
+
+	if ctx.IsTLS() {
+		h.Set("X-Forwarded-Proto", "https")
+	} else {
+		h.Set("X-Forwarded-Proto", "http")
+	}
+
+	if len(h.Peek(HeaderRequestID)) == 0 {
+		h.Set(HeaderRequestID, strconv.FormatUint(ctx.ID(), 10))
+	}
+}
+
+// copyResponse copies upstream response keeping headers set by the middlewares of the route
+func (p *Proxy) copyResponse(ctx *fasthttp.RequestCtx, resp *fasthttp.Response) {
+	ctx.SetStatusCode(resp.StatusCode())
+
+	seen := make(map[string]bool)
+
+	resp.Header.VisitAll(func(k, v []byte) {
+		name := string(k)
+
+		switch {
+		case name == fasthttp.HeaderContentLength || name == fasthttp.HeaderConnection:
+		case name == fasthttp.HeaderSetCookie || seen[name]:
+			ctx.Response.Header.Add(name, string(v))
+		default:
+			ctx.Response.Header.Set(name, string(v))
+			seen[name] = true
+		}
+	})
+
+	for _, name := range hopHeaders {
+		ctx.Response.Header.Del(name)
+	}
+
+	for _, name := range p.config.RemoveResponseHeaders {
+		ctx.Response.Header.Del(name)
+	}
+
+	for name, value := range p.config.SetResponseHeaders {
+		ctx.Response.Header.Set(name, value)
+	}
+
+	ctx.Response.SetBody(resp.Body())
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/finnan444/utils/transport/transporttest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestProxy(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no loopback: %v", err)
+	}
+	defer ln.Close()
+
+	go fasthttp.Serve(ln, func(ctx *fasthttp.RequestCtx) {
+		ctx.Response.Header.Set("X-Internal", "1")
+		ctx.Response.Header.Set("X-Upstream", "1")
+		ctx.SetContentType("text/csv")
+		ctx.SetBodyString(string(ctx.Method()) + " " + string(ctx.RequestURI()) + " " +
+			string(ctx.Request.Header.Peek("X-Forwarded-For")) + " " + string(ctx.Request.Header.Peek("X-Api-Key")) + " " +
+			string(ctx.Request.Header.Peek(HeaderRequestID)))
+	})
+
+	// nothing listens there
+	down, _ := net.Listen("tcp4", "127.0.0.1:0")
+	downAddr := down.Addr().String()
+	down.Close()
+
+	p, err := New("test", Config{
+		Upstreams:             []string{"http://" + downAddr, ln.Addr().String()},
+		StripPrefix:           "/api",
+		AddPrefix:             "/v2",
+		SetHeaders:            map[string]string{"X-Api-Key": "k"},
+		RemoveHeaders:         []string{"Authorization"},
+		RemoveResponseHeaders: []string{"X-Internal"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	srv := transporttest.NewServer(t, nil)
+	defer srv.Close()
+	srv.AddPrefixRoute("/api/", transport.Chain(p.Handler(), func(next transport.RouterFunc) transport.RouterFunc {
+		return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			ctx.Response.Header.Set("X-Outer", "1")
+			next(ctx, now, adds...)
+		}
+	}))
+
+	for i := 0; i < 2; i++ {
+		resp := srv.Get("/api/items").Query("a", "1").Header("Authorization", "secret").Do().
+			AssertStatus(fasthttp.StatusOK).
+			AssertHeader("X-Internal", "").
+			AssertHeader("X-Upstream", "1").
+			AssertHeader("X-Outer", "1").
+			AssertHeader(fasthttp.HeaderContentType, "text/csv")
+
+		body := string(resp.Body())
+		if !strings.HasPrefix(body, "GET /v2/items?a=1 ") || !strings.Contains(body, " k ") || strings.Contains(body, "secret") {
+			t.Errorf("Unexpected upstream request %q", body)
+		}
+	}
+
+	srv.Request(fasthttp.MethodDelete, "/api/items/1").Do().AssertStatus(fasthttp.StatusOK)
+
+	failed := p.Upstreams()[0]
+	if failed.errors == 0 || !strings.Contains(p.Upstreams()[1].String(), "\"retries\":") {
+		t.Errorf("Expecting failures of the down upstream, got %s", failed)
+	}
+
+	// POST is not retried
+	p.config.Retries = -1
+	statuses := map[int]int{}
+	for i := 0; i < 2; i++ {
+		statuses[srv.Post("/api/items").Body([]byte("{}"), "application/json").Do().StatusCode()]++
+	}
+
+	if statuses[fasthttp.StatusOK] != 1 || statuses[fasthttp.StatusBadGateway] != 1 {
+		t.Errorf("Expecting one success and one bad gateway, got %v", statuses)
+	}
+
+	p.Close()
+}
+
+func TestHealthCheck(t *testing.T) {
+	p := &Proxy{config: Config{Balance: LeastConn}}
+	a, _ := newUpstream("127.0.0.1:1", &p.config)
+	b, _ := newUpstream("127.0.0.1:2", &p.config)
+	p.upstreams = []*Upstream{a, b}
+
+	a.active = 3
+	if u := p.pick(nil); u != b {
+		t.Errorf("Expecting least loaded upstream, got %s", u.Addr)
+	}
+
+	hc := &HealthCheck{Path: "/health", Timeout: 100 * time.Millisecond, Fails: 2, Passes: 1}
+	b.check(hc)
+	if !b.Healthy() {
+		t.Error("Expecting upstream to be healthy after single failure")
+	}
+
+	b.check(hc)
+	if b.Healthy() {
+		t.Error("Expecting upstream to be unhealthy after two failures")
+	}
+
+	if u := p.pick(nil); u != a {
+		t.Errorf("Expecting healthy upstream, got %s", u.Addr)
+	}
+
+	if u := p.pick([]*Upstream{a}); u != b {
+		t.Error("Expecting unhealthy upstream to be tried when there is no other")
+	}
+}
@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Balance upstream selection strategy
+type Balance int
+
+// Balances
+const (
+	RoundRobin Balance = iota
+	LeastConn
+)
+
+// Upstream is a backend server of the proxy
+type Upstream struct {
+	Addr   string
+	client *fasthttp.HostClient
+
+	healthy int32
+	active  int64
+
+	sync.Mutex
+	requests, errors, retries int64
+	total, max                time.Duration
+	passes, fails             int
+}
+
+func newUpstream(addr string, config *Config) (*Upstream, error) {
+	host, isTLS := addr, false
+
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		switch u.Scheme {
+		case "http":
+		case "https":
+			isTLS = true
+		default:
+			return nil, fmt.Errorf("proxy: unsupported upstream scheme %s", u.Scheme)
+		}
+
+		host = u.Host
+	}
+
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		if isTLS {
+			host += ":443"
+		} else {
+			host += ":80"
+		}
+	}
+
+	return &Upstream{
+		Addr:    host,
+		healthy: 1,
+		client: &fasthttp.HostClient{
+			Addr:                      host,
+			IsTLS:                     isTLS,
+			MaxConns:                  config.MaxConns,
+			ReadTimeout:               config.Timeout,
+			WriteTimeout:              config.Timeout,
+			MaxIdemponentCallAttempts: 1,
+		},
+	}, nil
+}
+
+// Healthy reports whether upstream passed the last health checks
+func (u *Upstream) Healthy() bool {
+	return atomic.LoadInt32(&u.healthy) == 1
+}
+
+// Active returns number of requests in progress
+func (u *Upstream) Active() int64 {
+	return atomic.LoadInt64(&u.active)
+}
+
+func (u *Upstream) update(d time.Duration, failed, retry bool) {
+	u.Lock()
+
+	u.requests++
+	u.total += d
+
+	if d > u.max {
+		u.max = d
+	}
+
+	if failed {
+		u.errors++
+	}
+
+	if retry {
+		u.retries++
+	}
+
+	u.Unlock()
+}
+
+// String is shown by /internal/stats
+func (u *Upstream) String() string {
+	u.Lock()
+	defer u.Unlock()
+
+	var med time.Duration
+	if u.requests > 0 {
+		med = u.total / time.Duration(u.requests)
+	}
+
+	return fmt.Sprintf("{\"healthy\":%v, \"active\":%d, \"requests\":%d, \"errors\":%d, \"retries\":%d, \"max\":%v, \"med\":%v}",
+		u.Healthy(), u.Active(), u.requests, u.errors, u.retries, u.max, med)
+}
+
+// check runs single health check and updates upstream state using thresholds
+func (u *Upstream) check(hc *HealthCheck) {
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(hc.Path)
+	req.SetHost(u.Addr)
+
+	err := u.client.DoTimeout(req, resp, hc.Timeout)
+	ok := err == nil && resp.StatusCode() < fasthttp.StatusBadRequest
+
+	u.Lock()
+	defer u.Unlock()
+
+	if ok {
+		u.passes, u.fails = u.passes+1, 0
+		if u.passes >= hc.Passes {
+			atomic.StoreInt32(&u.healthy, 1)
+		}
+	} else {
+		u.passes, u.fails = 0, u.fails+1
+		if u.fails >= hc.Fails {
+			atomic.StoreInt32(&u.healthy, 0)
+		}
+	}
+}
+
+func excluded(u *Upstream, exclude []*Upstream) bool {
+	for _, e := range exclude {
+		if e == u {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pick selects upstream which is not excluded, healthy ones are preferred.
+// If all of them are unhealthy the request is still tried
+func (p *Proxy) pick(exclude []*Upstream) *Upstream {
+	if u := p.pickHealthy(exclude, true); u != nil {
+		return u
+	}
+
+	return p.pickHealthy(exclude, false)
+}
+
+func (p *Proxy) pickHealthy(exclude []*Upstream, healthyOnly bool) *Upstream {
+	var (
+		n      = len(p.upstreams)
+		start  = int(atomic.AddUint32(&p.next, 1))
+		result *Upstream
+	)
+
+	for i := 0; i < n; i++ {
+		u := p.upstreams[(start+i)%n]
+		if (healthyOnly && !u.Healthy()) || excluded(u, exclude) {
+			continue
+		}
+
+		if p.config.Balance == RoundRobin {
+			return u
+		}
+
+		if result == nil || u.Active() < result.Active() {
+			result = u
+		}
+	}
+
+	return result
+}
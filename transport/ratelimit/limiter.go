@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Result of the limiter decision
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time left until the limit is fully restored
+	Reset time.Duration
+	// RetryAfter is the time left until the next request may be allowed
+	RetryAfter time.Duration
+}
+
+// Limiter decides whether request identified by key is allowed
+type Limiter interface {
+	Allow(key string, now time.Time) Result
+}
+
+// TokenBucket allows bursts up to Burst requests refilled at Limit requests per Period
+type TokenBucket struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+	store  Store
+}
+
+// NewTokenBucket creates token bucket limiter, if burst is less than 1 limit is used.
+// It panics if limit or period is not positive
+func NewTokenBucket(limit int, period time.Duration, burst int, store Store) *TokenBucket {
+	if limit <= 0 || period <= 0 {
+		panic("ratelimit: limit and period of token bucket must be positive")
+	}
+
+	if burst < 1 {
+		burst = limit
+	}
+
+	return &TokenBucket{Limit: limit, Period: period, Burst: burst, store: store}
+}
+
+// Allow impl
+func (tb *TokenBucket) Allow(key string, now time.Time) (result Result) {
+	rate := float64(tb.Limit) / tb.Period.Seconds()
+	capacity := float64(tb.Burst)
+	ttl := time.Duration(capacity / rate * float64(time.Second))
+
+	tb.store.Update(key, ttl, func(state *State) {
+		if state.Last.IsZero() {
+			state.Tokens = capacity
+		} else if elapsed := now.Sub(state.Last).Seconds(); elapsed > 0 {
+			state.Tokens = math.Min(capacity, state.Tokens+elapsed*rate)
+		}
+
+		if now.After(state.Last) {
+			state.Last = now
+		}
+
+		result.Limit = tb.Burst
+
+		if state.Tokens >= 1 {
+			state.Tokens--
+			result.Allowed = true
+		} else {
+			result.RetryAfter = secondsToDuration((1 - state.Tokens) / rate)
+		}
+
+		result.Remaining = int(state.Tokens)
+		result.Reset = secondsToDuration((capacity - state.Tokens) / rate)
+	})
+
+	return result
+}
+
+// SlidingWindow allows Limit requests per Window,
+// previous window is counted proportionally to its overlap with the sliding one
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+	store  Store
+}
+
+// NewSlidingWindow creates sliding window limiter
+func NewSlidingWindow(limit int, window time.Duration, store Store) *SlidingWindow {
+	return &SlidingWindow{Limit: limit, Window: window, store: store}
+}
+
+// Allow impl
+func (sw *SlidingWindow) Allow(key string, now time.Time) (result Result) {
+	sw.store.Update(key, 2*sw.Window, func(state *State) {
+		start := now.Truncate(sw.Window)
+
+		switch {
+		case state.Last.Equal(start):
+		case state.Last.Add(sw.Window).Equal(start):
+			state.PrevCount, state.Count, state.Last = state.Count, 0, start
+		case state.Last.Before(start):
+			state.PrevCount, state.Count, state.Last = 0, 0, start
+		}
+
+		elapsed := now.Sub(state.Last)
+		weight := 1 - float64(elapsed)/float64(sw.Window)
+		estimated := float64(state.PrevCount)*weight + float64(state.Count)
+
+		result.Limit = sw.Limit
+		result.Reset = sw.Window - elapsed
+
+		if estimated+1 <= float64(sw.Limit) {
+			state.Count++
+			estimated++
+			result.Allowed = true
+		} else if free := float64(sw.Limit - 1 - state.Count); free >= 0 && state.PrevCount > 0 {
+			// wait until the previous window weight drops enough
+			wait := (1-free/float64(state.PrevCount))*float64(sw.Window) - float64(elapsed)
+			result.RetryAfter = time.Duration(math.Max(wait, 0))
+		} else {
+			result.RetryAfter = sw.Window - elapsed
+		}
+
+		if result.Remaining = sw.Limit - int(math.Ceil(estimated)); result.Remaining < 0 {
+			result.Remaining = 0
+		}
+
+		// requests of the current window are counted until the end of the next one
+		if state.Count > 0 {
+			result.Reset += sw.Window
+		}
+	})
+
+	return result
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
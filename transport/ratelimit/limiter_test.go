@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	limiter := NewTokenBucket(2, time.Second, 4, NewMemoryStore(0))
+	start := time.Unix(1000, 0)
+
+	for i := 0; i < 4; i++ {
+		if res := limiter.Allow("a", start); !res.Allowed || res.Remaining != 3-i {
+			t.Errorf("request %d: expecting allowed with %d remaining, got %+v", i, 3-i, res)
+		}
+	}
+
+	res := limiter.Allow("a", start)
+	if res.Allowed {
+		t.Errorf("Expecting burst to be exhausted, got %+v", res)
+	}
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Expecting retry after 500ms, got %v", res.RetryAfter)
+	}
+
+	if res = limiter.Allow("b", start); !res.Allowed {
+		t.Errorf("Expecting other key to be allowed, got %+v", res)
+	}
+
+	if res = limiter.Allow("a", start.Add(500*time.Millisecond)); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("Expecting one refilled token, got %+v", res)
+	}
+
+	if res = limiter.Allow("a", start.Add(10*time.Second)); !res.Allowed || res.Remaining != 3 {
+		t.Errorf("Expecting bucket to be full, got %+v", res)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		prev      int
+		cur       int
+		offset    time.Duration
+		allowed   bool
+		remaining int
+	}{
+		{name: "empty", offset: 0, allowed: true, remaining: 9},
+		{name: "current window full", cur: 10, offset: 30 * time.Second, allowed: false, remaining: 0},
+		{name: "previous window half counted", prev: 10, cur: 4, offset: 30 * time.Second, allowed: true, remaining: 0},
+		{name: "previous window too heavy", prev: 10, cur: 5, offset: 30 * time.Second, allowed: false, remaining: 0},
+		{name: "previous window almost gone", prev: 10, cur: 5, offset: 54 * time.Second, allowed: true, remaining: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore(0)
+			limiter := NewSlidingWindow(10, time.Minute, store)
+			start := time.Unix(6000, 0)
+
+			store.Update("k", time.Hour, func(state *State) {
+				state.Last, state.Count, state.PrevCount = start, tt.cur, tt.prev
+			})
+
+			res := limiter.Allow("k", start.Add(tt.offset))
+			if res.Allowed != tt.allowed || res.Remaining != tt.remaining {
+				t.Errorf("Allow() = %+v, want allowed %v remaining %d", res, tt.allowed, tt.remaining)
+			}
+			if !res.Allowed && res.RetryAfter <= 0 {
+				t.Errorf("Expecting positive retry after, got %v", res.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestTokenBucketInvalidConfig(t *testing.T) {
+	for _, c := range []struct {
+		limit  int
+		period time.Duration
+	}{{0, time.Second}, {-1, time.Second}, {1, 0}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expecting panic for limit %d per %v", c.limit, c.period)
+				}
+			}()
+
+			NewTokenBucket(c.limit, c.period, 0, NewMemoryStore(0))
+		}()
+	}
+
+	if tb := NewTokenBucket(3, time.Second, 0, NewMemoryStore(0)); tb.Burst != 3 {
+		t.Errorf("Expecting burst to default to limit, got %d", tb.Burst)
+	}
+}
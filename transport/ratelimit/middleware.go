@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+)
+
+// Rate limit headers
+const (
+	HeaderLimit     = "X-RateLimit-Limit"
+	HeaderRemaining = "X-RateLimit-Remaining"
+	HeaderReset     = "X-RateLimit-Reset"
+)
+
+// KeyFunc extracts client key from request, empty key disables limiting for the request
+type KeyFunc func(ctx *fasthttp.RequestCtx) string
+
+// KeyByIP uses client ip as a key
+func KeyByIP(ctx *fasthttp.RequestCtx) string {
+	return ctx.RemoteIP().String()
+}
+
+// KeyByHeader uses header value as a key
+func KeyByHeader(name string) KeyFunc {
+	return func(ctx *fasthttp.RequestCtx) string {
+		return string(ctx.Request.Header.Peek(name))
+	}
+}
+
+// KeyByToken uses token of transport.KernelBaseRequest body as a key, falls back to client ip
+func KeyByToken(ctx *fasthttp.RequestCtx) string {
+	var req struct {
+		Token string `json:"token"`
+	}
+
+	if err := json.Unmarshal(ctx.PostBody(), &req); err == nil && req.Token != "" {
+		return "token:" + req.Token
+	}
+
+	return KeyByIP(ctx)
+}
+
+// Middleware limits requests by key.
+// Routes wrapped with the same name share limits, so one middleware
+// can be used for a whole transport.Group
+func Middleware(name string, limiter Limiter, key KeyFunc) transport.Middleware {
+	return func(next transport.RouterFunc) transport.RouterFunc {
+		return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			k := key(ctx)
+			if k == "" {
+				next(ctx, now, adds...)
+				return
+			}
+
+			result := limiter.Allow(name+":"+k, now)
+			if !result.Allowed {
+				// Error resets response, so headers are set after it
+				ctx.Error("Too many requests", fasthttp.StatusTooManyRequests)
+				ctx.Response.Header.Set(fasthttp.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			}
+
+			ctx.Response.Header.Set(HeaderLimit, strconv.Itoa(result.Limit))
+			ctx.Response.Header.Set(HeaderRemaining, strconv.Itoa(result.Remaining))
+			ctx.Response.Header.Set(HeaderReset, strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if result.Allowed {
+				next(ctx, now, adds...)
+			}
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/finnan444/utils/time/cron"
+)
+
+const shardsCount = 64
+
+// State is a limiter state of a single key
+type State struct {
+	// Tokens left in token bucket
+	Tokens float64
+	// Last is the time of the last refill for token bucket or
+	// the start of the current window for sliding window
+	Last time.Time
+	// Count of requests in the current window
+	Count int
+	// PrevCount of requests in the previous window
+	PrevCount int
+}
+
+// Store keeps limiter states by key
+type Store interface {
+	// Update atomically modifies state of the key.
+	// New keys start with zero State, state is kept at least ttl after the last update
+	Update(key string, ttl time.Duration, fn func(state *State))
+}
+
+type memoryEntry struct {
+	state   State
+	expires time.Time
+}
+
+type memoryShard struct {
+	sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+// MemoryStore in-memory sharded store
+type MemoryStore struct {
+	shards [shardsCount]memoryShard
+}
+
+// NewMemoryStore creates memory store, expired keys are removed every cleanupPeriod
+func NewMemoryStore(cleanupPeriod time.Duration) *MemoryStore {
+	result := &MemoryStore{}
+	for i := range result.shards {
+		result.shards[i].entries = make(map[string]*memoryEntry)
+	}
+
+	if cleanupPeriod > 0 {
+		cron.Add(cleanupPeriod, time.UTC, result.cleanup)
+	}
+
+	return result
+}
+
+func (s *MemoryStore) shard(key string) *memoryShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return &s.shards[h.Sum32()%shardsCount]
+}
+
+// Update impl
+func (s *MemoryStore) Update(key string, ttl time.Duration, fn func(state *State)) {
+	sh := s.shard(key)
+	now := time.Now()
+
+	sh.Lock()
+
+	entry, ok := sh.entries[key]
+	if !ok || entry.expires.Before(now) {
+		entry = &memoryEntry{}
+		sh.entries[key] = entry
+	}
+
+	fn(&entry.state)
+	entry.expires = now.Add(ttl)
+	sh.Unlock()
+}
+
+// Len returns number of stored keys
+func (s *MemoryStore) Len() (result int) {
+	for i := range s.shards {
+		s.shards[i].Lock()
+		result += len(s.shards[i].entries)
+		s.shards[i].Unlock()
+	}
+
+	return result
+}
+
+func (s *MemoryStore) cleanup() {
+	now := time.Now()
+
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.Lock()
+
+		for k, v := range sh.entries {
+			if v.expires.Before(now) {
+				delete(sh.entries, k)
+			}
+		}
+
+		sh.Unlock()
+	}
+}
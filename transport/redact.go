@@ -0,0 +1,314 @@
+package transport
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+// RedactMask replaces sensitive values
+const RedactMask = "***"
+
+// Redactor masks sensitive values in logged bodies.
+// Pattern is a dot separated json path matched against the end of the value path,
+// so "token" matches token key at any depth and "*.phone" matches phone key of any nested object.
+// Patterns starting with "$." are matched from the root. Every segment is a path.Match
+// pattern compared case insensitively, array indexes are not part of the path
+type Redactor struct {
+	rules [][]string
+	roots []bool
+}
+
+var (
+	// DefaultRedactor masks credentials and phone numbers
+	DefaultRedactor = NewRedactor("token", "password", "signature", "*.phone")
+	redactor        = DefaultRedactor
+)
+
+// NewRedactor creates redactor with the given patterns
+func NewRedactor(patterns ...string) *Redactor {
+	result := &Redactor{}
+
+	for _, p := range patterns {
+		root := strings.HasPrefix(p, "$.")
+		if root {
+			p = p[2:]
+		}
+
+		result.rules = append(result.rules, strings.Split(strings.ToLower(p), "."))
+		result.roots = append(result.roots, root)
+	}
+
+	return result
+}
+
+// SetRedactor sets redactor applied to all logged bodies, nil disables redaction
+func SetRedactor(r *Redactor) {
+	redactor = r
+}
+
+// RedactBody masks sensitive values of the body with the redactor set by SetRedactor
+func RedactBody(body []byte) []byte {
+	if redactor == nil {
+		return body
+	}
+
+	return redactor.Redact(body)
+}
+
+// RedactValue masks sensitive values of the decoded json with the redactor set by SetRedactor
+func RedactValue(value interface{}) interface{} {
+	if redactor == nil {
+		return value
+	}
+
+	return redactor.RedactValue(value)
+}
+
+// Match checks whether value with the given path must be masked
+func (r *Redactor) Match(keys []string) bool {
+	for i, rule := range r.rules {
+		if len(keys) < len(rule) || (r.roots[i] && len(keys) != len(rule)) {
+			continue
+		}
+
+		matched := true
+		tail := keys[len(keys)-len(rule):]
+
+		for j, segment := range rule {
+			if ok, _ := path.Match(segment, strings.ToLower(tail[j])); !ok {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RedactValue returns copy of the decoded json (maps, slices and scalars) with masked values
+func (r *Redactor) RedactValue(value interface{}) interface{} {
+	return r.redactValue(value, nil)
+}
+
+func (r *Redactor) redactValue(value interface{}, keys []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			if itemKeys := append(keys, k); r.Match(itemKeys) {
+				result[k] = RedactMask
+			} else {
+				result[k] = r.redactValue(item, itemKeys)
+			}
+		}
+
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = r.redactValue(item, keys)
+		}
+
+		return result
+	}
+
+	return value
+}
+
+// Redact masks sensitive values of json body. Body may be truncated, in this case
+// value cut off in the middle is masked as well. Bodies which are not json
+// objects or arrays are treated as url encoded query strings
+func (r *Redactor) Redact(body []byte) []byte {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) == 0 {
+		return body
+	}
+
+	if trimmed[0] != '{' && trimmed[0] != '[' {
+		return r.redactQuery(body)
+	}
+
+	s := redactScanner{r: r, in: body, out: make([]byte, 0, len(body))}
+	s.scan()
+
+	return s.out
+}
+
+func (r *Redactor) redactQuery(body []byte) []byte {
+	var (
+		out     = make([]byte, 0, len(body))
+		changed bool
+	)
+
+	for i, pair := range bytes.Split(body, []byte{'&'}) {
+		if i > 0 {
+			out = append(out, '&')
+		}
+
+		if eq := bytes.IndexByte(pair, '='); eq > 0 && r.Match([]string{string(pair[:eq])}) {
+			out = append(out, pair[:eq+1]...)
+			out = append(out, RedactMask...)
+			changed = true
+		} else {
+			out = append(out, pair...)
+		}
+	}
+
+	if !changed {
+		return body
+	}
+
+	return out
+}
+
+var scalarDelimiters = []byte(",}] \t\r\n")
+
+type redactFrame struct {
+	object bool
+	key    string
+}
+
+type redactScanner struct {
+	r         *Redactor
+	in, out   []byte
+	pos       int
+	stack     []redactFrame
+	expectKey bool
+}
+
+func (s *redactScanner) keys() []string {
+	result := make([]string, 0, len(s.stack))
+
+	for _, f := range s.stack {
+		if f.object {
+			result = append(result, f.key)
+		}
+	}
+
+	return result
+}
+
+// valueMasked reports whether value at the current position must be masked
+func (s *redactScanner) valueMasked() bool {
+	if len(s.stack) == 0 || !s.stack[len(s.stack)-1].object {
+		return false
+	}
+
+	return s.r.Match(s.keys())
+}
+
+func (s *redactScanner) scan() {
+	for s.pos < len(s.in) {
+		c := s.in[s.pos]
+
+		switch c {
+		case '{', '[':
+			if !s.expectKey && s.valueMasked() {
+				s.skipContainer()
+				s.out = append(s.out, '"')
+				s.out = append(s.out, RedactMask...)
+				s.out = append(s.out, '"')
+
+				continue
+			}
+
+			s.stack = append(s.stack, redactFrame{object: c == '{'})
+			s.expectKey = c == '{'
+			s.out = append(s.out, c)
+			s.pos++
+		case '}', ']':
+			if len(s.stack) > 0 {
+				s.stack = s.stack[:len(s.stack)-1]
+			}
+
+			s.expectKey = false
+			s.out = append(s.out, c)
+			s.pos++
+		case ',':
+			s.expectKey = len(s.stack) > 0 && s.stack[len(s.stack)-1].object
+			s.out = append(s.out, c)
+			s.pos++
+		case ':', ' ', '\t', '\r', '\n':
+			s.out = append(s.out, c)
+			s.pos++
+		case '"':
+			start := s.pos
+			s.skipString()
+
+			if s.expectKey && len(s.stack) > 0 {
+				s.stack[len(s.stack)-1].key = unquoteKey(s.in[start:s.pos])
+				s.expectKey = false
+				s.out = append(s.out, s.in[start:s.pos]...)
+			} else if s.valueMasked() {
+				s.out = append(s.out, '"')
+				s.out = append(s.out, RedactMask...)
+				s.out = append(s.out, '"')
+			} else {
+				s.out = append(s.out, s.in[start:s.pos]...)
+			}
+		default:
+			start := s.pos
+			for s.pos < len(s.in) && bytes.IndexByte(scalarDelimiters, s.in[s.pos]) < 0 {
+				s.pos++
+			}
+
+			if s.valueMasked() {
+				s.out = append(s.out, '"')
+				s.out = append(s.out, RedactMask...)
+				s.out = append(s.out, '"')
+			} else {
+				s.out = append(s.out, s.in[start:s.pos]...)
+			}
+		}
+	}
+}
+
+// skipString moves position after the string starting at the current position
+func (s *redactScanner) skipString() {
+	for s.pos++; s.pos < len(s.in); s.pos++ {
+		switch s.in[s.pos] {
+		case '\\':
+			s.pos++
+		case '"':
+			s.pos++
+			return
+		}
+	}
+
+	s.pos = len(s.in)
+}
+
+// skipContainer moves position after the object or array starting at the current position
+func (s *redactScanner) skipContainer() {
+	depth := 0
+
+	for s.pos < len(s.in) {
+		switch s.in[s.pos] {
+		case '"':
+			s.skipString()
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		}
+
+		s.pos++
+
+		if depth == 0 {
+			return
+		}
+	}
+}
+
+func unquoteKey(raw []byte) string {
+	raw = bytes.TrimPrefix(raw, []byte{'"'})
+	raw = bytes.TrimSuffix(raw, []byte{'"'})
+
+	return string(raw)
+}
@@ -0,0 +1,82 @@
+package transport
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactorRedact(t *testing.T) {
+	r := NewRedactor("token", "*.phone", "$.payload.secret", "*_key")
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "top level token",
+			body: `{"token":"abc","payload":{"id":1}}`,
+			want: `{"token":"***","payload":{"id":1}}`,
+		},
+		{
+			name: "nested phone, root phone kept",
+			body: `{"phone":"1","user":{"phone":79001234567,"name":"a"}}`,
+			want: `{"phone":"1","user":{"phone":"***","name":"a"}}`,
+		},
+		{
+			name: "rooted path",
+			body: `{"payload":{"secret":"s","a":{"secret":"x"}}}`,
+			want: `{"payload":{"secret":"***","a":{"secret":"x"}}}`,
+		},
+		{
+			name: "object value and arrays",
+			body: `[{"token":{"a":"}"},"list":[{"api_key":[1,2]}, 3]}]`,
+			want: `[{"token":"***","list":[{"api_key":"***"}, 3]}]`,
+		},
+		{
+			name: "escaped quotes",
+			body: `{"name":"a\"token","token":"x\"y"}`,
+			want: `{"name":"a\"token","token":"***"}`,
+		},
+		{
+			name: "truncated value",
+			body: `{"id":5,"token":"abcd`,
+			want: `{"id":5,"token":"***"`,
+		},
+		{
+			name: "truncated object",
+			body: `{"token":{"a":"b`,
+			want: `{"token":"***"`,
+		},
+		{
+			name: "query string",
+			body: `a=1&token=secret&b=2`,
+			want: `a=1&token=***&b=2`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(r.Redact([]byte(tt.body))); got != tt.want {
+				t.Errorf("Redact() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorRedactValue(t *testing.T) {
+	r := NewRedactor("token", "*.phone")
+	value := map[string]interface{}{
+		"token":   "abc",
+		"payload": map[string]interface{}{"phone": "7900", "list": []interface{}{map[string]interface{}{"Token": 1}}},
+	}
+	want := map[string]interface{}{
+		"token":   RedactMask,
+		"payload": map[string]interface{}{"phone": RedactMask, "list": []interface{}{map[string]interface{}{"Token": RedactMask}}},
+	}
+
+	if got := r.RedactValue(value); !reflect.DeepEqual(got, want) {
+		t.Errorf("RedactValue() = %v, want %v", got, want)
+	}
+	if value["token"] != "abc" {
+		t.Errorf("Expecting source value to be unchanged")
+	}
+}
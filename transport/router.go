@@ -14,31 +14,71 @@ import (
 	"github.com/valyala/fasthttp"
 )
 
+// Router holds routes with their timings and docs
+type Router struct {
+	postRoutes       map[string]RouterFunc
+	postSimpleRoutes map[string]fasthttp.RequestHandler
+	postRegRoutes    map[*regexp.Regexp]RouterFunc
+	getRoutes        map[string]RouterFunc
+	getSimpleRoutes  map[string]fasthttp.RequestHandler
+	getRegRoutes     map[*regexp.Regexp]RouterFunc
+	getPrefixRoutes  map[string]RouterFunc
+	headPrefixRoutes map[string]RouterFunc
+	anyPrefixRoutes  map[string]RouterFunc
+	putRoutes        map[string]RouterFunc
+	timings          map[string]*median
+	timingsReg       map[*regexp.Regexp]*median
+	extraStats       map[string]fmt.Stringer
+	routeDocs        map[string]*RouteDoc
+}
+
 var (
-	postRoutes       = make(map[string]RouterFunc)
-	postSimpleRoutes = make(map[string]fasthttp.RequestHandler)
-	postRegRoutes    = make(map[*regexp.Regexp]RouterFunc)
-	getRoutes        = make(map[string]RouterFunc)
-	getSimpleRoutes  = make(map[string]fasthttp.RequestHandler)
-	getRegRoutes     = make(map[*regexp.Regexp]RouterFunc)
-	clientsPool      = sync.Pool{
+	// DefaultRouter is used by package level functions
+	DefaultRouter = NewRouter()
+	clientsPool   = sync.Pool{
 		New: func() interface{} {
 			return &fasthttp.Client{}
 		},
 	}
-	timings      = make(map[string]*median)
-	timingsReg   = make(map[*regexp.Regexp]*median)
 	logger       = log.New(os.Stdout, "\n-----------------------------\n", log.LstdFlags)
 	pingResponse = []byte("OK")
 )
 
 func init() {
-	AddGetRoute("/internal/stats", handlerInternalStats)
-	AddGetRouteSimple("/internal/stats", handlerInternalStatsSimple)
+	DefaultRouter.AddInternalRoutes()
 	AddGetRoute("/internal/shutdown", shutdown)
 	AddGetRouteSimple("/internal/shutdown", shutdownSimple)
-	AddGetRoute("/ping", ping)
-	AddGetRouteSimple("/ping", pingSimple)
+}
+
+// NewRouter creates empty router, routes registered by package level functions
+// are not visible to it
+func NewRouter() *Router {
+	return &Router{
+		postRoutes:       make(map[string]RouterFunc),
+		postSimpleRoutes: make(map[string]fasthttp.RequestHandler),
+		postRegRoutes:    make(map[*regexp.Regexp]RouterFunc),
+		getRoutes:        make(map[string]RouterFunc),
+		getSimpleRoutes:  make(map[string]fasthttp.RequestHandler),
+		getRegRoutes:     make(map[*regexp.Regexp]RouterFunc),
+		getPrefixRoutes:  make(map[string]RouterFunc),
+		headPrefixRoutes: make(map[string]RouterFunc),
+		anyPrefixRoutes:  make(map[string]RouterFunc),
+		putRoutes:        make(map[string]RouterFunc),
+		timings:          make(map[string]*median),
+		timingsReg:       make(map[*regexp.Regexp]*median),
+		extraStats:       make(map[string]fmt.Stringer),
+		routeDocs:        make(map[string]*RouteDoc),
+	}
+}
+
+// AddInternalRoutes adds /ping, /internal/stats and OpenAPI document routes
+func (r *Router) AddInternalRoutes() {
+	r.AddGetRoute("/internal/stats", r.handlerInternalStats)
+	r.AddGetRouteSimple("/internal/stats", r.handlerInternalStatsSimple)
+	r.AddGetRoute(OpenAPIPath, r.handlerOpenAPI)
+	r.AddGetRouteSimple(OpenAPIPath, r.handlerOpenAPISimple)
+	r.AddGetRoute("/ping", ping)
+	r.AddGetRouteSimple("/ping", pingSimple)
 }
 
 type median struct {
@@ -80,137 +120,314 @@ type RouterFunc func(*fasthttp.RequestCtx, time.Time, ...string)
 
 // AddGetRoute adds get route
 func AddGetRoute(path string, handler RouterFunc) {
-	getRoutes[path] = handler
-	timings["[GET] "+path] = &median{}
+	DefaultRouter.AddGetRoute(path, handler)
+}
+
+// AddGetRoute adds get route
+func (r *Router) AddGetRoute(path string, handler RouterFunc) {
+	r.getRoutes[path] = handler
+	r.timings["[GET] "+path] = &median{}
 }
 
 // AddGetRouteSimple dosmth
 func AddGetRouteSimple(path string, handler fasthttp.RequestHandler) {
-	getSimpleRoutes[path] = handler
-	timings["[GET] "+path] = &median{}
+	DefaultRouter.AddGetRouteSimple(path, handler)
+}
+
+// AddGetRouteSimple dosmth
+func (r *Router) AddGetRouteSimple(path string, handler fasthttp.RequestHandler) {
+	r.getSimpleRoutes[path] = handler
+	r.timings["[GET] "+path] = &median{}
 }
 
 // AddPostRouteSimple dosmth
 func AddPostRouteSimple(path string, handler fasthttp.RequestHandler) {
-	postSimpleRoutes[path] = handler
-	timings["[POST] "+path] = &median{}
+	DefaultRouter.AddPostRouteSimple(path, handler)
+}
+
+// AddPostRouteSimple dosmth
+func (r *Router) AddPostRouteSimple(path string, handler fasthttp.RequestHandler) {
+	r.postSimpleRoutes[path] = handler
+	r.timings["[POST] "+path] = &median{}
 }
 
 // AddGetRegexpRoute adds get route. For example /accounts/([0-9]+)/suggest/.
 //The result of regex will be passed as s third parameter in router.RouterFunc
 func AddGetRegexpRoute(path string, handler RouterFunc) {
+	DefaultRouter.AddGetRegexpRoute(path, handler)
+}
+
+// AddGetRegexpRoute adds get route. For example /accounts/([0-9]+)/suggest/.
+//The result of regex will be passed as s third parameter in router.RouterFunc
+func (r *Router) AddGetRegexpRoute(path string, handler RouterFunc) {
 	if re, err := regexp.Compile(path); err == nil {
-		getRegRoutes[re] = handler
-		timingsReg[re] = &median{}
+		r.getRegRoutes[re] = handler
+		r.timingsReg[re] = &median{}
 	}
 }
 
+// AddGetPrefixRoute adds get route for all pathes starting with prefix.
+// The rest of the path will be passed as a third parameter in router.RouterFunc.
+// Exact and regexp routes take precedence, the longest prefix wins
+func AddGetPrefixRoute(prefix string, handler RouterFunc) {
+	DefaultRouter.AddGetPrefixRoute(prefix, handler)
+}
+
+// AddGetPrefixRoute adds get route for all pathes starting with prefix.
+// The rest of the path will be passed as a third parameter in router.RouterFunc.
+// Exact and regexp routes take precedence, the longest prefix wins
+func (r *Router) AddGetPrefixRoute(prefix string, handler RouterFunc) {
+	r.getPrefixRoutes[prefix] = handler
+	r.timings["[GET] "+prefix+"*"] = &median{}
+}
+
+// AddHeadPrefixRoute adds head route for all pathes starting with prefix, e.g. the get prefix route
+// handler which answers HEAD requests itself. Get routes are never called for HEAD requests
+func AddHeadPrefixRoute(prefix string, handler RouterFunc) {
+	DefaultRouter.AddHeadPrefixRoute(prefix, handler)
+}
+
+// AddHeadPrefixRoute adds head route for all pathes starting with prefix, e.g. the get prefix route
+// handler which answers HEAD requests itself. Get routes are never called for HEAD requests
+func (r *Router) AddHeadPrefixRoute(prefix string, handler RouterFunc) {
+	r.headPrefixRoutes[prefix] = handler
+	r.timings["[HEAD] "+prefix+"*"] = &median{}
+}
+
+// AddPrefixRoute adds route for all methods and pathes starting with prefix, e.g. for proxies.
+// The rest of the path will be passed as a third parameter in router.RouterFunc.
+// It is checked when there is no other route for the request
+func AddPrefixRoute(prefix string, handler RouterFunc) {
+	DefaultRouter.AddPrefixRoute(prefix, handler)
+}
+
+// AddPrefixRoute adds route for all methods and pathes starting with prefix, e.g. for proxies.
+// The rest of the path will be passed as a third parameter in router.RouterFunc.
+// It is checked when there is no other route for the request
+func (r *Router) AddPrefixRoute(prefix string, handler RouterFunc) {
+	r.anyPrefixRoutes[prefix] = handler
+	r.timings["[ANY] "+prefix+"*"] = &median{}
+}
+
+// AddStats adds custom stats shown by /internal/stats under the given name.
+// String should return a single line
+func AddStats(name string, stats fmt.Stringer) {
+	DefaultRouter.AddStats(name, stats)
+}
+
+// AddStats adds custom stats shown by /internal/stats under the given name.
+// String should return a single line
+func (r *Router) AddStats(name string, stats fmt.Stringer) {
+	r.extraStats[name] = stats
+}
+
 // AddPostRoute adds post route
 func AddPostRoute(path string, handler RouterFunc) {
-	postRoutes[path] = handler
-	timings["[POST] "+path] = &median{}
+	DefaultRouter.AddPostRoute(path, handler)
+}
+
+// AddPostRoute adds post route
+func (r *Router) AddPostRoute(path string, handler RouterFunc) {
+	r.postRoutes[path] = handler
+	r.timings["[POST] "+path] = &median{}
+}
+
+// AddPutRoute adds put route
+func AddPutRoute(path string, handler RouterFunc) {
+	DefaultRouter.AddPutRoute(path, handler)
+}
+
+// AddPutRoute adds put route
+func (r *Router) AddPutRoute(path string, handler RouterFunc) {
+	r.putRoutes[path] = handler
+	r.timings["[PUT] "+path] = &median{}
 }
 
 // AddPostRegexpRoute adds post route
 func AddPostRegexpRoute(path string, handler RouterFunc) {
+	DefaultRouter.AddPostRegexpRoute(path, handler)
+}
+
+// AddPostRegexpRoute adds post route
+func (r *Router) AddPostRegexpRoute(path string, handler RouterFunc) {
 	if re, err := regexp.Compile(path); err == nil {
-		postRegRoutes[re] = handler
-		timingsReg[re] = &median{}
+		r.postRegRoutes[re] = handler
+		r.timingsReg[re] = &median{}
 	}
 }
 
 // ProcessRouting returns router
-// Обрабатывает только GET и POST
+// Обрабатывает только GET, POST, PUT и HEAD для AddHeadPrefixRoute
 // логи обрезаются только у POST запросов
 func ProcessRouting(server PathesLogger) fasthttp.RequestHandler {
+	return DefaultRouter.ProcessRouting(server)
+}
+
+// ProcessRouting returns router
+// Обрабатывает только GET, POST, PUT и HEAD для AddHeadPrefixRoute
+// логи обрезаются только у POST запросов
+func (r *Router) ProcessRouting(server PathesLogger) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
 		now := time.Now()
 		path := string(ctx.Path())
 		reqID := ctx.ID()
+		logFlag := server.GetLogFlag(path)
+		route := ""
+
+		if accessLog != nil {
+			defer func() { writeAccess(ctx, now, route, logFlag) }()
+		}
+
 		switch string(ctx.Method()) {
 		case fasthttp.MethodPost:
 			body := ctx.PostBody()
-			if logFlag := server.GetLogFlag(path); (logFlag & ToLog) != 0 {
+			if (logFlag&ToLog) != 0 && accessLog == nil {
 				if (logFlag & FullLog) != 0 {
-					logger.Printf("[POST %s %d][Request] %s\n", path, reqID, body)
+					logger.Printf("[POST %s %d][Request] %s\n", path, reqID, RedactBody(body))
 				} else {
-					logger.Printf("[POST %s %d][Request] %s\n", path, reqID, body[:ints.MinInt(len(body), 255)])
+					logger.Printf("[POST %s %d][Request] %s\n", path, reqID, RedactBody(body[:ints.MinInt(len(body), 255)]))
 				}
 			}
-			if handler, ok := postRoutes[path]; ok {
+			if handler, ok := r.postRoutes[path]; ok {
+				route = path
 				handler(ctx, now)
-				timings["[POST] "+path].Update(time.Since(now))
+				r.timings["[POST] "+path].Update(time.Since(now))
 			} else {
-				for k, v := range postRegRoutes {
+				for k, v := range r.postRegRoutes {
 					adds := k.FindStringSubmatch(path)
 					if len(adds) > 1 {
+						route = k.String()
 						v(ctx, now, adds[1:]...)
 						return
 					}
 				}
-				ctx.Error("Not found", fasthttp.StatusNotFound)
+				if route = r.serveAnyPrefix(ctx, now, path); route == "" {
+					ctx.Error("Not found", fasthttp.StatusNotFound)
+				}
 			}
 		case fasthttp.MethodGet:
-			if logFlag := server.GetLogFlag(path); (logFlag & ToLog) != 0 {
-				logger.Printf("[GET %s %d][Request] %s\n", path, reqID, ctx.QueryArgs().QueryString())
+			if (logFlag&ToLog) != 0 && accessLog == nil {
+				logger.Printf("[GET %s %d][Request] %s\n", path, reqID, RedactBody(ctx.QueryArgs().QueryString()))
 			}
-			if handler, ok := getRoutes[path]; ok {
+			if handler, ok := r.getRoutes[path]; ok {
+				route = path
 				handler(ctx, now)
-				timings["[GET] "+path].Update(time.Since(now))
+				r.timings["[GET] "+path].Update(time.Since(now))
 			} else {
-				for k, v := range getRegRoutes {
+				for k, v := range r.getRegRoutes {
 					adds := k.FindStringSubmatch(path)
 					if len(adds) > 1 {
+						route = k.String()
 						v(ctx, now, adds[1:]...)
-						timingsReg[k].Update(time.Since(now))
+						r.timingsReg[k].Update(time.Since(now))
 						return
 					}
 				}
+				if prefix, handler, ok := matchPrefixRoute(r.getPrefixRoutes, path); ok {
+					route = prefix + "*"
+					handler(ctx, now, path[len(prefix):])
+					r.timings["[GET] "+route].Update(time.Since(now))
+					return
+				}
+				if route = r.serveAnyPrefix(ctx, now, path); route == "" {
+					ctx.Error("Not found", fasthttp.StatusNotFound)
+				}
+			}
+		case fasthttp.MethodHead:
+			if prefix, handler, ok := matchPrefixRoute(r.headPrefixRoutes, path); ok {
+				route = prefix + "*"
+				handler(ctx, now, path[len(prefix):])
+				r.timings["[HEAD] "+route].Update(time.Since(now))
+			} else if route = r.serveAnyPrefix(ctx, now, path); route == "" {
+				ctx.Error("Not found", fasthttp.StatusNotFound)
+			}
+		case fasthttp.MethodPut:
+			if (logFlag&ToLog) != 0 && accessLog == nil {
+				body := ctx.PostBody()
+				logger.Printf("[PUT %s %d][Request] %s\n", path, reqID, RedactBody(truncateLog(body, logFlag)))
+			}
+			if handler, ok := r.putRoutes[path]; ok {
+				route = path
+				handler(ctx, now)
+				r.timings["[PUT] "+path].Update(time.Since(now))
+			} else if route = r.serveAnyPrefix(ctx, now, path); route == "" {
 				ctx.Error("Not found", fasthttp.StatusNotFound)
 			}
 		default:
-			ctx.Error("Not found", fasthttp.StatusNotFound)
+			if route = r.serveAnyPrefix(ctx, now, path); route == "" {
+				ctx.Error("Not found", fasthttp.StatusNotFound)
+			}
 		}
 	}
 }
 
 // ProcessSimpleRouting тоже самое что ProcessRouting, только без логирования
 func ProcessSimpleRouting() fasthttp.RequestHandler {
+	return DefaultRouter.ProcessSimpleRouting()
+}
+
+// ProcessSimpleRouting тоже самое что ProcessRouting, только без логирования
+func (r *Router) ProcessSimpleRouting() fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
 		now := time.Now()
 		path := string(ctx.Path())
 		switch string(ctx.Method()) {
 		case fasthttp.MethodPost:
-			if handler, ok := postRoutes[path]; ok {
+			if handler, ok := r.postRoutes[path]; ok {
 				handler(ctx, now)
-				timings["[POST] "+path].Update(time.Since(now))
+				r.timings["[POST] "+path].Update(time.Since(now))
 			} else {
-				for k, v := range postRegRoutes {
+				for k, v := range r.postRegRoutes {
 					adds := k.FindStringSubmatch(path)
 					if len(adds) > 1 {
 						v(ctx, now, adds[1:]...)
 						return
 					}
 				}
-				ctx.Error("Not found", fasthttp.StatusNotFound)
+				if r.serveAnyPrefix(ctx, now, path) == "" {
+					ctx.Error("Not found", fasthttp.StatusNotFound)
+				}
 			}
 		case fasthttp.MethodGet:
-			if handler, ok := getRoutes[path]; ok {
+			if handler, ok := r.getRoutes[path]; ok {
 				handler(ctx, now)
-				timings["[GET] "+path].Update(time.Since(now))
+				r.timings["[GET] "+path].Update(time.Since(now))
 			} else {
-				for k, v := range getRegRoutes {
+				for k, v := range r.getRegRoutes {
 					adds := k.FindStringSubmatch(path)
 					if len(adds) > 1 {
 						v(ctx, now, adds[1:]...)
-						timingsReg[k].Update(time.Since(now))
+						r.timingsReg[k].Update(time.Since(now))
 						return
 					}
 				}
+				if prefix, handler, ok := matchPrefixRoute(r.getPrefixRoutes, path); ok {
+					handler(ctx, now, path[len(prefix):])
+					r.timings["[GET] "+prefix+"*"].Update(time.Since(now))
+					return
+				}
+				if r.serveAnyPrefix(ctx, now, path) == "" {
+					ctx.Error("Not found", fasthttp.StatusNotFound)
+				}
+			}
+		case fasthttp.MethodHead:
+			if prefix, handler, ok := matchPrefixRoute(r.headPrefixRoutes, path); ok {
+				handler(ctx, now, path[len(prefix):])
+				r.timings["[HEAD] "+prefix+"*"].Update(time.Since(now))
+			} else if r.serveAnyPrefix(ctx, now, path) == "" {
+				ctx.Error("Not found", fasthttp.StatusNotFound)
+			}
+		case fasthttp.MethodPut:
+			if handler, ok := r.putRoutes[path]; ok {
+				handler(ctx, now)
+				r.timings["[PUT] "+path].Update(time.Since(now))
+			} else if r.serveAnyPrefix(ctx, now, path) == "" {
 				ctx.Error("Not found", fasthttp.StatusNotFound)
 			}
 		default:
-			ctx.Error("Not found", fasthttp.StatusNotFound)
+			if r.serveAnyPrefix(ctx, now, path) == "" {
+				ctx.Error("Not found", fasthttp.StatusNotFound)
+			}
 		}
 	}
 }
@@ -219,27 +436,41 @@ func ProcessSimpleRouting() fasthttp.RequestHandler {
 // без regexp routes + обрабатывает только GET и POST
 // логи обрезаются только у POST запросов
 func ProcessStandardRouting(server PathesLogger) fasthttp.RequestHandler {
+	return DefaultRouter.ProcessStandardRouting(server)
+}
+
+// ProcessStandardRouting работает с хендлерами, соотв стандартной сигнатуре fasthttp
+// без regexp routes + обрабатывает только GET и POST
+// логи обрезаются только у POST запросов
+func (r *Router) ProcessStandardRouting(server PathesLogger) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
 		path := string(ctx.Path())
+		logFlag := server.GetLogFlag(path)
+		route := ""
+
+		if accessLog != nil {
+			defer func() { writeAccess(ctx, ctx.Time(), route, logFlag) }()
+		}
 
 		switch string(ctx.Method()) {
 		case "POST":
 			body := ctx.PostBody()
-			if logFlag := server.GetLogFlag(path); (logFlag & ToLog) != 0 {
+			if (logFlag&ToLog) != 0 && accessLog == nil {
 				if (logFlag & FullLog) != 0 {
-					logger.Printf("[POST %s %d][Request] %s\n", path, ctx.ID(), body)
+					logger.Printf("[POST %s %d][Request] %s\n", path, ctx.ID(), RedactBody(body))
 				} else {
-					logger.Printf("[POST %s %d][Request] %s\n", path, ctx.ID(), body[:ints.MinInt(len(body), 255)])
+					logger.Printf("[POST %s %d][Request] %s\n", path, ctx.ID(), RedactBody(body[:ints.MinInt(len(body), 255)]))
 				}
 			}
-			if handler, ok := postSimpleRoutes[path]; ok {
+			if handler, ok := r.postSimpleRoutes[path]; ok {
+				route = path
 				handler(ctx)
-				timings["[POST] "+path].Update(time.Since(ctx.Time()))
+				r.timings["[POST] "+path].Update(time.Since(ctx.Time()))
 			} else {
 				ctx.Error("Not found", fasthttp.StatusNotFound)
 			}
 		case "GET":
-			if logFlag := server.GetLogFlag(path); (logFlag & ToLog) != 0 {
+			if (logFlag&ToLog) != 0 && accessLog == nil {
 				var (
 					queryString string
 					err         error
@@ -247,11 +478,12 @@ func ProcessStandardRouting(server PathesLogger) fasthttp.RequestHandler {
 				if queryString, err = url.QueryUnescape(string(ctx.QueryArgs().QueryString())); err != nil {
 					queryString = string(ctx.QueryArgs().QueryString())
 				}
-				logger.Printf("[GET %s %d][Request] %s\n", path, ctx.ID(), queryString)
+				logger.Printf("[GET %s %d][Request] %s\n", path, ctx.ID(), RedactBody([]byte(queryString)))
 			}
-			if handler, ok := getSimpleRoutes[path]; ok {
+			if handler, ok := r.getSimpleRoutes[path]; ok {
+				route = path
 				handler(ctx)
-				timings["[GET] "+path].Update(time.Since(ctx.Time()))
+				r.timings["[GET] "+path].Update(time.Since(ctx.Time()))
 			} else {
 				ctx.Error("Not found", fasthttp.StatusNotFound)
 			}
@@ -261,6 +493,29 @@ func ProcessStandardRouting(server PathesLogger) fasthttp.RequestHandler {
 	}
 }
 
+// serveAnyPrefix serves route added by AddPrefixRoute, returns matched route or empty string
+func (r *Router) serveAnyPrefix(ctx *fasthttp.RequestCtx, now time.Time, path string) string {
+	prefix, handler, ok := matchPrefixRoute(r.anyPrefixRoutes, path)
+	if !ok {
+		return ""
+	}
+
+	handler(ctx, now, path[len(prefix):])
+	r.timings["[ANY] "+prefix+"*"].Update(time.Since(now))
+
+	return prefix + "*"
+}
+
+func matchPrefixRoute(routes map[string]RouterFunc, path string) (prefix string, handler RouterFunc, ok bool) {
+	for k, v := range routes {
+		if len(k) > len(prefix) && strings.HasPrefix(path, k) {
+			prefix, handler, ok = k, v, true
+		}
+	}
+
+	return prefix, handler, ok
+}
+
 //todo ProcessStandardRoutingAllowCORS
 // как при этом настраивать какие хедеры слать в случае - может метод, если пустой, то стандартный хэндлер
 
@@ -274,28 +529,36 @@ func PutHTTPClient(client *fasthttp.Client) {
 	clientsPool.Put(client)
 }
 
-func handlerInternalStats(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+func (r *Router) handlerInternalStats(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
 	var res strings.Builder
 
-	for k, v := range timings {
+	for k, v := range r.timings {
 		res.WriteString(fmt.Sprintf("%s: %s", k, v))
 	}
 
-	for k, v := range timingsReg {
+	for k, v := range r.timingsReg {
+		res.WriteString(fmt.Sprintf("%s: %s\n", k, v))
+	}
+
+	for k, v := range r.extraStats {
 		res.WriteString(fmt.Sprintf("%s: %s\n", k, v))
 	}
 
 	ctx.SetBodyString(res.String())
 }
 
-func handlerInternalStatsSimple(ctx *fasthttp.RequestCtx) {
+func (r *Router) handlerInternalStatsSimple(ctx *fasthttp.RequestCtx) {
 	var res strings.Builder
 
-	for k, v := range timings {
+	for k, v := range r.timings {
 		res.WriteString(fmt.Sprintf("%s: %s", k, v))
 	}
 
-	for k, v := range timingsReg {
+	for k, v := range r.timingsReg {
+		res.WriteString(fmt.Sprintf("%s: %s\n", k, v))
+	}
+
+	for k, v := range r.extraStats {
 		res.WriteString(fmt.Sprintf("%s: %s\n", k, v))
 	}
 
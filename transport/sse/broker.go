@@ -0,0 +1,323 @@
+package sse
+
+import (
+	"bufio"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+)
+
+// HeaderLastEventID header sent by reconnecting clients
+const HeaderLastEventID = "Last-Event-ID"
+
+// Defaults
+const (
+	DefaultReplaySize = 100
+	DefaultQueue      = 64
+	DefaultHeartbeat  = 15 * time.Second
+	DefaultReplayTTL  = 5 * time.Minute
+)
+
+var heartbeat = []byte(": ping\n\n")
+
+// BrokerConfig describes broker
+type BrokerConfig struct {
+	// ReplaySize number of last events per topic kept for Last-Event-ID resume
+	ReplaySize int
+	// Queue number of events buffered per subscriber, subscribers which
+	// can't keep up are disconnected and resume with Last-Event-ID
+	Queue int
+	// Heartbeat period of comments keeping idle connections alive, negative disables them
+	Heartbeat time.Duration
+	// Retry reconnection delay sent to clients on connect, 0 means browser default
+	Retry time.Duration
+	// ReplayTTL topic without subscribers is dropped with its replay buffer
+	// when nothing is published to it for ReplayTTL
+	ReplayTTL time.Duration
+}
+
+// TopicFunc returns topic of the request
+type TopicFunc func(ctx *fasthttp.RequestCtx, adds ...string) string
+
+// Broker fans out events published into topics to subscribers
+type Broker struct {
+	sync.Mutex
+	config BrokerConfig
+	topics map[string]*topic
+}
+
+type topic struct {
+	seq    uint64
+	replay []Event
+	subs   map[*Subscription]struct{}
+	// idle time of the last publish or unsubscribe, expire timer is running while there are no subscribers
+	idle   time.Time
+	expire *time.Timer
+}
+
+// Subscription receives events of a topic, Events channel is closed
+// when the subscription is closed or dropped as a slow one
+type Subscription struct {
+	Events <-chan Event
+	events chan Event
+	broker *Broker
+	topic  string
+}
+
+// NewBroker creates broker
+func NewBroker(config BrokerConfig) *Broker {
+	if config.ReplaySize <= 0 {
+		config.ReplaySize = DefaultReplaySize
+	}
+
+	if config.Queue <= 0 {
+		config.Queue = DefaultQueue
+	}
+
+	if config.Heartbeat == 0 {
+		config.Heartbeat = DefaultHeartbeat
+	}
+
+	if config.ReplayTTL <= 0 {
+		config.ReplayTTL = DefaultReplayTTL
+	}
+
+	return &Broker{config: config, topics: make(map[string]*topic)}
+}
+
+func (b *Broker) topic(name string) *topic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &topic{subs: make(map[*Subscription]struct{})}
+		b.topics[name] = t
+	}
+
+	return t
+}
+
+// release starts expiration of the topic without subscribers, caller holds the lock
+func (b *Broker) release(name string, t *topic) {
+	if len(t.subs) > 0 {
+		return
+	}
+
+	t.idle = time.Now()
+
+	if t.expire == nil {
+		t.expire = time.AfterFunc(b.config.ReplayTTL, func() { b.drop(name, t) })
+	}
+}
+
+// drop removes the topic if it is still idle, otherwise waits for the rest of ReplayTTL
+func (b *Broker) drop(name string, t *topic) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.topics[name] != t || len(t.subs) > 0 {
+		t.expire = nil
+		return
+	}
+
+	if rest := b.config.ReplayTTL - time.Since(t.idle); rest > 0 {
+		t.expire.Reset(rest)
+		return
+	}
+
+	delete(b.topics, name)
+}
+
+// Publish sends event to all subscribers of the topic.
+// Events without ID get sequential ids within the topic
+func (b *Broker) Publish(name string, event Event) {
+	b.Lock()
+	defer b.Unlock()
+
+	t := b.topic(name)
+	t.seq++
+
+	if event.ID == "" {
+		event.ID = strconv.FormatUint(t.seq, 10)
+	}
+
+	if len(t.replay) >= b.config.ReplaySize {
+		t.replay = append(t.replay[:0], t.replay[len(t.replay)-b.config.ReplaySize+1:]...)
+	}
+
+	t.replay = append(t.replay, event)
+
+	for sub := range t.subs {
+		select {
+		case sub.events <- event:
+		default:
+			delete(t.subs, sub)
+			close(sub.events)
+		}
+	}
+
+	b.release(name, t)
+}
+
+// Subscribe subscribes to the topic. If lastEventID is not empty, buffered events
+// published after it are delivered first, all buffered events are delivered
+// if the id is unknown (too old or the topic was dropped after ReplayTTL)
+func (b *Broker) Subscribe(name, lastEventID string) *Subscription {
+	b.Lock()
+	defer b.Unlock()
+
+	t := b.topic(name)
+
+	var replay []Event
+
+	if lastEventID != "" {
+		replay = t.replay
+
+		for i := len(t.replay) - 1; i >= 0; i-- {
+			if t.replay[i].ID == lastEventID {
+				replay = t.replay[i+1:]
+				break
+			}
+		}
+	}
+
+	events := make(chan Event, b.config.Queue+len(replay))
+	for _, e := range replay {
+		events <- e
+	}
+
+	sub := &Subscription{Events: events, events: events, broker: b, topic: name}
+	t.subs[sub] = struct{}{}
+
+	return sub
+}
+
+// Subscribers returns number of subscribers of the topic
+func (b *Broker) Subscribers(name string) int {
+	b.Lock()
+	defer b.Unlock()
+
+	if t, ok := b.topics[name]; ok {
+		return len(t.subs)
+	}
+
+	return 0
+}
+
+// Close closes the subscription
+func (s *Subscription) Close() {
+	s.broker.Lock()
+	defer s.broker.Unlock()
+
+	if t, ok := s.broker.topics[s.topic]; ok {
+		if _, ok = t.subs[s]; ok {
+			delete(t.subs, s)
+			close(s.events)
+			s.broker.release(s.topic, t)
+		}
+	}
+}
+
+// Handler returns handler streaming the topic of the request. If topic is nil,
+// the third parameter (regexp or prefix route match) is used, request path otherwise.
+// Last-Event-ID is taken from the header or lastEventId query argument
+func (b *Broker) Handler(topic TopicFunc) transport.RouterFunc {
+	return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		var name string
+
+		switch {
+		case topic != nil:
+			name = topic(ctx, adds...)
+		case len(adds) > 0:
+			name = adds[0]
+		default:
+			name = string(ctx.Path())
+		}
+
+		lastEventID := string(ctx.Request.Header.Peek(HeaderLastEventID))
+		if lastEventID == "" {
+			lastEventID = string(ctx.QueryArgs().Peek("lastEventId"))
+		}
+
+		b.Serve(ctx, b.Subscribe(name, lastEventID))
+	}
+}
+
+// Serve streams subscription to the client, subscription is closed when the client goes away
+func (b *Broker) Serve(ctx *fasthttp.RequestCtx, sub *Subscription) {
+	stream(ctx, sub.Events, b.config.Heartbeat, b.config.Retry, sub.Close)
+}
+
+// Register adds get route streaming the topic to transport.DefaultRouter
+func Register(path string, broker *Broker, topic TopicFunc, middlewares ...transport.Middleware) {
+	RegisterOn(transport.DefaultRouter, path, broker, topic, middlewares...)
+}
+
+// RegisterOn adds get route streaming the topic to router
+func RegisterOn(router *transport.Router, path string, broker *Broker, topic TopicFunc, middlewares ...transport.Middleware) {
+	router.AddGetRoute(path, transport.Chain(broker.Handler(topic), middlewares...))
+}
+
+// Stream streams events from the channel until it is closed or the client goes away
+func Stream(ctx *fasthttp.RequestCtx, events <-chan Event, heartbeatPeriod time.Duration) {
+	stream(ctx, events, heartbeatPeriod, 0, nil)
+}
+
+func stream(ctx *fasthttp.RequestCtx, events <-chan Event, heartbeatPeriod, retry time.Duration, done func()) {
+	ctx.SetContentType("text/event-stream")
+	ctx.Response.Header.Set(fasthttp.HeaderCacheControl, "no-cache")
+	ctx.Response.Header.Set("X-Accel-Buffering", "no")
+
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		if done != nil {
+			defer done()
+		}
+
+		var (
+			buf  []byte
+			tick <-chan time.Time
+		)
+
+		if heartbeatPeriod > 0 {
+			ticker := time.NewTicker(heartbeatPeriod)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		if retry > 0 {
+			buf = append(buf, "retry: "...)
+			buf = strconv.AppendInt(buf, int64(retry/time.Millisecond), 10)
+			buf = append(buf, '\n', '\n')
+		} else {
+			buf = append(buf, heartbeat...)
+		}
+
+		// headers are sent with the first write
+		if _, err := w.Write(buf); err != nil || w.Flush() != nil {
+			return
+		}
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+
+				buf = event.AppendTo(buf[:0])
+				if _, err := w.Write(buf); err != nil {
+					return
+				}
+			case <-tick:
+				if _, err := w.Write(heartbeat); err != nil {
+					return
+				}
+			}
+
+			if w.Flush() != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package sse
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a single server-sent event
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	// Retry reconnection delay for the client, sent if positive
+	Retry time.Duration
+}
+
+var lineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// JSONEvent creates event with v encoded as json data
+func JSONEvent(event string, v interface{}) (Event, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{Event: event, Data: string(data)}, nil
+}
+
+// AppendTo appends event in wire format, multiline data is sent as several data fields
+func (e *Event) AppendTo(dst []byte) []byte {
+	if e.ID != "" {
+		dst = appendField(dst, "id", singleLine(e.ID))
+	}
+
+	if e.Event != "" {
+		dst = appendField(dst, "event", singleLine(e.Event))
+	}
+
+	if e.Retry > 0 {
+		dst = appendField(dst, "retry", strconv.FormatInt(int64(e.Retry/time.Millisecond), 10))
+	}
+
+	for _, line := range strings.Split(lineBreaks.Replace(e.Data), "\n") {
+		dst = appendField(dst, "data", line)
+	}
+
+	return append(dst, '\n')
+}
+
+func appendField(dst []byte, name, value string) []byte {
+	dst = append(dst, name...)
+	dst = append(dst, ':', ' ')
+	dst = append(dst, value...)
+
+	return append(dst, '\n')
+}
+
+func singleLine(s string) string {
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		return s[:i]
+	}
+
+	return s
+}
+
+// Reader parses event stream, it is used by clients and tests
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader creates event stream reader
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns next event, comments (heartbeats) and events without data are skipped
+func (r *Reader) Next() (Event, error) {
+	var (
+		event Event
+		data  []string
+		seen  bool
+	)
+
+	for {
+		line, err := r.r.ReadString('\n')
+		if err != nil {
+			return Event{}, err
+		}
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if seen {
+				event.Data = strings.Join(data, "\n")
+				return event, nil
+			}
+
+			event = Event{}
+
+			continue
+		}
+
+		if line[0] == ':' {
+			continue
+		}
+
+		name, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			name, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch name {
+		case "id":
+			event.ID = value
+		case "event":
+			event.Event = value
+		case "retry":
+			if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+				event.Retry = time.Duration(ms) * time.Millisecond
+			}
+		case "data":
+			data = append(data, value)
+			seen = true
+		}
+	}
+}
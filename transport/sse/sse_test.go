@@ -0,0 +1,157 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestEventRoundTrip(t *testing.T) {
+	in := Event{ID: "7", Event: "update", Data: "line1\r\nline2", Retry: 3 * time.Second}
+
+	r := NewReader(bytes.NewReader(append([]byte(": comment\n\n"), in.AppendTo(nil)...)))
+
+	out, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.ID != "7" || out.Event != "update" || out.Data != "line1\nline2" || out.Retry != in.Retry {
+		t.Errorf("Unexpected event %+v", out)
+	}
+}
+
+func TestBrokerReplay(t *testing.T) {
+	broker := NewBroker(BrokerConfig{ReplaySize: 3, Queue: 1})
+
+	for i := 0; i < 5; i++ {
+		broker.Publish("a", Event{Data: "x"})
+	}
+
+	sub := broker.Subscribe("a", "3")
+	if e := <-sub.Events; e.ID != "4" {
+		t.Errorf("Expecting replay from 4, got %+v", e)
+	}
+	if e := <-sub.Events; e.ID != "5" {
+		t.Errorf("Expecting replay of 5, got %+v", e)
+	}
+
+	old := broker.Subscribe("a", "1")
+	if e := <-old.Events; e.ID != "3" {
+		t.Errorf("Expecting whole buffer for unknown id, got %+v", e)
+	}
+
+	for i := 0; i < 4; i++ {
+		broker.Publish("a", Event{Data: "y"})
+	}
+
+	if e := <-sub.Events; e.ID != "6" {
+		t.Errorf("Expecting live event, got %+v", e)
+	}
+
+	received := 1
+	for range sub.Events {
+		received++
+	}
+
+	if received != 3 {
+		t.Errorf("Expecting slow subscriber to be dropped after 3 events, got %d", received)
+	}
+	if broker.Subscribers("a") != 0 {
+		t.Errorf("Expecting no subscribers, got %d", broker.Subscribers("a"))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	broker := NewBroker(BrokerConfig{Retry: time.Second})
+	transport.AddGetPrefixRoute("/test/sse/", broker.Handler(nil))
+	broker.Publish("feed", Event{Event: "old", Data: "1"})
+	broker.Publish("feed", Event{Event: "missed", Data: "2"})
+
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go fasthttp.Serve(ln, transport.ProcessRouting(&transport.LogPathes{}))
+
+	c, err := ln.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Write([]byte("GET /test/sse/feed HTTP/1.1\r\nHost: test\r\nLast-Event-ID: 1\r\n\r\n"))
+
+	resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Unexpected content type %s", ct)
+	}
+
+	r := NewReader(resp.Body)
+
+	if e, err := r.Next(); err != nil || e.Event != "missed" {
+		t.Fatalf("Expecting replayed event, got %+v %v", e, err)
+	}
+
+	for broker.Subscribers("feed") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	broker.Publish("feed", Event{Event: "live", Data: "3"})
+
+	if e, err := r.Next(); err != nil || e.Event != "live" || e.ID != "3" {
+		t.Errorf("Expecting live event, got %+v %v", e, err)
+	}
+}
+
+func TestBrokerDropsIdleTopics(t *testing.T) {
+	b := NewBroker(BrokerConfig{ReplayTTL: 100 * time.Millisecond})
+
+	b.Publish("published", Event{Data: "a"})
+
+	sub := b.Subscribe("subscribed", "")
+	b.Publish("subscribed", Event{Data: "b"})
+
+	kept := b.Subscribe("kept", "")
+	defer kept.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	sub.Close()
+	b.Publish("published", Event{Data: "c"})
+
+	time.Sleep(60 * time.Millisecond)
+
+	b.Lock()
+	n := len(b.topics)
+	b.Unlock()
+
+	if n != 3 {
+		t.Fatalf("Expecting topics to be kept until replay window expires, got %d", n)
+	}
+
+	if replay := b.Subscribe("published", "0"); len(replay.Events) != 2 {
+		t.Fatalf("Expecting replay of the recent topic, got %d events", len(replay.Events))
+	} else {
+		replay.Close()
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	b.Lock()
+	_, published := b.topics["published"]
+	_, subscribed := b.topics["subscribed"]
+	_, ok := b.topics["kept"]
+	b.Unlock()
+
+	if published || subscribed || !ok {
+		t.Fatalf("Expecting only topic with subscribers to be kept, got %v", b.topics)
+	}
+}
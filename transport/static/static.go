@@ -0,0 +1,328 @@
+package static
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+)
+
+// Config describes static files route
+type Config struct {
+	// Root file system, http.Dir for directory or http.FS for embed.FS
+	Root http.FileSystem
+	// Index file served for directories, index.html by default
+	Index string
+	// SPA serves index of the root for unknown pathes without extension
+	SPA bool
+	// Precompressed serves file.br and file.gz variants if client accepts them
+	Precompressed bool
+	// CacheControl by file extension (".js"), "" key is used for other files
+	CacheControl map[string]string
+}
+
+// precompressed preference order of precompressed variants
+var precompressed = transport.CompressionConfig{Encodings: []string{transport.EncodingBrotli, transport.EncodingGzip}}
+
+var variantExtensions = map[string]string{
+	transport.EncodingBrotli: ".br",
+	transport.EncodingGzip:   ".gz",
+}
+
+// Register adds get and head routes serving files under prefix to transport.DefaultRouter
+func Register(prefix string, config Config, middlewares ...transport.Middleware) {
+	RegisterOn(transport.DefaultRouter, prefix, config, middlewares...)
+}
+
+// RegisterOn adds get and head routes serving files under prefix to router
+func RegisterOn(router *transport.Router, prefix string, config Config, middlewares ...transport.Middleware) {
+	handler := transport.Chain(Handler(config), middlewares...)
+
+	router.AddGetPrefixRoute(prefix, handler)
+	router.AddHeadPrefixRoute(prefix, handler)
+}
+
+// Handler returns handler serving files, path relative to the root
+// is taken from the third parameter as passed by transport.AddGetPrefixRoute.
+// HEAD requests are answered without body, other methods get 405
+func Handler(config Config) transport.RouterFunc {
+	if config.Index == "" {
+		config.Index = "index.html"
+	}
+
+	return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		if !ctx.IsGet() && !ctx.IsHead() {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			ctx.Response.Header.Set("Allow", "GET, HEAD")
+
+			return
+		}
+
+		name := "/"
+		if len(adds) > 0 && adds[0] != "" {
+			name = adds[0]
+		}
+
+		serve(ctx, &config, name)
+	}
+}
+
+func serve(ctx *fasthttp.RequestCtx, config *Config, name string) {
+	trailingSlash := strings.HasSuffix(name, "/")
+	name = path.Clean("/" + name)
+
+	f, info, err := open(config.Root, name)
+	if err == nil && info.IsDir() {
+		if !trailingSlash {
+			f.Close()
+			location := string(ctx.Path()) + "/"
+			if query := ctx.URI().QueryString(); len(query) > 0 {
+				location += "?" + string(query)
+			}
+
+			ctx.Redirect(location, fasthttp.StatusMovedPermanently)
+
+			return
+		}
+
+		f.Close()
+		name = path.Join(name, config.Index)
+		f, info, err = open(config.Root, name)
+	}
+
+	if err != nil && os.IsNotExist(err) && config.SPA && path.Ext(name) == "" {
+		name = "/" + config.Index
+		f, info, err = open(config.Root, name)
+	}
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			ctx.Error("Not found", fasthttp.StatusNotFound)
+		} else if os.IsPermission(err) {
+			ctx.Error("Forbidden", fasthttp.StatusForbidden)
+		} else {
+			ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	if info.IsDir() {
+		f.Close()
+		ctx.Error("Not found", fasthttp.StatusNotFound)
+
+		return
+	}
+
+	ext := path.Ext(name)
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = transport.ApplicationOctetStream
+	}
+
+	encoding := ""
+	rangeHeader := ctx.Request.Header.Peek(fasthttp.HeaderRange)
+
+	if config.Precompressed {
+		transport.AddVary(&ctx.Response.Header, fasthttp.HeaderAcceptEncoding)
+
+		// ranges are served from the original file only
+		if len(rangeHeader) == 0 {
+			if encoding = transport.NegotiateEncoding(ctx, &precompressed); encoding != "" {
+				if vf, vinfo, verr := open(config.Root, name+variantExtensions[encoding]); verr == nil && !vinfo.IsDir() {
+					f.Close()
+					f, info = vf, vinfo
+				} else {
+					encoding = ""
+				}
+			}
+		}
+	}
+
+	etag := fmt.Sprintf("\"%x-%x\"", info.Size(), info.ModTime().UnixNano())
+	if encoding != "" {
+		etag = etag[:len(etag)-1] + "-" + encoding + "\""
+	}
+
+	modTime := info.ModTime().UTC().Truncate(time.Second)
+
+	h := &ctx.Response.Header
+	h.Set(fasthttp.HeaderETag, etag)
+	h.SetLastModified(modTime)
+	h.Set("Accept-Ranges", "bytes")
+	h.SetContentType(contentType)
+
+	if cc, ok := config.CacheControl[ext]; ok {
+		h.Set(fasthttp.HeaderCacheControl, cc)
+	} else if cc, ok = config.CacheControl[""]; ok {
+		h.Set(fasthttp.HeaderCacheControl, cc)
+	}
+
+	if encoding != "" {
+		h.Set(fasthttp.HeaderContentEncoding, encoding)
+	}
+
+	if notModified(ctx, etag, modTime) {
+		f.Close()
+		ctx.NotModified()
+
+		return
+	}
+
+	start, end, size := int64(0), info.Size()-1, info.Size()
+
+	if len(rangeHeader) > 0 && ifRange(ctx, etag, modTime) {
+		var ok bool
+		if start, end, ok = parseRange(string(rangeHeader), size); !ok {
+			f.Close()
+			ctx.Error("Requested range not satisfiable", fasthttp.StatusRequestedRangeNotSatisfiable)
+			ctx.Response.Header.Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+
+			return
+		}
+
+		ctx.SetStatusCode(fasthttp.StatusPartialContent)
+		h.SetContentRange(int(start), int(end), int(size))
+	}
+
+	length := end - start + 1
+
+	if ctx.IsHead() {
+		f.Close()
+		h.SetContentLength(int(length))
+
+		return
+	}
+
+	if start > 0 {
+		if _, err = f.Seek(start, io.SeekStart); err != nil {
+			f.Close()
+			ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	ctx.SetBodyStream(&fileReader{Reader: io.LimitReader(f, length), Closer: f}, int(length))
+}
+
+type fileReader struct {
+	io.Reader
+	io.Closer
+}
+
+func open(root http.FileSystem, name string) (http.File, os.FileInfo, error) {
+	f, err := root.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return f, info, nil
+}
+
+func notModified(ctx *fasthttp.RequestCtx, etag string, modTime time.Time) bool {
+	if inm := ctx.Request.Header.Peek(fasthttp.HeaderIfNoneMatch); len(inm) > 0 {
+		return etagMatch(string(inm), etag)
+	}
+
+	if ims := ctx.Request.Header.Peek(fasthttp.HeaderIfModifiedSince); len(ims) > 0 {
+		if t, err := http.ParseTime(string(ims)); err == nil {
+			return !modTime.After(t)
+		}
+	}
+
+	return false
+}
+
+// ifRange checks If-Range precondition, range is ignored if the file has changed
+func ifRange(ctx *fasthttp.RequestCtx, etag string, modTime time.Time) bool {
+	value := string(ctx.Request.Header.Peek("If-Range"))
+	if value == "" {
+		return true
+	}
+
+	if strings.HasPrefix(value, "\"") {
+		return value == etag
+	}
+
+	t, err := http.ParseTime(value)
+
+	return err == nil && modTime.Equal(t)
+}
+
+// etagMatch weak comparison of If-None-Match list with etag
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseRange parses single byte range, multiple ranges are not supported
+// and served as a whole file
+func parseRange(header string, size int64) (start, end int64, ok bool) {
+	full := func() (int64, int64, bool) { return 0, size - 1, true }
+
+	if !strings.HasPrefix(header, "bytes=") || strings.Contains(header, ",") {
+		return full()
+	}
+
+	spec := strings.TrimSpace(header[len("bytes="):])
+
+	i := strings.IndexByte(spec, '-')
+	if i < 0 {
+		return full()
+	}
+
+	from, to := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+
+	if from == "" {
+		n, err := strconv.ParseInt(to, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+
+		if n > size {
+			n = size
+		}
+
+		return size - n, size - 1, size > 0
+	}
+
+	start, err := strconv.ParseInt(from, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+
+	end = size - 1
+
+	if to != "" {
+		if end, err = strconv.ParseInt(to, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return start, end, true
+}
@@ -0,0 +1,170 @@
+package static
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/finnan444/utils/transport/transporttest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		header     string
+		start, end int64
+		ok         bool
+	}{
+		{"bytes=0-9", 0, 9, true},
+		{"bytes=10-", 10, 99, true},
+		{"bytes=-10", 90, 99, true},
+		{"bytes=-200", 0, 99, true},
+		{"bytes=50-500", 50, 99, true},
+		{"bytes=100-", 0, 0, false},
+		{"bytes=20-10", 0, 0, false},
+		{"bytes=0-1,5-6", 0, 99, true},
+		{"items=0-1", 0, 99, true},
+	}
+
+	for _, c := range cases {
+		start, end, ok := parseRange(c.header, 100)
+		if ok != c.ok || (ok && (start != c.start || end != c.end)) {
+			t.Errorf("%s: expecting %d-%d %v, got %d-%d %v", c.header, c.start, c.end, c.ok, start, end, ok)
+		}
+	}
+}
+
+func TestETagMatch(t *testing.T) {
+	if !etagMatch(`"a", W/"b"`, `"b"`) {
+		t.Error("Expecting weak etag to match")
+	}
+	if !etagMatch("*", `"b"`) {
+		t.Error("Expecting * to match")
+	}
+	if etagMatch(`"a"`, `"b"`) {
+		t.Error("Expecting different etags not to match")
+	}
+}
+
+func newStaticServer(t *testing.T, config Config) *transporttest.Server {
+	srv := transporttest.NewServer(t, nil)
+
+	RegisterOn(srv.Router, "/app/", config)
+
+	return srv
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		name = filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestServeSPA(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"index.html": "<app>", "app.js": "js"})
+	defer os.RemoveAll(dir)
+
+	srv := newStaticServer(t, Config{Root: http.Dir(dir), SPA: true})
+	defer srv.Close()
+
+	if body := string(srv.Get("/app/users/42").Do().AssertStatus(fasthttp.StatusOK).Body()); body != "<app>" {
+		t.Errorf("Expecting index for unknown route, got %q", body)
+	}
+
+	srv.Get("/app/missing.js").Do().AssertStatus(fasthttp.StatusNotFound)
+
+	resp := srv.Get("/app/app.js").Do().AssertStatus(fasthttp.StatusOK)
+	if body := string(resp.Body()); body != "js" {
+		t.Errorf("Expecting file, got %q", body)
+	}
+
+	srv.Get("/app/app.js").Header(fasthttp.HeaderIfNoneMatch, string(resp.Header.Peek(fasthttp.HeaderETag))).Do().
+		AssertStatus(fasthttp.StatusNotModified)
+
+	head := srv.Request(fasthttp.MethodHead, "/app/app.js").Do().AssertStatus(fasthttp.StatusOK)
+	if len(head.Body()) != 0 || head.Header.ContentLength() != 2 {
+		t.Errorf("Expecting HEAD without body and with length 2, got %q %d", head.Body(), head.Header.ContentLength())
+	}
+
+	srv.Post("/app/app.js").Do().AssertStatus(fasthttp.StatusNotFound)
+
+	called := false
+	srv.AddGetRoute("/state", func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		called = true
+	})
+
+	srv.Request(fasthttp.MethodHead, "/state").Do().AssertStatus(fasthttp.StatusNotFound)
+
+	if called {
+		t.Error("Expecting get route not to be called for HEAD request")
+	}
+}
+
+func TestServePrecompressed(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"app.js": "plain", "app.js.gz": "gzipped", "app.js.br": "brotli"})
+	defer os.RemoveAll(dir)
+
+	srv := newStaticServer(t, Config{Root: http.Dir(dir), Precompressed: true})
+	defer srv.Close()
+
+	cases := []struct {
+		accept, encoding, body string
+	}{
+		{"", "", "plain"},
+		{"gzip", transport.EncodingGzip, "gzipped"},
+		{"gzip, br", transport.EncodingBrotli, "brotli"},
+		{"br;q=0, gzip", transport.EncodingGzip, "gzipped"},
+	}
+
+	for _, c := range cases {
+		resp := srv.Get("/app/app.js").Header(fasthttp.HeaderAcceptEncoding, c.accept).Do().
+			AssertStatus(fasthttp.StatusOK).
+			AssertHeader(fasthttp.HeaderVary, fasthttp.HeaderAcceptEncoding).
+			AssertHeader(fasthttp.HeaderContentEncoding, c.encoding)
+
+		if body := string(resp.Body()); body != c.body {
+			t.Errorf("%q: expecting %q, got %q", c.accept, c.body, body)
+		}
+	}
+
+	resp := srv.Get("/app/app.js").Header(fasthttp.HeaderAcceptEncoding, "gzip").Header(fasthttp.HeaderRange, "bytes=1-2").Do().
+		AssertStatus(fasthttp.StatusPartialContent).
+		AssertHeader(fasthttp.HeaderContentEncoding, "")
+
+	if body := string(resp.Body()); body != "la" {
+		t.Errorf("Expecting range of the original file, got %q", body)
+	}
+}
+
+func TestServeDirectoryRedirect(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"docs/index.html": "docs"})
+	defer os.RemoveAll(dir)
+
+	srv := newStaticServer(t, Config{Root: http.Dir(dir)})
+	defer srv.Close()
+
+	srv.Get("/app/docs").Query("page", "2").Do().
+		AssertStatus(fasthttp.StatusMovedPermanently).
+		AssertHeader(fasthttp.HeaderLocation, "http://"+transporttest.Host+"/app/docs/?page=2")
+
+	if body := string(srv.Get("/app/docs/").Do().AssertStatus(fasthttp.StatusOK).Body()); body != "docs" {
+		t.Errorf("Expecting directory index, got %q", body)
+	}
+}
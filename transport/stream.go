@@ -0,0 +1,250 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/finnan444/utils/pool"
+	"github.com/valyala/fasthttp"
+)
+
+// compressionConfigKey user value key under which CompressHandler stores its config
+const compressionConfigKey = "transport.compression"
+
+// ItemIterator iterates over items streamed by SendNDJSON.
+// If iterator implements io.Closer, Close is called when streaming is finished
+type ItemIterator interface {
+	// Next returns next item, ok is false when there are no more items
+	Next() (item interface{}, ok bool)
+}
+
+// ItemIteratorFunc is an adapter to use ordinary functions as ItemIterator
+type ItemIteratorFunc func() (interface{}, bool)
+
+// Next impl
+func (f ItemIteratorFunc) Next() (interface{}, bool) {
+	return f()
+}
+
+// ChanIterator streams items from channel until it is closed.
+// The producer must always close the channel, e.g. when the context of the request is done,
+// otherwise Close leaks the goroutine draining it
+type ChanIterator <-chan interface{}
+
+// Next impl
+func (ch ChanIterator) Next() (interface{}, bool) {
+	item, ok := <-ch
+	return item, ok
+}
+
+// Close drains the channel in background until the producer closes it,
+// so producer is never blocked if the client went away in the middle of the stream
+func (ch ChanIterator) Close() error {
+	go func() {
+		for item := range ch {
+			if reuse, ok := item.(pool.Reusable); ok {
+				reuse.Reuse()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// streamEncoder writes json into the response stream (possibly through compressor)
+// and keeps the beginning of the output for logging
+type streamEncoder struct {
+	w      *bufio.Writer
+	cw     compressWriter
+	out    io.Writer
+	enc    *json.Encoder
+	logged []byte
+	limit  int
+}
+
+var streamEncoders = sync.Pool{
+	New: func() interface{} {
+		se := &streamEncoder{}
+		se.enc = json.NewEncoder(se)
+		return se
+	},
+}
+
+func acquireStreamEncoder(w *bufio.Writer, encoding string, logLimit int) *streamEncoder {
+	se := streamEncoders.Get().(*streamEncoder)
+	se.w, se.out, se.limit = w, w, logLimit
+
+	if encoding != "" {
+		if se.cw = acquireCompressor(encoding, w); se.cw != nil {
+			se.out = se.cw
+		}
+	}
+
+	return se
+}
+
+func releaseStreamEncoder(se *streamEncoder, encoding string) {
+	if se.cw != nil {
+		releaseCompressor(encoding, se.cw)
+	}
+
+	se.w, se.cw, se.out, se.logged = nil, nil, nil, se.logged[:0]
+	streamEncoders.Put(se)
+}
+
+// Write impl
+func (se *streamEncoder) Write(p []byte) (int, error) {
+	if se.limit < 0 {
+		se.logged = append(se.logged, p...)
+	} else if rest := se.limit - len(se.logged); rest > 0 {
+		if rest > len(p) {
+			rest = len(p)
+		}
+		se.logged = append(se.logged, p[:rest]...)
+	}
+
+	return se.out.Write(p)
+}
+
+// Flush sends everything written so far to the client
+func (se *streamEncoder) Flush() error {
+	if se.cw != nil {
+		if err := se.cw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return se.w.Flush()
+}
+
+// Close finishes compressed stream
+func (se *streamEncoder) Close() error {
+	if se.cw != nil {
+		return se.cw.Close()
+	}
+
+	return nil
+}
+
+// prepareStream sets headers of the streamed response and returns negotiated encoding
+func prepareStream(ctx *fasthttp.RequestCtx, contentType string) string {
+	ctx.SetContentType(contentType)
+
+	config, ok := ctx.UserValue(compressionConfigKey).(*CompressionConfig)
+	if !ok || ctx.IsHead() {
+		return ""
+	}
+
+	if _, ok = noCompressionPathes[string(ctx.Path())]; ok {
+		return ""
+	}
+
+	AddVary(&ctx.Response.Header, fasthttp.HeaderAcceptEncoding)
+
+	encoding := NegotiateEncoding(ctx, config)
+	if encoding != "" {
+		ctx.Response.Header.Set(fasthttp.HeaderContentEncoding, encoding)
+	}
+
+	return encoding
+}
+
+func streamLogLimit(logFlag LogFlag) int {
+	switch {
+	case (logFlag & ToLog) == 0:
+		return 0
+	case (logFlag & FullLog) != 0:
+		return -1
+	default:
+		return 255
+	}
+}
+
+// SendResponseStream does the same as SendResponse, but encodes response directly
+// into the connection instead of building the whole body in memory.
+// Response is reused after it is written. Encoding errors can't change
+// the status code anymore, so they are only logged
+func SendResponseStream(ctx *fasthttp.RequestCtx, response pool.Reusable, startTime time.Time, server PathesLogger) {
+	encoding := prepareStream(ctx, ApplicationJSONUTF8)
+	method, path, reqID := string(ctx.Method()), string(ctx.Path()), ctx.ID()
+	logFlag := server.GetLogFlag(path)
+
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer response.Reuse()
+
+		se := acquireStreamEncoder(w, encoding, streamLogLimit(logFlag))
+		defer releaseStreamEncoder(se, encoding)
+
+		err := se.enc.Encode(response)
+		if err == nil {
+			err = se.Close()
+		}
+
+		if err != nil {
+			logger.Printf("[%s %s %d][Response] stream error: %v\n", method, path, reqID, err)
+		}
+
+		if (logFlag&ToLog) != 0 && accessLog == nil {
+			logger.Printf("[%s %s %d][Response %s] %s\n", method, path, reqID, time.Since(startTime), RedactBody(se.logged))
+		}
+	})
+}
+
+// SendNDJSON streams items as newline delimited json, flushing every item to the client.
+// Items implementing pool.Reusable are reused after they are written
+func SendNDJSON(ctx *fasthttp.RequestCtx, items ItemIterator, startTime time.Time, server PathesLogger) {
+	encoding := prepareStream(ctx, ApplicationNDJSON)
+	method, path, reqID := string(ctx.Method()), string(ctx.Path()), ctx.ID()
+	logFlag := server.GetLogFlag(path)
+
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		if closer, ok := items.(io.Closer); ok {
+			defer closer.Close()
+		}
+
+		se := acquireStreamEncoder(w, encoding, streamLogLimit(logFlag))
+		defer releaseStreamEncoder(se, encoding)
+
+		var (
+			count int
+			err   error
+		)
+
+		for {
+			item, ok := items.Next()
+			if !ok {
+				break
+			}
+
+			err = se.enc.Encode(item)
+			if reuse, ok := item.(pool.Reusable); ok {
+				reuse.Reuse()
+			}
+
+			if err == nil {
+				err = se.Flush()
+			}
+
+			if err != nil {
+				break
+			}
+
+			count++
+		}
+
+		if err == nil {
+			err = se.Close()
+		}
+
+		if err != nil {
+			logger.Printf("[%s %s %d][Response] stream error after %d items: %v\n", method, path, reqID, count, err)
+		}
+
+		if (logFlag&ToLog) != 0 && accessLog == nil {
+			logger.Printf("[%s %s %d][Response %s] %d items %s\n", method, path, reqID, time.Since(startTime), count, RedactBody(se.logged))
+		}
+	})
+}
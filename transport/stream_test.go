@@ -0,0 +1,138 @@
+package transport_test
+
+import (
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/finnan444/utils/transport/transporttest"
+	"github.com/valyala/fasthttp"
+)
+
+type streamItem struct {
+	ID     int `json:"id"`
+	reused *int32
+}
+
+func (i *streamItem) Reuse() {
+	atomic.AddInt32(i.reused, 1)
+}
+
+func TestSendResponseStream(t *testing.T) {
+	pathes := &transport.LogPathes{FullLogPathes: map[string]transport.LogFlag{"*": 0}}
+
+	srv := transporttest.NewServer(t, pathes)
+	defer srv.Close()
+
+	handler := func(stream bool) transport.RouterFunc {
+		return func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+			resp := transport.GetResponse()
+			resp.Payload = map[string]int{"count": 3}
+
+			if stream {
+				transport.SendResponseStream(ctx, resp, now, pathes)
+			} else {
+				transport.SendResponse(ctx, resp, now, pathes)
+			}
+		}
+	}
+
+	srv.AddGetRoute("/stream", handler(true))
+	srv.AddGetRoute("/plain", handler(false))
+
+	streamed := srv.Get("/stream").Do().AssertStatus(fasthttp.StatusOK).AssertHeader(fasthttp.HeaderContentType, transport.ApplicationJSONUTF8)
+	plain := srv.Get("/plain").Do()
+
+	if strings.TrimSpace(string(streamed.Body())) != string(plain.Body()) {
+		t.Errorf("Expecting streamed response to match SendResponse, got %s and %s", streamed.Body(), plain.Body())
+	}
+}
+
+func TestSendNDJSON(t *testing.T) {
+	var reused int32
+
+	pathes := &transport.LogPathes{FullLogPathes: map[string]transport.LogFlag{"*": 0}}
+
+	srv := transporttest.NewServer(t, pathes)
+	defer srv.Close()
+
+	srv.AddGetRoute("/items", func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		n := 0
+		transport.SendNDJSON(ctx, transport.ItemIteratorFunc(func() (interface{}, bool) {
+			n++
+			return &streamItem{ID: n, reused: &reused}, n <= 3
+		}), now, pathes)
+	})
+
+	srv.AddGetRoute("/chan", func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		ch := make(chan interface{})
+		go func() {
+			defer close(ch)
+			for i := 1; i <= 3; i++ {
+				ch <- &streamItem{ID: i, reused: &reused}
+			}
+		}()
+
+		transport.SendNDJSON(ctx, transport.ChanIterator(ch), now, pathes)
+	})
+
+	for _, path := range []string{"/items", "/chan"} {
+		resp := srv.Get(path).Do().AssertStatus(fasthttp.StatusOK).AssertHeader(fasthttp.HeaderContentType, transport.ApplicationNDJSON)
+
+		lines := strings.Split(strings.TrimSpace(string(resp.Body())), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("%s: expecting 3 lines, got %q", path, resp.Body())
+		}
+
+		for i, line := range lines {
+			item := &streamItem{}
+			if err := json.Unmarshal([]byte(line), item); err != nil || item.ID != i+1 {
+				t.Errorf("%s: unexpected line %q: %v", path, line, err)
+			}
+		}
+	}
+
+	if n := atomic.LoadInt32(&reused); n != 6 {
+		t.Errorf("Expecting all streamed items to be reused, got %d", n)
+	}
+}
+
+func TestChanIteratorClose(t *testing.T) {
+	var reused int32
+
+	ch := make(chan interface{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer close(ch)
+
+		for i := 0; i < 3; i++ {
+			ch <- &streamItem{ID: i, reused: &reused}
+		}
+	}()
+
+	it := transport.ChanIterator(ch)
+	if _, ok := it.Next(); !ok {
+		t.Fatal("Expecting item")
+	}
+
+	it.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expecting producer not to be blocked after Close")
+	}
+
+	for i := 0; i < 100 && atomic.LoadInt32(&reused) < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if n := atomic.LoadInt32(&reused); n != 2 {
+		t.Errorf("Expecting drained items to be reused, got %d", n)
+	}
+}
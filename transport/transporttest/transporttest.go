@@ -0,0 +1,322 @@
+// Package transporttest runs transport routes in-process for tests
+package transporttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// Host used in request uris
+const Host = "transporttest"
+
+// Server serves isolated router on in-memory listener
+type Server struct {
+	*transport.Router
+	t      testing.TB
+	ln     *fasthttputil.InmemoryListener
+	client *fasthttp.Client
+}
+
+// NewServer starts server with empty router, routes are added to the embedded Router.
+// Nil pathes disables request logging
+func NewServer(t testing.TB, pathes transport.PathesLogger) *Server {
+	if pathes == nil {
+		pathes = &transport.LogPathes{FullLogPathes: map[string]transport.LogFlag{"*": 0}}
+	}
+
+	s := &Server{Router: transport.NewRouter(), t: t, ln: fasthttputil.NewInmemoryListener()}
+	s.client = &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return s.ln.Dial()
+		},
+	}
+
+	go func() {
+		_ = fasthttp.Serve(s.ln, s.Router.ProcessRouting(pathes))
+	}()
+
+	return s
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	_ = s.ln.Close()
+}
+
+// Dial opens raw connection to the server, e.g. for websocket or streaming tests
+func (s *Server) Dial() (net.Conn, error) {
+	return s.ln.Dial()
+}
+
+// Get starts GET request
+func (s *Server) Get(path string) *Request {
+	return s.Request(fasthttp.MethodGet, path)
+}
+
+// Post starts POST request
+func (s *Server) Post(path string) *Request {
+	return s.Request(fasthttp.MethodPost, path)
+}
+
+// Put starts PUT request
+func (s *Server) Put(path string) *Request {
+	return s.Request(fasthttp.MethodPut, path)
+}
+
+// Request starts request with the given method
+func (s *Server) Request(method, path string) *Request {
+	r := &Request{s: s, req: &fasthttp.Request{}}
+	r.req.Header.SetMethod(method)
+	r.req.SetRequestURI("http://" + Host + path)
+
+	return r
+}
+
+// Request is a fluent request builder
+type Request struct {
+	s        *Server
+	req      *fasthttp.Request
+	body     interface{}
+	signed   bool
+	user     string
+	secret   string
+	kernel   bool
+	token    string
+	signTime time.Time
+}
+
+// Header sets request header
+func (r *Request) Header(key, value string) *Request {
+	r.req.Header.Set(key, value)
+	return r
+}
+
+// Query adds query argument
+func (r *Request) Query(key, value string) *Request {
+	r.req.URI().QueryArgs().Add(key, value)
+	return r
+}
+
+// Body sets raw body
+func (r *Request) Body(body []byte, contentType string) *Request {
+	r.req.SetBody(body)
+	r.req.Header.SetContentType(contentType)
+
+	return r
+}
+
+// JSON sets value encoded as json body
+func (r *Request) JSON(v interface{}) *Request {
+	r.body = v
+	return r
+}
+
+// Signed adds time and signature fields checked by transport.Authenticate to the json body
+func (r *Request) Signed(secret string) *Request {
+	r.signed, r.secret = true, secret
+	return r
+}
+
+// SignedUser adds user, time and signature fields checked by transport.AuthenticateUser to the json body
+func (r *Request) SignedUser(user, secret string) *Request {
+	r.signed, r.user, r.secret = true, user, secret
+	return r
+}
+
+// At sets time used in signature, current time by default
+func (r *Request) At(t time.Time) *Request {
+	r.signTime = t
+	return r
+}
+
+// Kernel wraps json body into transport.KernelBaseRequest with the given token
+func (r *Request) Kernel(token string) *Request {
+	r.kernel, r.token = true, token
+	return r
+}
+
+func (r *Request) encodeBody() error {
+	var body interface{} = r.body
+
+	if r.signed {
+		fields := make(map[string]interface{})
+
+		if r.body != nil {
+			js, err := json.Marshal(r.body)
+			if err != nil {
+				return err
+			}
+
+			if err = json.Unmarshal(js, &fields); err != nil {
+				return err
+			}
+		}
+
+		if r.signTime.IsZero() {
+			r.signTime = time.Now()
+		}
+
+		unix := strconv.FormatInt(r.signTime.Unix(), 10)
+		fields["time"] = r.signTime.Unix()
+
+		if r.user != "" {
+			fields["user"] = r.user
+			fields["signature"] = transport.Sign(r.user, r.secret, unix)
+		} else {
+			fields["signature"] = transport.Sign(unix, r.secret)
+		}
+
+		body = fields
+	}
+
+	if r.kernel {
+		body = &transport.KernelBaseRequest{Token: r.token, Payload: body}
+	}
+
+	if body == nil {
+		return nil
+	}
+
+	js, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	r.req.SetBody(js)
+	r.req.Header.SetContentType(transport.ApplicationJSON)
+
+	return nil
+}
+
+// Do sends request, test fails immediately on transport errors
+func (r *Request) Do() *Response {
+	t := r.s.t
+	t.Helper()
+
+	if err := r.encodeBody(); err != nil {
+		t.Fatalf("transporttest: encode body: %v", err)
+	}
+
+	resp := &fasthttp.Response{}
+	if err := r.s.client.Do(r.req, resp); err != nil {
+		t.Fatalf("transporttest: %s %s: %v", r.req.Header.Method(), r.req.URI().Path(), err)
+	}
+
+	return &Response{t: t, Response: resp, method: string(r.req.Header.Method()), path: string(r.req.URI().Path())}
+}
+
+// Response of the request with assertion helpers, failed assertions
+// are reported with t.Errorf, so chain continues
+type Response struct {
+	*fasthttp.Response
+	t            testing.TB
+	method, path string
+	basic        *basicResponse
+}
+
+// basicResponse is response.BasicResponse with payload kept raw for decoding into the test type
+type basicResponse struct {
+	Code    int             `json:"code"`
+	Msg     string          `json:"msg"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// AssertStatus checks http status
+func (r *Response) AssertStatus(status int) *Response {
+	r.t.Helper()
+
+	if r.StatusCode() != status {
+		r.t.Errorf("%s %s: expecting status %d, got %d: %s", r.method, r.path, status, r.StatusCode(), r.Body())
+	}
+
+	return r
+}
+
+// AssertHeader checks response header
+func (r *Response) AssertHeader(key, value string) *Response {
+	r.t.Helper()
+
+	if actual := string(r.Header.Peek(key)); actual != value {
+		r.t.Errorf("%s %s: expecting %s header %q, got %q", r.method, r.path, key, value, actual)
+	}
+
+	return r
+}
+
+// Basic decodes body as response.BasicResponse, payload is left raw
+func (r *Response) Basic() (code int, msg string, payload json.RawMessage) {
+	r.t.Helper()
+
+	if r.basic == nil {
+		r.basic = &basicResponse{}
+		if err := json.Unmarshal(r.Body(), r.basic); err != nil {
+			r.t.Errorf("%s %s: response is not BasicResponse: %v: %s", r.method, r.path, err, r.Body())
+		}
+	}
+
+	return r.basic.Code, r.basic.Msg, r.basic.Payload
+}
+
+// AssertCode checks BasicResponse code
+func (r *Response) AssertCode(code int) *Response {
+	r.t.Helper()
+
+	if actual, msg, _ := r.Basic(); actual != code {
+		r.t.Errorf("%s %s: expecting code %d, got %d %q", r.method, r.path, code, actual, msg)
+	}
+
+	return r
+}
+
+// DecodePayload decodes BasicResponse payload into v
+func (r *Response) DecodePayload(v interface{}) *Response {
+	r.t.Helper()
+
+	if _, _, payload := r.Basic(); len(payload) > 0 {
+		if err := json.Unmarshal(payload, v); err != nil {
+			r.t.Errorf("%s %s: decode payload: %v: %s", r.method, r.path, err, payload)
+		}
+	}
+
+	return r
+}
+
+// AssertPayload checks that BasicResponse payload is equal to expected value encoded as json
+func (r *Response) AssertPayload(expected interface{}) *Response {
+	r.t.Helper()
+
+	_, _, payload := r.Basic()
+
+	if !jsonEqual(payload, expected) {
+		js, _ := json.Marshal(expected)
+		r.t.Errorf("%s %s: expecting payload %s, got %s", r.method, r.path, js, payload)
+	}
+
+	return r
+}
+
+// jsonEqual compares raw json with value ignoring formatting and key order
+func jsonEqual(raw json.RawMessage, expected interface{}) bool {
+	js, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+
+	var a, b interface{}
+	if json.Unmarshal(js, &a) != nil || json.Unmarshal(raw, &b) != nil {
+		return bytes.Equal(js, raw)
+	}
+
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+
+	return bytes.Equal(ja, jb)
+}
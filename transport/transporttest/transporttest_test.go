@@ -0,0 +1,56 @@
+package transporttest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/finnan444/utils/transport/request"
+	"github.com/valyala/fasthttp"
+)
+
+type greetRequest struct {
+	request.BasicRequest
+	Name string `json:"name"`
+}
+
+var quiet = &transport.LogPathes{FullLogPathes: map[string]transport.LogFlag{"*": 0}}
+
+func TestServer(t *testing.T) {
+	srv := NewServer(t, nil)
+	defer srv.Close()
+
+	srv.AddPostRoute("/greet", func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		req := &greetRequest{}
+		resp := transport.GetResponse()
+
+		if err := json.Unmarshal(ctx.PostBody(), req); err != nil {
+			resp.SetError(transport.RequestError, err.Error())
+		} else if transport.Authenticate(req, resp, "secret", quiet) {
+			resp.Payload = map[string]string{"greeting": "hello " + req.Name}
+		}
+
+		transport.SendResponse(ctx, resp, now, quiet)
+	})
+
+	srv.Post("/greet").JSON(map[string]string{"name": "bob"}).Signed("secret").Do().
+		AssertStatus(fasthttp.StatusOK).
+		AssertHeader(fasthttp.HeaderContentType, transport.ApplicationJSONUTF8).
+		AssertCode(0).
+		AssertPayload(map[string]string{"greeting": "hello bob"})
+
+	srv.Post("/greet").JSON(map[string]string{"name": "bob"}).Signed("wrong").Do().
+		AssertCode(transport.SignatureMismatch)
+
+	srv.Get("/greet").Do().AssertStatus(fasthttp.StatusNotFound)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetRequestURI("/greet")
+	transport.ProcessSimpleRouting()(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusNotFound {
+		t.Errorf("Expecting route not to be added to the default router, got %d", ctx.Response.StatusCode())
+	}
+}
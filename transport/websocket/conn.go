@@ -0,0 +1,446 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType type of data message
+type MessageType byte
+
+// Message types
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+const (
+	opContinuation = 0
+	opClose        = 8
+	opPing         = 9
+	opPong         = 10
+
+	finBit  = 0x80
+	maskBit = 0x80
+
+	maxControlPayload = 125
+)
+
+// Close codes
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+var (
+	// ErrClosed returned on write to closed connection
+	ErrClosed = errors.New("websocket: connection closed")
+	// ErrMessageTooBig returned when message exceeds Config.ReadLimit
+	ErrMessageTooBig = errors.New("websocket: message too big")
+)
+
+// CloseError returned by ReadMessage when the close frame is received
+// or the connection is closed because of protocol violation
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// Conn is an upgraded websocket connection. ReadMessage must be called from a single
+// goroutine, writes are safe for concurrent use
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	config *Config
+	stats  *routeStats
+
+	requestID   uint64
+	path        string
+	subprotocol string
+	values      map[string]interface{}
+
+	header [14]byte
+	mask   [4]byte
+
+	wmu       sync.Mutex
+	wbuf      []byte
+	closeSent bool
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (c *Conn) start(nc net.Conn) {
+	c.conn = nc
+	c.br = bufio.NewReader(nc)
+
+	if c.config.PingInterval > 0 {
+		go c.keepalive()
+	}
+}
+
+// RequestID id of the upgrade request
+func (c *Conn) RequestID() uint64 {
+	return c.requestID
+}
+
+// Path of the upgrade request
+func (c *Conn) Path() string {
+	return c.path
+}
+
+// Subprotocol negotiated with the client
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// UserValue returns value set on the upgrade request by middlewares
+func (c *Conn) UserValue(key string) interface{} {
+	return c.values[key]
+}
+
+// RemoteAddr of the client
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Done is closed when the connection is closed
+func (c *Conn) Done() <-chan struct{} {
+	return c.closed
+}
+
+// ReadMessage reads next data message. Pings are answered and pongs are consumed
+// while waiting for it. Fragmented messages are assembled
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		msgType MessageType
+		msg     []byte
+	)
+
+	for {
+		if err := c.conn.SetReadDeadline(time.Now().Add(c.config.ReadTimeout)); err != nil {
+			return 0, nil, err
+		}
+
+		fin, opcode, length, err := c.readHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if opcode >= opClose {
+			if !fin || length > maxControlPayload {
+				return 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+			}
+
+			if opcode > opPong {
+				return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+			}
+
+			payload := make([]byte, length)
+			if err = c.readPayload(payload); err != nil {
+				return 0, nil, err
+			}
+
+			if err = c.handleControl(opcode, payload); err != nil {
+				return 0, nil, err
+			}
+
+			continue
+		}
+
+		switch {
+		case opcode == opContinuation && msgType == 0:
+			return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+		case opcode != opContinuation && msgType != 0:
+			return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+		case opcode != opContinuation && opcode != byte(TextMessage) && opcode != byte(BinaryMessage):
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		if opcode != opContinuation {
+			msgType = MessageType(opcode)
+		}
+
+		if int64(len(msg))+int64(length) > c.config.ReadLimit {
+			c.fail(CloseMessageTooBig, "")
+			return 0, nil, ErrMessageTooBig
+		}
+
+		start := len(msg)
+		msg = append(msg, make([]byte, length)...)
+
+		if err = c.readPayload(msg[start:]); err != nil {
+			return 0, nil, err
+		}
+
+		if !fin {
+			continue
+		}
+
+		if msgType == TextMessage && !utf8.Valid(msg) {
+			return 0, nil, c.fail(CloseInvalidPayload, "invalid utf8")
+		}
+
+		if c.stats != nil {
+			atomic.AddInt64(&c.stats.in, 1)
+		}
+
+		return msgType, msg, nil
+	}
+}
+
+// ReadJSON reads next message and decodes it as json
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, msg, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(msg, v)
+}
+
+// WriteMessage sends data message
+func (c *Conn) WriteMessage(msgType MessageType, data []byte) error {
+	return c.writeFrame(byte(msgType), data)
+}
+
+// WriteJSON sends v encoded as json text message
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.WriteMessage(TextMessage, data)
+}
+
+// Close sends normal close frame and closes the connection
+func (c *Conn) Close() error {
+	return c.CloseWithCode(CloseNormal, "")
+}
+
+// CloseWithCode sends close frame with the given code and closes the connection
+func (c *Conn) CloseWithCode(code int, text string) error {
+	err := c.writeClose(code, text)
+
+	c.closeOnce.Do(func() {
+		close(c.closed)
+
+		// hijacked connection is closed by fasthttp only after handler returns
+		if u, ok := c.conn.(interface{ UnsafeConn() net.Conn }); ok {
+			u.UnsafeConn().Close()
+		} else {
+			c.conn.Close()
+		}
+	})
+
+	if err == ErrClosed {
+		return nil
+	}
+
+	return err
+}
+
+func (c *Conn) keepalive() {
+	ticker := time.NewTicker(c.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ticker.C:
+			if err := c.writeFrame(opPing, nil); err != nil {
+				c.CloseWithCode(CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+func (c *Conn) handleControl(opcode byte, payload []byte) error {
+	switch opcode {
+	case opPing:
+		if err := c.writeFrame(opPong, payload); err != nil && err != ErrClosed {
+			return err
+		}
+	case opClose:
+		closeErr := &CloseError{Code: CloseNoStatus}
+
+		if len(payload) == 1 {
+			return c.fail(CloseProtocolError, "invalid close frame")
+		}
+
+		if len(payload) >= 2 {
+			closeErr.Code = int(binary.BigEndian.Uint16(payload))
+			closeErr.Text = string(payload[2:])
+		}
+
+		reply := closeErr.Code
+		if reply == CloseNoStatus {
+			reply = CloseNormal
+		}
+
+		c.CloseWithCode(reply, "")
+
+		return closeErr
+	}
+
+	return nil
+}
+
+// fail closes the connection because of protocol violation
+func (c *Conn) fail(code int, text string) error {
+	c.CloseWithCode(code, text)
+	return &CloseError{Code: code, Text: text}
+}
+
+func (c *Conn) readHeader() (fin bool, opcode byte, length uint64, err error) {
+	h := c.header[:2]
+	if _, err = io.ReadFull(c.br, h); err != nil {
+		return
+	}
+
+	if h[0]&0x70 != 0 {
+		err = c.fail(CloseProtocolError, "reserved bits set")
+		return
+	}
+
+	fin, opcode = h[0]&finBit != 0, h[0]&0x0f
+
+	if h[1]&maskBit == 0 {
+		err = c.fail(CloseProtocolError, "client frame is not masked")
+		return
+	}
+
+	switch length = uint64(h[1] & 0x7f); length {
+	case 126:
+		if _, err = io.ReadFull(c.br, c.header[:2]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(c.header[:2]))
+	case 127:
+		if _, err = io.ReadFull(c.br, c.header[:8]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(c.header[:8])
+	}
+
+	if length > uint64(c.config.ReadLimit) {
+		c.fail(CloseMessageTooBig, "")
+		err = ErrMessageTooBig
+
+		return
+	}
+
+	_, err = io.ReadFull(c.br, c.mask[:])
+
+	return
+}
+
+func (c *Conn) readPayload(p []byte) error {
+	if _, err := io.ReadFull(c.br, p); err != nil {
+		return err
+	}
+
+	for i := range p {
+		p[i] ^= c.mask[i&3]
+	}
+
+	return nil
+}
+
+func (c *Conn) writeClose(code int, text string) error {
+	// reason is cut at rune boundary, so it stays valid utf-8
+	if n := maxControlPayload - 2; len(text) > n {
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+		text = text[:n]
+	}
+
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, text...)
+
+	return c.writeFrame(opClose, payload)
+}
+
+func (c *Conn) writeFrame(opcode byte, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	c.wbuf = appendFrame(c.wbuf[:0], opcode, data)
+
+	return c.writeLocked(opcode, c.wbuf)
+}
+
+// writePrepared sends frame encoded by appendFrame
+func (c *Conn) writePrepared(frame []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	return c.writeLocked(frame[0]&0x0f, frame)
+}
+
+func (c *Conn) writeLocked(opcode byte, frame []byte) error {
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout)); err != nil {
+		return err
+	}
+
+	if _, err := c.conn.Write(frame); err != nil {
+		return err
+	}
+
+	if c.stats != nil && opcode < opClose {
+		atomic.AddInt64(&c.stats.out, 1)
+	}
+
+	return nil
+}
+
+// appendFrame appends unmasked (server side) final frame
+func appendFrame(dst []byte, opcode byte, data []byte) []byte {
+	dst = append(dst, finBit|opcode)
+
+	switch n := len(data); {
+	case n <= 125:
+		dst = append(dst, byte(n))
+	case n <= 0xffff:
+		dst = append(dst, 126, byte(n>>8), byte(n))
+	default:
+		dst = append(dst, 127)
+		dst = append(dst, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(dst[len(dst)-8:], uint64(n))
+	}
+
+	return append(dst, data...)
+}
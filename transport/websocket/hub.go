@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// DefaultHubQueue default number of messages queued per connection
+const DefaultHubQueue = 64
+
+// Hub broadcasts messages to a set of connections. Every connection has its own
+// bounded queue, connections which can't keep up are closed with 1008
+type Hub struct {
+	sync.RWMutex
+	members map[*Conn]chan []byte
+	queue   int
+}
+
+// NewHub creates hub, queue is the number of messages buffered per connection
+func NewHub(queue int) *Hub {
+	if queue <= 0 {
+		queue = DefaultHubQueue
+	}
+
+	return &Hub{members: make(map[*Conn]chan []byte), queue: queue}
+}
+
+// Add subscribes connection to broadcasts. Connection is removed automatically
+// when it is closed, usually Add is called in the beginning of the Handler
+func (h *Hub) Add(conn *Conn) {
+	ch := make(chan []byte, h.queue)
+
+	h.Lock()
+	if _, ok := h.members[conn]; ok {
+		h.Unlock()
+		return
+	}
+	h.members[conn] = ch
+	h.Unlock()
+
+	go h.write(conn, ch)
+}
+
+// Remove unsubscribes connection
+func (h *Hub) Remove(conn *Conn) {
+	h.Lock()
+	if ch, ok := h.members[conn]; ok {
+		delete(h.members, conn)
+		close(ch)
+	}
+	h.Unlock()
+}
+
+// Len returns number of connections
+func (h *Hub) Len() int {
+	h.RLock()
+	defer h.RUnlock()
+
+	return len(h.members)
+}
+
+// Broadcast sends message to all connections
+func (h *Hub) Broadcast(msgType MessageType, data []byte) {
+	var (
+		frame = appendFrame(nil, byte(msgType), data)
+		slow  []*Conn
+	)
+
+	h.RLock()
+	for conn, ch := range h.members {
+		select {
+		case ch <- frame:
+		default:
+			slow = append(slow, conn)
+		}
+	}
+	h.RUnlock()
+
+	for _, conn := range slow {
+		h.Remove(conn)
+		go conn.CloseWithCode(ClosePolicyViolation, "slow consumer")
+	}
+}
+
+// BroadcastJSON sends v encoded as json text message to all connections
+func (h *Hub) BroadcastJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	h.Broadcast(TextMessage, data)
+
+	return nil
+}
+
+func (h *Hub) write(conn *Conn, ch chan []byte) {
+	for {
+		select {
+		case frame, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			if err := conn.writePrepared(frame); err != nil {
+				h.Remove(conn)
+				conn.CloseWithCode(CloseGoingAway, "")
+
+				return
+			}
+		case <-conn.closed:
+			h.Remove(conn)
+			return
+		}
+	}
+}
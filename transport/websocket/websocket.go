@@ -0,0 +1,216 @@
+package websocket
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+)
+
+// Defaults
+const (
+	DefaultReadLimit    = 1 << 20
+	DefaultReadTimeout  = time.Minute
+	DefaultWriteTimeout = 10 * time.Second
+	DefaultPingInterval = 25 * time.Second
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Handler processes upgraded connection, connection is closed after it returns
+type Handler func(conn *Conn)
+
+// Config describes websocket route
+type Config struct {
+	// ReadLimit max size of the message, larger messages close the connection with 1009
+	ReadLimit int64
+	// ReadTimeout max time between two frames from the client, pongs included
+	ReadTimeout time.Duration
+	// WriteTimeout deadline of a single frame write
+	WriteTimeout time.Duration
+	// PingInterval period of keepalive pings, negative disables them
+	PingInterval time.Duration
+	// Subprotocols supported by the server in the order of preference
+	Subprotocols []string
+	// CheckOrigin validates Origin header, by default only same host origins are allowed
+	CheckOrigin func(ctx *fasthttp.RequestCtx) bool
+}
+
+func (c *Config) withDefaults() *Config {
+	result := *c
+
+	if result.ReadLimit <= 0 {
+		result.ReadLimit = DefaultReadLimit
+	}
+
+	if result.ReadTimeout <= 0 {
+		result.ReadTimeout = DefaultReadTimeout
+	}
+
+	if result.WriteTimeout <= 0 {
+		result.WriteTimeout = DefaultWriteTimeout
+	}
+
+	if result.PingInterval == 0 {
+		result.PingInterval = DefaultPingInterval
+	}
+
+	if result.CheckOrigin == nil {
+		result.CheckOrigin = sameOrigin
+	}
+
+	return &result
+}
+
+// routeStats connection and message counters of a route shown by /internal/stats
+type routeStats struct {
+	active, total, in, out int64
+}
+
+func (s *routeStats) String() string {
+	return fmt.Sprintf("{\"active\":%d, \"total\":%d, \"in\":%d, \"out\":%d}",
+		atomic.LoadInt64(&s.active), atomic.LoadInt64(&s.total), atomic.LoadInt64(&s.in), atomic.LoadInt64(&s.out))
+}
+
+// Register adds get route upgrading requests to websocket connections to transport.DefaultRouter.
+// Middlewares (authentication, rate limits) are applied to the upgrade request
+// as for ordinary routes, connection counters are shown by /internal/stats
+func Register(path string, config Config, handler Handler, middlewares ...transport.Middleware) {
+	RegisterOn(transport.DefaultRouter, path, config, handler, middlewares...)
+}
+
+// RegisterOn does the same as Register for router, counters are added to its stats
+func RegisterOn(router *transport.Router, path string, config Config, handler Handler, middlewares ...transport.Middleware) {
+	stats := &routeStats{}
+	cfg := config.withDefaults()
+
+	router.AddGetRoute(path, transport.Chain(func(ctx *fasthttp.RequestCtx, now time.Time, adds ...string) {
+		upgrade(ctx, cfg, handler, stats)
+	}, middlewares...))
+	router.AddStats("[WS] "+path, stats)
+}
+
+// Upgrade upgrades the request to websocket connection and runs handler in the hijacked connection.
+// If handshake is invalid, error response is set and false is returned
+func Upgrade(ctx *fasthttp.RequestCtx, config Config, handler Handler) bool {
+	return upgrade(ctx, config.withDefaults(), handler, nil)
+}
+
+func upgrade(ctx *fasthttp.RequestCtx, config *Config, handler Handler, stats *routeStats) bool {
+	if !ctx.IsGet() ||
+		!headerContains(ctx.Request.Header.Peek(fasthttp.HeaderConnection), "upgrade") ||
+		!headerContains(ctx.Request.Header.Peek("Upgrade"), "websocket") {
+		ctx.Error("Bad Request", fasthttp.StatusBadRequest)
+		return false
+	}
+
+	if string(ctx.Request.Header.Peek("Sec-WebSocket-Version")) != "13" {
+		ctx.Error("Unsupported websocket version", fasthttp.StatusUpgradeRequired)
+		ctx.Response.Header.Set("Sec-WebSocket-Version", "13")
+
+		return false
+	}
+
+	key := ctx.Request.Header.Peek("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(string(key)); err != nil || len(decoded) != 16 {
+		ctx.Error("Bad Request", fasthttp.StatusBadRequest)
+		return false
+	}
+
+	if !config.CheckOrigin(ctx) {
+		ctx.Error("Forbidden", fasthttp.StatusForbidden)
+		return false
+	}
+
+	conn := &Conn{
+		config:    config,
+		stats:     stats,
+		requestID: ctx.ID(),
+		path:      string(ctx.Path()),
+		closed:    make(chan struct{}),
+	}
+
+	if len(config.Subprotocols) > 0 {
+		conn.subprotocol = selectSubprotocol(ctx.Request.Header.Peek("Sec-WebSocket-Protocol"), config.Subprotocols)
+	}
+
+	// request context is reused by fasthttp after hijacking, so values set by middlewares are copied
+	ctx.VisitUserValues(func(k []byte, v interface{}) {
+		if conn.values == nil {
+			conn.values = make(map[string]interface{})
+		}
+		conn.values[string(k)] = v
+	})
+
+	ctx.SetStatusCode(fasthttp.StatusSwitchingProtocols)
+	ctx.Response.Header.Set("Upgrade", "websocket")
+	ctx.Response.Header.Set(fasthttp.HeaderConnection, "Upgrade")
+	ctx.Response.Header.Set("Sec-WebSocket-Accept", acceptKey(key))
+
+	if conn.subprotocol != "" {
+		ctx.Response.Header.Set("Sec-WebSocket-Protocol", conn.subprotocol)
+	}
+
+	ctx.Hijack(func(c net.Conn) {
+		conn.start(c)
+
+		if stats != nil {
+			atomic.AddInt64(&stats.active, 1)
+			atomic.AddInt64(&stats.total, 1)
+			defer atomic.AddInt64(&stats.active, -1)
+		}
+
+		handler(conn)
+		conn.Close()
+	})
+
+	return true
+}
+
+func acceptKey(key []byte) string {
+	h := sha1.New()
+	h.Write(key)
+	h.Write([]byte(acceptGUID))
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContains checks comma separated header for the token, case insensitive
+func headerContains(header []byte, token string) bool {
+	for _, item := range bytes.Split(header, []byte{','}) {
+		if strings.EqualFold(string(bytes.TrimSpace(item)), token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func selectSubprotocol(header []byte, supported []string) string {
+	for _, s := range supported {
+		if headerContains(header, s) {
+			return s
+		}
+	}
+
+	return ""
+}
+
+func sameOrigin(ctx *fasthttp.RequestCtx) bool {
+	origin := ctx.Request.Header.Peek("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+
+	u, err := url.Parse(string(origin))
+
+	return err == nil && strings.EqualFold(u.Host, string(ctx.Host()))
+}
@@ -0,0 +1,207 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/finnan444/utils/transport"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+var testHub = NewHub(4)
+
+func init() {
+	Register("/test/ws/echo", Config{}, func(conn *Conn) {
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			if conn.WriteMessage(msgType, msg) != nil {
+				return
+			}
+		}
+	})
+
+	Register("/test/ws/close", Config{}, func(conn *Conn) {
+		conn.CloseWithCode(CloseNormal, strings.Repeat("é", 100))
+	})
+
+	Register("/test/ws/hub", Config{ReadLimit: 16}, func(conn *Conn) {
+		testHub.Add(conn)
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func dial(t *testing.T, ln *fasthttputil.InmemoryListener, path string) (net.Conn, *bufio.Reader) {
+	c, err := ln.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"))
+
+	br := bufio.NewReader(c)
+
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expecting 101, got %d", resp.StatusCode)
+	}
+
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Unexpected accept key %s", accept)
+	}
+
+	return c, br
+}
+
+func writeClientFrame(c net.Conn, fin bool, opcode byte, data []byte) {
+	frame := appendFrame(nil, opcode, nil)[:1]
+	if !fin {
+		frame[0] &^= finBit
+	}
+
+	frame = append(frame, maskBit|byte(len(data)), 1, 2, 3, 4)
+	for i, b := range data {
+		frame = append(frame, b^byte(i%4+1))
+	}
+
+	c.Write(frame)
+}
+
+func readServerFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	h := make([]byte, 2)
+	if _, err := io.ReadFull(br, h); err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, h[1]&0x7f)
+	if _, err := io.ReadFull(br, data); err != nil {
+		t.Fatal(err)
+	}
+
+	return h[0] & 0x0f, data
+}
+
+func serve(t *testing.T) *fasthttputil.InmemoryListener {
+	ln := fasthttputil.NewInmemoryListener()
+	go fasthttp.Serve(ln, transport.ProcessRouting(&transport.LogPathes{}))
+
+	return ln
+}
+
+func TestEcho(t *testing.T) {
+	ln := serve(t)
+	defer ln.Close()
+
+	c, br := dial(t, ln, "/test/ws/echo")
+	defer c.Close()
+
+	writeClientFrame(c, false, byte(TextMessage), []byte("hel"))
+	writeClientFrame(c, true, opPing, []byte("p"))
+	writeClientFrame(c, true, opContinuation, []byte("lo"))
+
+	if op, data := readServerFrame(t, br); op != opPong || string(data) != "p" {
+		t.Errorf("Expecting pong, got %d %q", op, data)
+	}
+
+	if op, data := readServerFrame(t, br); op != byte(TextMessage) || string(data) != "hello" {
+		t.Errorf("Expecting assembled text message, got %d %q", op, data)
+	}
+
+	writeClientFrame(c, true, opClose, []byte{0x03, 0xe8})
+
+	if op, data := readServerFrame(t, br); op != opClose || binary.BigEndian.Uint16(data) != CloseNormal {
+		t.Errorf("Expecting close reply, got %d %v", op, data)
+	}
+}
+
+func TestHubAndReadLimit(t *testing.T) {
+	ln := serve(t)
+	defer ln.Close()
+
+	c, br := dial(t, ln, "/test/ws/hub")
+	defer c.Close()
+
+	for i := 0; i < 100 && testHub.Len() == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	testHub.Broadcast(TextMessage, []byte("news"))
+
+	if op, data := readServerFrame(t, br); op != byte(TextMessage) || string(data) != "news" {
+		t.Errorf("Expecting broadcast, got %d %q", op, data)
+	}
+
+	writeClientFrame(c, true, byte(BinaryMessage), make([]byte, 17))
+
+	if op, data := readServerFrame(t, br); op != opClose || binary.BigEndian.Uint16(data) != CloseMessageTooBig {
+		t.Errorf("Expecting close 1009, got %d %v", op, data)
+	}
+}
+
+func TestHandshakeErrors(t *testing.T) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(&fasthttp.Request{}, nil, nil)
+	ctx.Request.Header.Set("Connection", "keep-alive, Upgrade")
+	ctx.Request.Header.Set("Upgrade", "websocket")
+	ctx.Request.Header.Set("Sec-WebSocket-Version", "8")
+
+	if Upgrade(ctx, Config{}, nil) || ctx.Response.StatusCode() != fasthttp.StatusUpgradeRequired {
+		t.Errorf("Expecting 426, got %d", ctx.Response.StatusCode())
+	}
+
+	ctx.Request.Header.Set("Sec-WebSocket-Version", "13")
+	ctx.Request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	ctx.Request.Header.Set("Origin", "http://evil.example")
+
+	if Upgrade(ctx, Config{}, nil) || ctx.Response.StatusCode() != fasthttp.StatusForbidden {
+		t.Errorf("Expecting 403, got %d", ctx.Response.StatusCode())
+	}
+}
+
+func TestReservedControlOpcode(t *testing.T) {
+	ln := serve(t)
+	defer ln.Close()
+
+	c, br := dial(t, ln, "/test/ws/echo")
+	defer c.Close()
+
+	writeClientFrame(c, true, 0x0b, nil)
+
+	if op, data := readServerFrame(t, br); op != opClose || binary.BigEndian.Uint16(data) != CloseProtocolError {
+		t.Errorf("Expecting close 1002, got %d %v", op, data)
+	}
+}
+
+func TestCloseReasonCut(t *testing.T) {
+	ln := serve(t)
+	defer ln.Close()
+
+	c, br := dial(t, ln, "/test/ws/close")
+	defer c.Close()
+
+	op, data := readServerFrame(t, br)
+	if op != opClose || len(data) > maxControlPayload || !utf8.Valid(data[2:]) || len(data) < maxControlPayload-1 {
+		t.Errorf("Expecting close reason cut at rune boundary, got %d %q", op, data)
+	}
+}